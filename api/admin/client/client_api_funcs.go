@@ -2,26 +2,110 @@ package client
 
 import (
 	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
 
 	"github.com/emccode/polly/api/types"
 )
 
+// pageSize is the number of volumes requested per page when listing
+const pageSize = 500
+
 // Volumes returns a list of all registered Volumes for all Services.
 func (c *Client) Volumes() (reply []*types.Volume, err error) {
-	url := fmt.Sprintf("/admin/volumes")
-	if _, err = c.httpGet(url, &reply); err != nil {
-		return nil, err
-	}
-	return reply, nil
+	return c.VolumesFilter("")
+}
+
+// VolumesFilter returns the registered Volumes matching a filter expression.
+func (c *Client) VolumesFilter(filter string) (reply []*types.Volume, err error) {
+	return c.VolumesList(false, &types.VolumeListOptions{Filter: filter})
 }
 
 // VolumesAll returns a list of all Volumes available for all Services.
 func (c *Client) VolumesAll() (reply []*types.Volume, err error) {
-	url := fmt.Sprintf("/admin/volumesall")
-	if _, err = c.httpGet(url, &reply); err != nil {
-		return nil, err
+	return c.VolumesAllFilter("")
+}
+
+// VolumesAllFilter returns the available Volumes matching a filter expression.
+func (c *Client) VolumesAllFilter(filter string) (reply []*types.Volume, err error) {
+	return c.VolumesList(true, &types.VolumeListOptions{Filter: filter})
+}
+
+// VolumesList returns the registered, or all when all is set, Volumes
+// selected and ordered by the options. The pages of the listing are
+// requested in turn, opts.Limit sets their size.
+func (c *Client) VolumesList(
+	all bool, opts *types.VolumeListOptions) (reply []*types.Volume, err error) {
+
+	return c.volumesList(volumesPath(all), opts)
+}
+
+func (c *Client) volumesList(
+	path string, opts *types.VolumeListOptions) (reply []*types.Volume, err error) {
+
+	o := *opts
+	if o.Limit == 0 {
+		o.Limit = pageSize
+	}
+	for {
+		vols, next, err := c.volumesPage(path, &o)
+		if err != nil {
+			return nil, err
+		}
+		reply = append(reply, vols...)
+		if next == "" {
+			return reply, nil
+		}
+		o.Continue = next
 	}
-	return reply, nil
+}
+
+// VolumesPage returns a single page of the registered, or all when all is
+// set, Volumes and the token of the next page, empty on the last page.
+func (c *Client) VolumesPage(
+	all bool,
+	opts *types.VolumeListOptions) (reply []*types.Volume, next string, err error) {
+
+	return c.volumesPage(volumesPath(all), opts)
+}
+
+func volumesPath(all bool) string {
+	if all {
+		return "/admin/volumesall"
+	}
+	return "/admin/volumes"
+}
+
+func (c *Client) volumesPage(
+	path string,
+	opts *types.VolumeListOptions) (reply []*types.Volume, next string, err error) {
+
+	q := neturl.Values{}
+	if opts.Filter != "" {
+		q.Set("filter", opts.Filter)
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continue != "" {
+		q.Set("continue", opts.Continue)
+	}
+	if opts.Fresh {
+		q.Set("fresh", "true")
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	res, err := c.httpGet(path, &reply)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, res.Header.Get(types.ContinueHeader), nil
 }
 
 // VolumeInspect will inspect a specific volume
@@ -69,6 +153,17 @@ func (c *Client) VolumeLabelsRemove(lr *types.VolumeLabelsRemoveRequest) (reply
 	return reply, nil
 }
 
+// VolumesBulk applies one operation to many volumes. The response reports
+// the outcome for every volume, the request only fails as a whole when it
+// is rejected.
+func (c *Client) VolumesBulk(br *types.VolumeBulkRequest) (reply *types.VolumeBulkResponse, err error) {
+	url := "/admin/volumesbulk"
+	if _, err = c.httpPost(url, br, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
 // VolumeCreate create a volume
 func (c *Client) VolumeCreate(lr *types.VolumeCreateRequest) (reply *types.Volume, err error) {
 	url := "/admin/volumes"
@@ -86,3 +181,190 @@ func (c *Client) VolumeRemove(volumeID string) (err error) {
 	}
 	return nil
 }
+
+// Health returns the liveness of the Polly daemon
+func (c *Client) Health() (reply *types.HealthResponse, err error) {
+	if _, err = c.httpDo(
+		"GET", "/healthz", nil, &reply, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Ready returns the readiness of the Polly daemon and its dependencies
+func (c *Client) Ready() (reply *types.HealthResponse, err error) {
+	if _, err = c.httpDo(
+		"GET", "/readyz", nil, &reply, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Config returns the effective configuration of the Polly daemon
+func (c *Client) Config() (reply map[string]interface{}, err error) {
+	if _, err = c.httpGet("/admin/config", &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// ConfigReload makes the Polly daemon read its configuration again
+func (c *Client) ConfigReload() (reply *types.ConfigReloadResponse, err error) {
+	if _, err = c.httpPost("/admin/config/reload", nil, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// OfferAccept leases the volume of an offer to a framework
+func (c *Client) OfferAccept(
+	offerID string, req *types.OfferAcceptRequest) (reply *types.Lease, err error) {
+	if _, err = c.httpPost(fmt.Sprintf("/admin/v2/offers/%s/accept",
+		neturl.PathEscape(offerID)), req, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Webhooks returns the registered webhooks, without their secrets
+func (c *Client) Webhooks() (reply []*types.Webhook, err error) {
+	if _, err = c.httpGet("/admin/webhooks", &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// WebhookCreate registers a webhook, the reply holds its secret
+func (c *Client) WebhookCreate(req *types.WebhookCreateRequest) (reply *types.Webhook, err error) {
+	if _, err = c.httpPost("/admin/webhooks", req, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// WebhookRemove removes a webhook
+func (c *Client) WebhookRemove(id string) error {
+	_, err := c.httpDelete(fmt.Sprintf("/admin/webhooks/%s", id), nil)
+	return err
+}
+
+// WebhookDeliveries returns the last deliveries to a webhook
+func (c *Client) WebhookDeliveries(id string) (reply []*types.WebhookDelivery, err error) {
+	url := fmt.Sprintf("/admin/webhooks/%s/deliveries", id)
+	if _, err = c.httpGet(url, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Tenants returns the tenants
+func (c *Client) Tenants() (reply []*types.Tenant, err error) {
+	if _, err = c.httpGet("/admin/tenants", &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TenantCreate creates a tenant
+func (c *Client) TenantCreate(t *types.Tenant) (reply *types.Tenant, err error) {
+	if _, err = c.httpPost("/admin/tenants", t, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TenantUpdate replaces the description and the schedulers of a tenant
+func (c *Client) TenantUpdate(t *types.Tenant) (reply *types.Tenant, err error) {
+	url := fmt.Sprintf("/admin/tenants/%s", t.Name)
+	if _, err = c.httpDo("PUT", url, t, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TenantRemove removes a tenant owning no volume
+func (c *Client) TenantRemove(name string) error {
+	_, err := c.httpDelete(fmt.Sprintf("/admin/tenants/%s", name), nil)
+	return err
+}
+
+// TenantTokens returns the tokens of a tenant, without their values
+func (c *Client) TenantTokens(name string) (reply []*types.TenantToken, err error) {
+	url := fmt.Sprintf("/admin/tenants/%s/tokens", name)
+	if _, err = c.httpGet(url, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TenantTokenCreate creates a token of a tenant, the reply holds its value
+func (c *Client) TenantTokenCreate(name string) (reply *types.TenantToken, err error) {
+	url := fmt.Sprintf("/admin/tenants/%s/tokens", name)
+	if _, err = c.httpPost(url, nil, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TenantTokenRemove removes a token of a tenant
+func (c *Client) TenantTokenRemove(name, id string) error {
+	_, err := c.httpDelete(fmt.Sprintf("/admin/tenants/%s/tokens/%s", name, id), nil)
+	return err
+}
+
+// Schedulers returns the registered schedulers
+func (c *Client) Schedulers() (reply []*types.Scheduler, err error) {
+	if _, err = c.httpGet("/admin/schedulers", &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SchedulerCreate registers a scheduler
+func (c *Client) SchedulerCreate(s *types.Scheduler) (reply *types.Scheduler, err error) {
+	if _, err = c.httpPost("/admin/schedulers", s, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SchedulerInspect returns a registered scheduler
+func (c *Client) SchedulerInspect(name string) (reply *types.Scheduler, err error) {
+	url := fmt.Sprintf("/admin/schedulers/%s", name)
+	if _, err = c.httpGet(url, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SchedulerUpdate replaces the type, contact and tenant of a scheduler
+func (c *Client) SchedulerUpdate(s *types.Scheduler) (reply *types.Scheduler, err error) {
+	url := fmt.Sprintf("/admin/schedulers/%s", s.Name)
+	if _, err = c.httpDo("PUT", url, s, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SchedulerRemove unregisters a scheduler
+func (c *Client) SchedulerRemove(name string) error {
+	_, err := c.httpDelete(fmt.Sprintf("/admin/schedulers/%s", name), nil)
+	return err
+}
+
+// SchedulerHeartbeat records that a scheduler is alive
+func (c *Client) SchedulerHeartbeat(name string) (reply *types.Scheduler, err error) {
+	url := fmt.Sprintf("/admin/schedulers/%s/heartbeat", name)
+	if _, err = c.httpPost(url, nil, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SchedulerVolumes returns the volumes offered to a scheduler selected and
+// ordered by the options
+func (c *Client) SchedulerVolumes(
+	name string, opts *types.VolumeListOptions) ([]*types.Volume, error) {
+
+	return c.volumesList(fmt.Sprintf("/admin/schedulers/%s/volumes", name), opts)
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+
+	"github.com/emccode/polly/api/types"
+)
+
+// The v2 functions return the ETag of the volume metadata along with the
+// resource. Passing it back as ifMatch makes a change fail with an error
+// satisfying types.IsPreconditionFailed when the volume was modified since,
+// an empty ifMatch applies the change unconditionally.
+
+// VolumeV2 returns a volume and its ETag.
+func (c *Client) VolumeV2(volumeID string) (reply *types.Volume, etag string, err error) {
+	res, err := c.httpGet(volumeV2Path(volumeID), &reply)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, res.Header.Get("ETag"), nil
+}
+
+// VolumePatch applies a JSON merge patch to the labels and schedulers of a
+// volume. A nil label value in the patch removes the label.
+func (c *Client) VolumePatch(
+	volumeID, ifMatch string,
+	patch map[string]interface{}) (*types.Volume, string, error) {
+
+	h := ifMatchHeader(ifMatch)
+	h.Set("Content-Type", "application/merge-patch+json")
+	return c.volumeV2Do("PATCH", volumeV2Path(volumeID), h, patch)
+}
+
+// VolumeRemoveV2 removes a volume.
+func (c *Client) VolumeRemoveV2(volumeID, ifMatch string) error {
+	_, err := c.httpDoHeader(
+		"DELETE", volumeV2Path(volumeID), ifMatchHeader(ifMatch), nil, nil)
+	return err
+}
+
+// VolumeLabelsV2 returns the labels of a volume and its ETag.
+func (c *Client) VolumeLabelsV2(
+	volumeID string) (reply map[string]string, etag string, err error) {
+
+	res, err := c.httpGet(volumeV2Path(volumeID)+"/labels", &reply)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, res.Header.Get("ETag"), nil
+}
+
+// VolumeLabelsPut replaces the labels of a volume.
+func (c *Client) VolumeLabelsPut(
+	volumeID, ifMatch string,
+	labels map[string]string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("PUT", volumeV2Path(volumeID)+"/labels",
+		ifMatchHeader(ifMatch), labels)
+}
+
+// VolumeLabelsDelete removes all the labels of a volume.
+func (c *Client) VolumeLabelsDelete(
+	volumeID, ifMatch string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("DELETE", volumeV2Path(volumeID)+"/labels",
+		ifMatchHeader(ifMatch), nil)
+}
+
+// VolumeLabelPut sets a label of a volume.
+func (c *Client) VolumeLabelPut(
+	volumeID, ifMatch, key, value string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("PUT", volumeV2Path(volumeID)+"/labels/"+
+		neturl.PathEscape(key), ifMatchHeader(ifMatch), value)
+}
+
+// VolumeLabelDelete removes a label of a volume.
+func (c *Client) VolumeLabelDelete(
+	volumeID, ifMatch, key string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("DELETE", volumeV2Path(volumeID)+"/labels/"+
+		neturl.PathEscape(key), ifMatchHeader(ifMatch), nil)
+}
+
+// VolumeSchedulersV2 returns the schedulers a volume is offered to and its
+// ETag.
+func (c *Client) VolumeSchedulersV2(
+	volumeID string) (reply []string, etag string, err error) {
+
+	res, err := c.httpGet(volumeV2Path(volumeID)+"/schedulers", &reply)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, res.Header.Get("ETag"), nil
+}
+
+// VolumeSchedulersPut replaces the schedulers a volume is offered to.
+func (c *Client) VolumeSchedulersPut(
+	volumeID, ifMatch string,
+	schedulers []string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("PUT", volumeV2Path(volumeID)+"/schedulers",
+		ifMatchHeader(ifMatch), schedulers)
+}
+
+// VolumeSchedulersDelete revokes the offers of a volume to all schedulers.
+func (c *Client) VolumeSchedulersDelete(
+	volumeID, ifMatch string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("DELETE", volumeV2Path(volumeID)+"/schedulers",
+		ifMatchHeader(ifMatch), nil)
+}
+
+// VolumeSchedulerPut offers a volume to a scheduler.
+func (c *Client) VolumeSchedulerPut(
+	volumeID, ifMatch, scheduler string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("PUT", volumeV2Path(volumeID)+"/schedulers/"+
+		neturl.PathEscape(scheduler), ifMatchHeader(ifMatch), nil)
+}
+
+// VolumeSchedulerDelete revokes the offer of a volume to a scheduler.
+func (c *Client) VolumeSchedulerDelete(
+	volumeID, ifMatch, scheduler string) (*types.Volume, string, error) {
+
+	return c.volumeV2Do("DELETE", volumeV2Path(volumeID)+"/schedulers/"+
+		neturl.PathEscape(scheduler), ifMatchHeader(ifMatch), nil)
+}
+
+func (c *Client) volumeV2Do(
+	method, path string,
+	header http.Header,
+	payload interface{}) (reply *types.Volume, etag string, err error) {
+
+	res, err := c.httpDoHeader(method, path, header, payload, &reply)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, res.Header.Get("ETag"), nil
+}
+
+func volumeV2Path(volumeID string) string {
+	return fmt.Sprintf("/admin/v2/volumes/%s", neturl.PathEscape(volumeID))
+}
+
+func ifMatchHeader(ifMatch string) http.Header {
+	h := http.Header{}
+	if ifMatch != "" {
+		h.Set("If-Match", ifMatch)
+	}
+	return h
+}
@@ -7,13 +7,27 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
 )
 
 func (c *Client) httpDo(
 	method, path string,
-	payload, reply interface{}) (*http.Response, error) {
+	payload, reply interface{},
+	accept ...int) (*http.Response, error) {
+
+	return c.httpDoHeader(method, path, nil, payload, reply, accept...)
+}
+
+// httpDoHeader performs a request with headers of its own, set after the
+// headers of the client
+func (c *Client) httpDoHeader(
+	method, path string,
+	header http.Header,
+	payload, reply interface{},
+	accept ...int) (*http.Response, error) {
 
 	reqBody, err := encPayload(payload)
 	if err != nil {
@@ -30,6 +44,9 @@ func (c *Client) httpDo(
 	for k, v := range c.Headers {
 		req.Header[k] = v
 	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
 
 	res, err := c.Client.Do(req)
 	if err != nil {
@@ -38,24 +55,8 @@ func (c *Client) httpDo(
 
 	c.logResponse(res)
 
-	if res.StatusCode > 299 {
-		//TODO: this is a hack. we need to revisit this at some point
-		//the commented code below should deserialize an object but currently
-		//the body contains just a string of the error message
-		/*
-			httpErr, err := goof.DecodeHTTPError(res.Body)
-			if err != nil {
-				return res, goof.WithField("status", res.StatusCode, "http error")
-			}
-		*/
-
-		buf, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return res, goof.WithField("status", res.StatusCode,
-				"Failed to retrieve HTTP body message")
-		}
-		result := string(buf)
-		return res, goof.WithField("status", res.StatusCode, result)
+	if res.StatusCode > 299 && !containsStatus(accept, res.StatusCode) {
+		return res, decError(res)
 	}
 
 	if req.Method != http.MethodHead && reply != nil {
@@ -95,6 +96,15 @@ func (c *Client) httpDelete(
 	return c.httpDo("DELETE", path, nil, reply)
 }
 
+func containsStatus(accept []int, status int) bool {
+	for _, s := range accept {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 func encPayload(payload interface{}) (io.Reader, error) {
 	if payload == nil {
 		return nil, nil
@@ -108,6 +118,27 @@ func encPayload(payload interface{}) (io.Reader, error) {
 	return bytes.NewReader(buf), nil
 }
 
+// decError decodes the error envelope of a failed request. Responses that
+// are not an envelope, such as those of a proxy, keep their body as the
+// message.
+func decError(res *http.Response) error {
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return goof.WithFieldE("status", res.StatusCode,
+			"Failed to retrieve HTTP body message", err)
+	}
+
+	e := &types.Error{}
+	if err := json.Unmarshal(buf, e); err != nil || e.Code == "" {
+		e = types.NewError(res.StatusCode, strings.TrimSpace(string(buf)))
+	}
+	e.Status = res.StatusCode
+	if e.RequestID == "" {
+		e.RequestID = res.Header.Get(types.RequestIDHeader)
+	}
+	return e
+}
+
 func decRes(body io.Reader, reply interface{}) error {
 	buf, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -1,57 +1,72 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/goof"
+	pcontext "github.com/emccode/polly/api/context"
 	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/health"
+	"github.com/emccode/polly/core/query"
 	"github.com/emccode/polly/core/version"
+	"github.com/emccode/polly/core/volumes"
 	"github.com/gorilla/mux"
 )
 
 func (rtr *Router) getVolumesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	log.Debug("getVolumesHandler")
-	vols, err := rtr.vsc.Volumes(r.URL.Query())
+	logger(r).Debug("getVolumesHandler")
+	l, err := rtr.vscFor(r).VolumesPage(r.URL.Query())
 	if err != nil {
-		http.Error(w, goof.WithError("problem getting volumes", err).Error(),
-			http.StatusInternalServerError)
+		writeError(w, r, "problem getting volumes", err)
 		return
 	}
 
-	j, _ := json.Marshal(&vols)
-	w.Write(j)
+	writeListing(w, l)
 }
 
 func (rtr *Router) getVolumesAllHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	log.Debug("getVolumesAllHandler")
-	vols, err := rtr.vsc.VolumesAll(r.URL.Query())
+	logger(r).Debug("getVolumesAllHandler")
+	l, err := rtr.vscFor(r).VolumesAllPage(r.URL.Query())
 	if err != nil {
-		http.Error(w, goof.WithError("problem getting volumes", err).Error(),
-			http.StatusInternalServerError)
+		writeError(w, r, "problem getting volumes", err)
 		return
 	}
 
-	j, _ := json.Marshal(&vols)
+	writeListing(w, l)
+}
+
+// writeListing writes a page of volumes along with the token of the next
+// page and the time the volumes were listed from libStorage
+func writeListing(w http.ResponseWriter, l *volumes.Listing) {
+	if l.Continue != "" {
+		w.Header().Set(types.ContinueHeader, l.Continue)
+	}
+	w.Header().Set(types.InventoryTimeHeader, l.Fetched.Format(time.RFC3339))
+
+	j, _ := json.Marshal(&l.Volumes)
 	w.Write(j)
 }
 
 func (rtr *Router) getVolumeInspectHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	log.Debug("getVolumeInspectHandler")
+	logger(r).Debug("getVolumeInspectHandler")
 	volumeID := mux.Vars(r)["volumeID"]
-	vol, err := rtr.vsc.VolumeInspect(volumeID)
+	vol, err := rtr.vscFor(r).VolumeInspect(volumeID)
 	if err != nil {
-		http.Error(w, goof.WithError("problem getting volumes", err).Error(),
-			http.StatusInternalServerError)
+		writeError(w, r, "problem getting volume", err)
 		return
 	}
 
@@ -66,19 +81,22 @@ func (rtr *Router) postVolumeOfferHandler(w http.ResponseWriter, r *http.Request
 	b, _ := ioutil.ReadAll(r.Body)
 	err := json.Unmarshal(b, &o)
 	if err != nil {
-		http.Error(w, "json is unparsable", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
 		return
 	}
 
 	// Process mandatory elements on create
 	if o.VolumeID == "" {
-		http.Error(w, "mandatory volumeID missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory volumeID missing or empty"))
 		return
 	}
 
-	vol, err := rtr.vsc.VolumeOffer(o.VolumeID, o.Schedulers)
+	vol, err := rtr.vscFor(r).VolumeOffer(o.VolumeID, o.Schedulers)
 	if err != nil {
-		http.Error(w, "problem performing volume offer", 422)
+		writeError(w, r, "problem performing volume offer", err)
+		return
 	}
 
 	j, _ := json.Marshal(vol)
@@ -92,19 +110,22 @@ func (rtr *Router) postVolumeOfferRevokeHandler(w http.ResponseWriter, r *http.R
 	b, _ := ioutil.ReadAll(r.Body)
 	err := json.Unmarshal(b, &o)
 	if err != nil {
-		http.Error(w, "json is unparsable", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
 		return
 	}
 
 	// Process mandatory elements on create
 	if o.VolumeID == "" {
-		http.Error(w, "mandatory volumeID missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory volumeID missing or empty"))
 		return
 	}
 
-	vol, err := rtr.vsc.VolumeOfferRevoke(o.VolumeID, o.Schedulers)
+	vol, err := rtr.vscFor(r).VolumeOfferRevoke(o.VolumeID, o.Schedulers)
 	if err != nil {
-		http.Error(w, "problem performing volume offer", 422)
+		writeError(w, r, "problem performing volume offer revoke", err)
+		return
 	}
 
 	j, _ := json.Marshal(vol)
@@ -118,19 +139,22 @@ func (rtr *Router) postVolumeLabelHandler(w http.ResponseWriter, r *http.Request
 	b, _ := ioutil.ReadAll(r.Body)
 	err := json.Unmarshal(b, &o)
 	if err != nil {
-		http.Error(w, "json is unparsable", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
 		return
 	}
 
 	// Process mandatory elements on create
 	if o.VolumeID == "" {
-		http.Error(w, "mandatory volumeID missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory volumeID missing or empty"))
 		return
 	}
 
-	vol, err := rtr.vsc.VolumeLabel(o.VolumeID, o.Labels)
+	vol, err := rtr.vscFor(r).VolumeLabel(o.VolumeID, o.Labels)
 	if err != nil {
-		http.Error(w, "problem performing volume offer", 422)
+		writeError(w, r, "problem performing volume label", err)
+		return
 	}
 
 	j, _ := json.Marshal(vol)
@@ -144,19 +168,22 @@ func (rtr *Router) postVolumeLabelsRemoveHandler(w http.ResponseWriter, r *http.
 	b, _ := ioutil.ReadAll(r.Body)
 	err := json.Unmarshal(b, &o)
 	if err != nil {
-		http.Error(w, "json is unparsable", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
 		return
 	}
 
 	// Process mandatory elements on create
 	if o.VolumeID == "" {
-		http.Error(w, "mandatory volumeID missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory volumeID missing or empty"))
 		return
 	}
 
-	vol, err := rtr.vsc.VolumeLabelsRemove(o.VolumeID, o.Labels)
+	vol, err := rtr.vscFor(r).VolumeLabelsRemove(o.VolumeID, o.Labels)
 	if err != nil {
-		http.Error(w, "problem performing volume offer", 422)
+		writeError(w, r, "problem performing volume labels remove", err)
+		return
 	}
 
 	j, _ := json.Marshal(vol)
@@ -170,30 +197,33 @@ func (rtr *Router) postVolumesHandler(w http.ResponseWriter, r *http.Request) {
 	b, _ := ioutil.ReadAll(r.Body)
 	err := json.Unmarshal(b, &m)
 	if err != nil {
-		http.Error(w, "json is unparsable", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
 		return
 	}
 
 	// Process mandatory elements on create
 	if m.ServiceName == "" {
-		http.Error(w, "mandatory ServiceName missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory ServiceName missing or empty"))
 		return
 	}
 
 	if _, ok := rtr.p.LsClient.Services[strings.ToLower(m.ServiceName)]; !ok {
-		http.Error(w, "ServiceName is not defined", http.StatusNotFound)
+		writeError(w, r, "", types.NewErrorf(http.StatusNotFound,
+			"ServiceName %q is not defined", m.ServiceName))
 		return
 	}
 
 	if m.Name == "" {
-		http.Error(w, "mandatory Name missing or empty", 422)
+		writeError(w, r, "",
+			types.NewError(422, "mandatory Name missing or empty"))
 		return
 	}
 
-	volNew, err := rtr.vsc.VolumeCreate(m)
+	volNew, err := rtr.vscFor(r).VolumeCreate(m)
 	if err != nil {
-		log.WithError(err).Error("volume creation failed")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, "volume creation failed", err)
 		return
 	}
 
@@ -202,6 +232,33 @@ func (rtr *Router) postVolumesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// postVolumesBulkHandler applies one operation to many volumes. A response
+// with failed volumes is a 207 so callers notice the partial failure.
+func (rtr *Router) postVolumesBulkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var m *types.VolumeBulkRequest
+	b, _ := ioutil.ReadAll(r.Body)
+	err := json.Unmarshal(b, &m)
+	if err != nil || m == nil {
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
+		return
+	}
+
+	res, err := rtr.vscFor(r).Bulk(m)
+	if err != nil {
+		writeError(w, r, "bulk volume operation failed", err)
+		return
+	}
+
+	if res.Failed > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	j, _ := json.Marshal(res)
+	w.Write(j)
+}
+
 func (rtr *Router) deleteVolumesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var ok bool
@@ -209,19 +266,21 @@ func (rtr *Router) deleteVolumesHandler(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 
 	if volid, ok = vars["volid"]; !ok {
-		http.Error(w, "volid missing", http.StatusBadRequest)
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "volid missing"))
 		return
 	}
 
 	vs := strings.SplitN(volid, "-", 2)
 	if len(vs) != 2 {
-		http.Error(w, "valid volid is service-volumeid", http.StatusBadRequest)
+		writeError(w, r, "", types.NewError(
+			http.StatusBadRequest, "valid volid is service-volumeid"))
 		return
 	}
 
-	err := rtr.vsc.VolumeRemove(volid)
+	err := rtr.vscFor(r).VolumeRemove(volid)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, "volume removal failed", err)
 		return
 	}
 
@@ -244,22 +303,172 @@ func (rtr *Router) getVersionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
-// notAllowedHandler is used to set a status code for unsupported operations
-func (rtr *Router) notAllowedHandler(allow ...string) func(w http.ResponseWriter, req *http.Request) {
-	return func(w http.ResponseWriter, req *http.Request) {
-		mesg := "Method Not Allowed. "
-		if len(allow) > 0 {
-			mesg += "Allow " + strings.Join(allow, ", ")
+// getConfigHandler returns the effective configuration with secrets redacted
+func (rtr *Router) getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	j, _ := json.Marshal(rtr.p.Reloader.Settings())
+	w.Write(j)
+}
+
+// postConfigReloadHandler reads the configuration files again and applies
+// the changes that do not require a restart
+func (rtr *Router) postConfigReloadHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	res, err := rtr.p.Reloader.Reload()
+	if err != nil {
+		if res != nil && len(res.Applied) > 0 {
+			// the keys applied before the failing handler stay in effect
+			err = types.NewError(http.StatusInternalServerError,
+				goof.WithError("config reload failed", err).Error()).
+				WithDetail("applied", res.Applied)
 		}
-		http.Error(w, mesg, http.StatusMethodNotAllowed)
+		writeError(w, r, "config reload failed", err)
 		return
 	}
+
+	j, _ := json.Marshal(res)
+	w.Write(j)
+}
+
+// getHealthHandler reports whether the daemon is alive
+func (rtr *Router) getHealthHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, health.Response(map[string]*types.HealthCheck{
+		"admin":  rtr.adminCheck(),
+		"leader": health.Leader(rtr.p),
+	}))
+}
+
+// getReadyHandler reports whether the daemon and its dependencies are ready
+func (rtr *Router) getReadyHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, health.Response(map[string]*types.HealthCheck{
+		"admin":      rtr.adminCheck(),
+		"store":      health.Store(rtr.p),
+		"libstorage": health.LibStorage(rtr.p),
+		"leader":     health.Leader(rtr.p),
+	}))
+}
+
+func writeHealth(w http.ResponseWriter, res *types.HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if res.Status != types.HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	j, _ := json.Marshal(res)
+	w.Write(j)
+}
+
+// notAllowedHandler is used to set a status code for unsupported operations
+func (rtr *Router) notAllowedHandler(allow ...string) http.Handler {
+	return methodNotAllowed(allow)
+}
+
+// methodNotAllowed answers the methods a route does not support, a type of
+// its own so the OpenAPI document can leave these routes out
+type methodNotAllowed []string
+
+func (allow methodNotAllowed) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	mesg := "Method Not Allowed. "
+	if len(allow) > 0 {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		mesg += "Allow " + strings.Join(allow, ", ")
+	}
+	writeError(w, req, "", types.NewError(http.StatusMethodNotAllowed, mesg))
 }
 
 // notImplementedHandler is used to set a status code for future operations
 func (rtr *Router) notImplementedHandler() func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		http.Error(w, "Not Implemented Yet", http.StatusNotImplemented)
+		writeError(w, req, "",
+			types.NewError(http.StatusNotImplemented, "Not Implemented Yet"))
 		return
 	}
 }
+
+// notFoundHandler is used for the paths that match no route
+func (rtr *Router) notFoundHandler(w http.ResponseWriter, req *http.Request) {
+	writeError(w, req, "", types.NewErrorf(
+		http.StatusNotFound, "no route for %s %s", req.Method, req.URL.Path))
+}
+
+// writeError writes the error envelope of a failed request. Admin API errors
+// keep their status, filter and listing parameter errors are rejected with a
+// 400 and any other error is reported as a 500 prefixed by mesg.
+func writeError(w http.ResponseWriter, r *http.Request, mesg string, err error) {
+	var e *types.Error
+	switch te := err.(type) {
+	case *types.Error:
+		e = te
+	case *query.Error:
+		param := te.Param
+		if param == "" {
+			param = "filter"
+		}
+		e = types.NewError(http.StatusBadRequest, te.Error()).
+			WithDetail("param", param).
+			WithDetail("value", te.Expr).
+			WithDetail("reason", te.Reason)
+	default:
+		if mesg != "" {
+			err = goof.WithError(mesg, err)
+		}
+		e = types.NewError(http.StatusInternalServerError, err.Error())
+	}
+
+	// a copy is written so the request ID is not set on a shared error
+	res := *e
+	res.RequestID = requestID(w, r)
+
+	fields := log.Fields{
+		"status":    res.Status,
+		"code":      res.Code,
+		"requestID": res.RequestID,
+		"path":      r.URL.Path,
+	}
+	if res.Status >= http.StatusInternalServerError {
+		log.WithFields(fields).WithError(err).Error("admin request failed")
+	} else {
+		log.WithFields(fields).WithError(err).Debug("admin request rejected")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(res.Status)
+	j, _ := json.Marshal(&res)
+	w.Write(j)
+}
+
+// validRequestID matches the request IDs accepted from callers, others are
+// replaced so they cannot be used to forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID returns the identifier of the request, generating one when the
+// caller did not send a valid one, and echoes it on the response
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := pcontext.RequestID(r.Context())
+	if id == "" {
+		id = r.Header.Get(types.RequestIDHeader)
+	}
+	if !validRequestID.MatchString(id) {
+		id = w.Header().Get(types.RequestIDHeader)
+	}
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(types.RequestIDHeader, id)
+	return id
+}
+
+// vscFor returns the volume service bound to the context of the request
+func (rtr *Router) vscFor(r *http.Request) *volumes.Vsc {
+	return rtr.vsc.WithContext(r.Context())
+}
+
+// logger returns a log entry with the ID of the request
+func logger(r *http.Request) *log.Entry {
+	return log.WithField("requestID", pcontext.RequestID(r.Context()))
+}
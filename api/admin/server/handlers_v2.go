@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+// mergePatchType is the media type of a JSON merge patch
+const mergePatchType = "application/merge-patch+json"
+
+// getVolumesV2Handler lists the registered volumes, or all of them when the
+// all parameter is set
+func (rtr *Router) getVolumesV2Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger(r).Debug("getVolumesV2Handler")
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	vsc := rtr.vscFor(r)
+	list := vsc.VolumesPage
+	if all {
+		list = vsc.VolumesAllPage
+	}
+	l, err := list(r.URL.Query())
+	if err != nil {
+		writeError(w, r, "problem getting volumes", err)
+		return
+	}
+
+	writeListing(w, l)
+}
+
+func (rtr *Router) getVolumeV2Handler(w http.ResponseWriter, r *http.Request) {
+	vol, err := rtr.vscFor(r).VolumeInspect(mux.Vars(r)["volumeID"])
+	if err != nil {
+		writeError(w, r, "problem getting volume", err)
+		return
+	}
+	writeVolume(w, vol, vol)
+}
+
+// patchVolumeV2Handler applies a JSON merge patch to the labels and
+// schedulers of a volume
+func (rtr *Router) patchVolumeV2Handler(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, _ := mime.ParseMediaType(ct)
+		if mt != mergePatchType && mt != "application/json" {
+			w.Header().Set("Accept-Patch", mergePatchType)
+			writeError(w, r, "", types.NewErrorf(http.StatusUnsupportedMediaType,
+				"unsupported patch media type %q, use %s", mt, mergePatchType))
+			return
+		}
+	}
+
+	b, _ := ioutil.ReadAll(r.Body)
+	vol, err := rtr.vscFor(r).VolumePatch(
+		mux.Vars(r)["volumeID"], r.Header.Get("If-Match"), b)
+	if err != nil {
+		writeError(w, r, "problem patching volume", err)
+		return
+	}
+	writeVolume(w, vol, vol)
+}
+
+func (rtr *Router) deleteVolumeV2Handler(w http.ResponseWriter, r *http.Request) {
+	err := rtr.vscFor(r).VolumeRemoveMatch(
+		mux.Vars(r)["volumeID"], r.Header.Get("If-Match"))
+	if err != nil {
+		writeError(w, r, "volume removal failed", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rtr *Router) getVolumeLabelsV2Handler(w http.ResponseWriter, r *http.Request) {
+	vol, err := rtr.vscFor(r).VolumeInspect(mux.Vars(r)["volumeID"])
+	if err != nil {
+		writeError(w, r, "problem getting volume", err)
+		return
+	}
+	labels := vol.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	writeVolume(w, vol, labels)
+}
+
+// putVolumeLabelsV2Handler replaces the labels of a volume
+func (rtr *Router) putVolumeLabelsV2Handler(w http.ResponseWriter, r *http.Request) {
+	var labels map[string]string
+	if !readJSON(w, r, &labels) {
+		return
+	}
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		vol.Labels = labels
+		return nil
+	})
+}
+
+func (rtr *Router) deleteVolumeLabelsV2Handler(w http.ResponseWriter, r *http.Request) {
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		vol.Labels = nil
+		return nil
+	})
+}
+
+// putVolumeLabelV2Handler sets a single label from a JSON string
+func (rtr *Router) putVolumeLabelV2Handler(w http.ResponseWriter, r *http.Request) {
+	var value string
+	if !readJSON(w, r, &value) {
+		return
+	}
+	key := mux.Vars(r)["key"]
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		if vol.Labels == nil {
+			vol.Labels = map[string]string{}
+		}
+		vol.Labels[key] = value
+		return nil
+	})
+}
+
+func (rtr *Router) deleteVolumeLabelV2Handler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		if _, ok := vol.Labels[key]; !ok {
+			return types.NewErrorf(http.StatusNotFound,
+				"volume %q has no label %q", vol.VolumeID, key).
+				WithDetail("label", key)
+		}
+		delete(vol.Labels, key)
+		return nil
+	})
+}
+
+func (rtr *Router) getVolumeSchedulersV2Handler(w http.ResponseWriter, r *http.Request) {
+	vol, err := rtr.vscFor(r).VolumeInspect(mux.Vars(r)["volumeID"])
+	if err != nil {
+		writeError(w, r, "problem getting volume", err)
+		return
+	}
+	schedulers := vol.Schedulers
+	if schedulers == nil {
+		schedulers = []string{}
+	}
+	writeVolume(w, vol, schedulers)
+}
+
+// putVolumeSchedulersV2Handler replaces the schedulers a volume is offered to
+func (rtr *Router) putVolumeSchedulersV2Handler(w http.ResponseWriter, r *http.Request) {
+	var schedulers []string
+	if !readJSON(w, r, &schedulers) {
+		return
+	}
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		vol.Schedulers = volumes.NonEmpty(schedulers)
+		return nil
+	})
+}
+
+func (rtr *Router) deleteVolumeSchedulersV2Handler(w http.ResponseWriter, r *http.Request) {
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		vol.Schedulers = nil
+		return nil
+	})
+}
+
+// putVolumeSchedulerV2Handler offers a volume to one more scheduler
+func (rtr *Router) putVolumeSchedulerV2Handler(w http.ResponseWriter, r *http.Request) {
+	scheduler := mux.Vars(r)["scheduler"]
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		for _, s := range vol.Schedulers {
+			if s == scheduler {
+				return nil
+			}
+		}
+		vol.Schedulers = append(vol.Schedulers, scheduler)
+		return nil
+	})
+}
+
+// deleteVolumeSchedulerV2Handler revokes the offer of a volume to a scheduler
+func (rtr *Router) deleteVolumeSchedulerV2Handler(w http.ResponseWriter, r *http.Request) {
+	scheduler := mux.Vars(r)["scheduler"]
+	rtr.updateVolume(w, r, func(vol *types.Volume) error {
+		for i, s := range vol.Schedulers {
+			if s == scheduler {
+				vol.Schedulers = append(vol.Schedulers[:i], vol.Schedulers[i+1:]...)
+				return nil
+			}
+		}
+		return types.NewErrorf(http.StatusNotFound,
+			"volume %q is not offered to %q", vol.VolumeID, scheduler).
+			WithDetail("scheduler", scheduler)
+	})
+}
+
+// updateVolume applies a change to the volume of the request, honoring its
+// If-Match header, and writes the updated volume
+func (rtr *Router) updateVolume(
+	w http.ResponseWriter, r *http.Request, change func(vol *types.Volume) error) {
+
+	vol, err := rtr.vscFor(r).VolumeUpdate(
+		mux.Vars(r)["volumeID"], r.Header.Get("If-Match"), change)
+	if err != nil {
+		writeError(w, r, "problem updating volume", err)
+		return
+	}
+	writeVolume(w, vol, vol)
+}
+
+// readJSON decodes the body of the request, writing an error when it is
+// unparsable
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	b, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(b, v); err != nil {
+		writeError(w, r, "",
+			types.NewError(http.StatusBadRequest, "json is unparsable"))
+		return false
+	}
+	return true
+}
+
+// writeVolume writes a volume or one of its sub-resources along with the
+// entity tag of the volume metadata
+func writeVolume(w http.ResponseWriter, vol *types.Volume, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", volumes.ETag(vol))
+
+	j, _ := json.Marshal(v)
+	w.Write(j)
+}
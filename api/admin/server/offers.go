@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// providerID is the ID of Polly as a Mesos resource provider
+	providerID = "polly"
+
+	// recordIOType is the media type of an offer feed, records of JSON
+	// events each prefixed by its length and a new line
+	recordIOType = "application/recordio"
+)
+
+// getOffersHandler lists the volume offers of a scheduler to a framework,
+// or streams them as Mesos scheduler events when the stream parameter is set
+func (rtr *Router) getOffersHandler(w http.ResponseWriter, r *http.Request) {
+	logger(r).Debug("getOffersHandler")
+	scheduler := r.URL.Query().Get("scheduler")
+	frameworkID := r.URL.Query().Get("frameworkID")
+	if scheduler == "" {
+		writeError(w, r, "", types.NewError(http.StatusBadRequest,
+			"scheduler is required"))
+		return
+	}
+
+	vsc := rtr.vscFor(r)
+	offers, err := vsc.Offers(scheduler, frameworkID)
+	if err != nil {
+		writeError(w, r, "problem getting offers", err)
+		return
+	}
+
+	if stream, _ := strconv.ParseBool(r.URL.Query().Get("stream")); !stream {
+		mos := []*types.MesosOffer{}
+		for _, o := range offers {
+			mos = append(mos, mesosOffer(o, scheduler, frameworkID))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		j, _ := json.Marshal(mos)
+		w.Write(j)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, "", types.NewError(http.StatusNotImplemented,
+			"offer feeds are not supported by this listener"))
+		return
+	}
+	w.Header().Set("Content-Type", recordIOType)
+	w.Header().Set("Message-Content-Type", "application/json")
+
+	ticker := time.NewTicker(volumes.OfferInterval(rtr.p.Config))
+	defer ticker.Stop()
+
+	var events []*types.MesosEvent
+	sent := map[string]bool{}
+	for {
+		events, sent = feedEvents(sent, offers, scheduler, frameworkID)
+		if len(events) == 0 {
+			events = []*types.MesosEvent{{Type: types.MesosEventHeartbeat}}
+		}
+		for _, e := range events {
+			if err := writeRecord(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-rtr.done:
+			return
+		}
+
+		next, err := vsc.Offers(scheduler, frameworkID)
+		if err != nil {
+			logger(r).WithError(err).Warn("problem refreshing offer feed")
+			continue
+		}
+		offers = next
+	}
+}
+
+// feedEvents returns the events of a feed that sent the offers in sent:
+// an OFFERS event with the new offers and a RESCIND event for each offer sent
+// but no longer valid. It returns the offers sent once the events are.
+func feedEvents(
+	sent map[string]bool,
+	offers []*volumes.Offer,
+	scheduler, frameworkID string) ([]*types.MesosEvent, map[string]bool) {
+
+	var events []*types.MesosEvent
+	next := map[string]bool{}
+	added := &types.MesosOffers{}
+	for _, o := range offers {
+		next[o.ID] = true
+		if !sent[o.ID] {
+			added.Offers = append(added.Offers, mesosOffer(o, scheduler, frameworkID))
+		}
+	}
+	if len(added.Offers) > 0 {
+		events = append(events, &types.MesosEvent{
+			Type:   types.MesosEventOffers,
+			Offers: added,
+		})
+	}
+
+	var rescinded []string
+	for id := range sent {
+		if !next[id] {
+			rescinded = append(rescinded, id)
+		}
+	}
+	sort.Strings(rescinded)
+	for _, id := range rescinded {
+		events = append(events, &types.MesosEvent{
+			Type:    types.MesosEventRescind,
+			Rescind: &types.MesosRescind{OfferID: &types.MesosValue{Value: id}},
+		})
+	}
+	return events, next
+}
+
+// mesosOffer returns an offer as a disk resource reserved for the role of
+// the scheduler and labelled with the admin labels of the volume
+func mesosOffer(o *volumes.Offer, scheduler, frameworkID string) *types.MesosOffer {
+	vol := o.Volume
+	res := &types.MesosResource{
+		ProviderID: &types.MesosValue{Value: providerID},
+		Name:       "disk",
+		Type:       "SCALAR",
+		Scalar:     &types.MesosScalar{Value: float64(vol.Size * 1024)},
+		Reservations: []*types.MesosReservation{
+			{Type: "DYNAMIC", Role: scheduler},
+		},
+		Disk: &types.MesosDisk{Source: &types.MesosDiskSource{
+			Type:    "MOUNT",
+			ID:      vol.VolumeID,
+			Vendor:  providerID,
+			Profile: vol.ServiceName,
+		}},
+	}
+
+	if len(vol.Labels) > 0 {
+		keys := make([]string, 0, len(vol.Labels))
+		for k := range vol.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		res.Labels = &types.MesosLabels{}
+		for _, k := range keys {
+			res.Labels.Labels = append(res.Labels.Labels,
+				&types.MesosLabel{Key: k, Value: vol.Labels[k]})
+		}
+	}
+
+	mo := &types.MesosOffer{
+		ID:        &types.MesosValue{Value: o.ID},
+		Resources: []*types.MesosResource{res},
+	}
+	if frameworkID != "" {
+		mo.FrameworkID = &types.MesosValue{Value: frameworkID}
+	}
+	return mo
+}
+
+// writeRecord writes an event as a RecordIO record
+func writeRecord(w http.ResponseWriter, e *types.MesosEvent) error {
+	j, _ := json.Marshal(e)
+	if _, err := fmt.Fprintf(w, "%d\n", len(j)); err != nil {
+		return err
+	}
+	_, err := w.Write(j)
+	return err
+}
+
+// postOfferAcceptHandler leases the volume of an offer to a framework
+func (rtr *Router) postOfferAcceptHandler(w http.ResponseWriter, r *http.Request) {
+	req := &types.OfferAcceptRequest{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	lease, err := rtr.vscFor(r).OfferAccept(mux.Vars(r)["offerID"], req)
+	if err != nil {
+		writeError(w, r, "problem accepting offer", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	j, _ := json.Marshal(lease)
+	w.Write(j)
+}
+
+func (rtr *Router) postOfferDeclineHandler(w http.ResponseWriter, r *http.Request) {
+	req := &types.OfferDeclineRequest{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	if err := rtr.vscFor(r).OfferDecline(mux.Vars(r)["offerID"], req); err != nil {
+		writeError(w, r, "problem declining offer", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rtr *Router) getLeasesHandler(w http.ResponseWriter, r *http.Request) {
+	leases, err := rtr.vscFor(r).Leases()
+	if err != nil {
+		writeError(w, r, "problem getting leases", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	j, _ := json.Marshal(leases)
+	w.Write(j)
+}
+
+// deleteLeaseHandler releases the lease of a volume, only when held by the
+// framework of the frameworkID parameter if set
+func (rtr *Router) deleteLeaseHandler(w http.ResponseWriter, r *http.Request) {
+	err := rtr.vscFor(r).LeaseRelease(
+		mux.Vars(r)["volumeID"], r.URL.Query().Get("frameworkID"))
+	if err != nil {
+		writeError(w, r, "problem releasing lease", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMesosOffer(t *testing.T) {
+	o := &volumes.Offer{ID: "o1", Volume: &types.Volume{
+		Volume:      &lstypes.Volume{ID: "vol-1", Size: 2},
+		VolumeID:    "ebs-vol-1",
+		ServiceName: "ebs",
+		Labels:      map[string]string{"zone": "a", "tier": "gold"},
+	}}
+
+	mo := mesosOffer(o, "mesos0", "fw1")
+	assert.Equal(t, "o1", mo.ID.Value)
+	assert.Equal(t, "fw1", mo.FrameworkID.Value)
+	if !assert.Len(t, mo.Resources, 1) {
+		return
+	}
+	res := mo.Resources[0]
+	assert.Equal(t, "disk", res.Name)
+	assert.EqualValues(t, 2048, res.Scalar.Value)
+	assert.Equal(t, "mesos0", res.Reservations[0].Role)
+	assert.Equal(t, "ebs-vol-1", res.Disk.Source.ID)
+	assert.Equal(t, "ebs", res.Disk.Source.Profile)
+	assert.Equal(t, "tier", res.Labels.Labels[0].Key)
+	assert.Equal(t, "zone", res.Labels.Labels[1].Key)
+
+	assert.Nil(t, mesosOffer(o, "mesos0", "").FrameworkID)
+}
+
+func TestFeedEvents(t *testing.T) {
+	offer := func(id string) *volumes.Offer {
+		return &volumes.Offer{ID: id, Volume: &types.Volume{
+			Volume: &lstypes.Volume{}, VolumeID: id}}
+	}
+
+	events, sent := feedEvents(map[string]bool{},
+		[]*volumes.Offer{offer("o1"), offer("o2")}, "mesos0", "fw1")
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, types.MesosEventOffers, events[0].Type)
+		assert.Len(t, events[0].Offers.Offers, 2)
+	}
+
+	events, _ = feedEvents(sent, []*volumes.Offer{offer("o2")}, "mesos0", "fw1")
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, types.MesosEventRescind, events[0].Type)
+		assert.Equal(t, "o1", events[0].Rescind.OfferID.Value)
+	}
+
+	events, _ = feedEvents(sent,
+		[]*volumes.Offer{offer("o1"), offer("o2")}, "mesos0", "fw1")
+	assert.Empty(t, events)
+}
+
+func TestWriteRecord(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.NoError(t, writeRecord(w, &types.MesosEvent{Type: types.MesosEventHeartbeat}))
+	assert.Equal(t, "20\n{\"type\":\"HEARTBEAT\"}", w.Body.String())
+}
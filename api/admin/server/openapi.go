@@ -0,0 +1,657 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/version"
+	"github.com/gorilla/mux"
+)
+
+// operation documents a route of the admin API
+type operation struct {
+	summary string
+
+	// query are the names of the query parameters, described in queryParams
+	query []string
+
+	// request is a value of the request body type, nil without a body
+	request     interface{}
+	requestType string
+
+	// response is a value of the response body type, nil without a body
+	response     interface{}
+	responseType string
+
+	// statuses are the success statuses, 200 when empty
+	statuses []int
+
+	// headers are the names of the response headers, described in
+	// responseHeaders
+	headers []string
+}
+
+var (
+	listParams = []string{"filter", "sort", "limit", "continue", "fresh"}
+	listing    = []string{types.ContinueHeader, types.InventoryTimeHeader}
+	etagHeader = []string{"ETag"}
+	noContent  = []int{http.StatusNoContent}
+	volume     = &types.Volume{}
+)
+
+// operations document the routes of the admin API keyed by method and path
+// template. Every route but those answering unsupported methods must be
+// documented, which the tests enforce.
+var operations = map[string]operation{
+	"GET /admin/version": {
+		summary:  "Get the version of the Polly daemon",
+		response: &types.VersionResponse{},
+	},
+	"GET /admin/volumes": {
+		summary:  "List the volumes managed by Polly",
+		query:    listParams,
+		response: []*types.Volume{},
+		headers:  listing,
+	},
+	"POST /admin/volumes": {
+		summary:  "Create a volume",
+		request:  &types.VolumeCreateRequest{},
+		response: volume,
+	},
+	"GET /admin/volumesall": {
+		summary:  "List all the volumes, managed or not",
+		query:    listParams,
+		response: []*types.Volume{},
+		headers:  listing,
+	},
+	"GET /admin/volumes/{volumeID}": {
+		summary:  "Get a volume",
+		response: volume,
+	},
+	"DELETE /admin/volumes/{volid}": {
+		summary:  "Remove a volume",
+		statuses: noContent,
+	},
+	"POST /admin/volumesbulk": {
+		summary:  "Apply an operation to many volumes",
+		request:  &types.VolumeBulkRequest{},
+		response: &types.VolumeBulkResponse{},
+		statuses: []int{http.StatusOK, http.StatusMultiStatus},
+	},
+	"POST /admin/volumeoffer": {
+		summary:  "Offer a volume to schedulers",
+		request:  &types.VolumeOfferRequest{},
+		response: volume,
+	},
+	"POST /admin/volumeofferrevoke": {
+		summary:  "Revoke the offer of a volume to schedulers",
+		request:  &types.VolumeOfferRevokeRequest{},
+		response: volume,
+	},
+	"POST /admin/volumelabel": {
+		summary:  "Create labels on a volume",
+		request:  &types.VolumeLabelRequest{},
+		response: volume,
+	},
+	"POST /admin/volumelabelsremove": {
+		summary:  "Remove labels from a volume",
+		request:  &types.VolumeLabelsRemoveRequest{},
+		response: volume,
+	},
+
+	"GET /admin/v2/volumes": {
+		summary:  "List the volumes",
+		query:    append([]string{"all"}, listParams...),
+		response: []*types.Volume{},
+		headers:  listing,
+	},
+	"POST /admin/v2/volumes": {
+		summary:  "Create a volume",
+		request:  &types.VolumeCreateRequest{},
+		response: volume,
+	},
+	"GET /admin/v2/volumes/{volumeID}": {
+		summary:  "Get a volume",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"PATCH /admin/v2/volumes/{volumeID}": {
+		summary:     "Patch the labels and schedulers of a volume",
+		request:     &types.VolumeMergePatch{},
+		requestType: mergePatchType,
+		response:    volume,
+		headers:     etagHeader,
+	},
+	"DELETE /admin/v2/volumes/{volumeID}": {
+		summary:  "Remove a volume",
+		statuses: noContent,
+	},
+	"GET /admin/v2/volumes/{volumeID}/labels": {
+		summary:  "Get the labels of a volume",
+		response: map[string]string{},
+		headers:  etagHeader,
+	},
+	"PUT /admin/v2/volumes/{volumeID}/labels": {
+		summary:  "Replace the labels of a volume",
+		request:  map[string]string{},
+		response: volume,
+		headers:  etagHeader,
+	},
+	"DELETE /admin/v2/volumes/{volumeID}/labels": {
+		summary:  "Remove the labels of a volume",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"PUT /admin/v2/volumes/{volumeID}/labels/{key}": {
+		summary:  "Set a label of a volume",
+		request:  "",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"DELETE /admin/v2/volumes/{volumeID}/labels/{key}": {
+		summary:  "Remove a label of a volume",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"GET /admin/v2/volumes/{volumeID}/schedulers": {
+		summary:  "Get the schedulers a volume is offered to",
+		response: []string{},
+		headers:  etagHeader,
+	},
+	"PUT /admin/v2/volumes/{volumeID}/schedulers": {
+		summary:  "Replace the schedulers a volume is offered to",
+		request:  []string{},
+		response: volume,
+		headers:  etagHeader,
+	},
+	"DELETE /admin/v2/volumes/{volumeID}/schedulers": {
+		summary:  "Revoke the offers of a volume",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"PUT /admin/v2/volumes/{volumeID}/schedulers/{scheduler}": {
+		summary:  "Offer a volume to a scheduler",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"DELETE /admin/v2/volumes/{volumeID}/schedulers/{scheduler}": {
+		summary:  "Revoke the offer of a volume to a scheduler",
+		response: volume,
+		headers:  etagHeader,
+	},
+	"GET /admin/v2/offers": {
+		summary:  "List or stream the Mesos offers of the volumes of a scheduler",
+		query:    []string{"scheduler", "frameworkID", "stream"},
+		response: []*types.MesosOffer{},
+	},
+	"POST /admin/v2/offers/{offerID}/accept": {
+		summary:  "Lease the volume of an offer to a framework",
+		request:  &types.OfferAcceptRequest{},
+		response: &types.Lease{},
+	},
+	"POST /admin/v2/offers/{offerID}/decline": {
+		summary:  "Decline an offer for a while",
+		request:  &types.OfferDeclineRequest{},
+		statuses: noContent,
+	},
+	"GET /admin/v2/leases": {
+		summary:  "List the volume leases",
+		response: []*types.Lease{},
+	},
+	"DELETE /admin/v2/leases/{volumeID}": {
+		summary:  "Release the lease of a volume",
+		query:    []string{"frameworkID"},
+		statuses: noContent,
+	},
+
+	"GET /admin/webhooks": {
+		summary:  "List the webhooks, without their secrets",
+		response: []*types.Webhook{},
+	},
+	"POST /admin/webhooks": {
+		summary:  "Register a webhook called on volume lifecycle events",
+		request:  &types.WebhookCreateRequest{},
+		response: &types.Webhook{},
+		statuses: []int{http.StatusCreated},
+	},
+	"GET /admin/webhooks/{webhookID}": {
+		summary:  "Get a webhook, without its secret",
+		response: &types.Webhook{},
+	},
+	"DELETE /admin/webhooks/{webhookID}": {
+		summary:  "Remove a webhook",
+		statuses: noContent,
+	},
+	"GET /admin/webhooks/{webhookID}/deliveries": {
+		summary:  "List the last deliveries to a webhook",
+		response: []*types.WebhookDelivery{},
+	},
+
+	"GET /admin/tenants": {
+		summary:  "List the tenants",
+		response: []*types.Tenant{},
+	},
+	"POST /admin/tenants": {
+		summary:  "Create a tenant and register its schedulers",
+		request:  &types.Tenant{},
+		response: &types.Tenant{},
+		statuses: []int{http.StatusCreated},
+	},
+	"GET /admin/tenants/{tenant}": {
+		summary:  "Get a tenant",
+		response: &types.Tenant{},
+	},
+	"PUT /admin/tenants/{tenant}": {
+		summary:  "Replace the description and the schedulers of a tenant",
+		request:  &types.Tenant{},
+		response: &types.Tenant{},
+	},
+	"DELETE /admin/tenants/{tenant}": {
+		summary:  "Remove a tenant owning no volume, along with its tokens",
+		statuses: noContent,
+	},
+	"GET /admin/tenants/{tenant}/tokens": {
+		summary:  "List the tokens of a tenant, without their values",
+		response: []*types.TenantToken{},
+	},
+	"POST /admin/tenants/{tenant}/tokens": {
+		summary:  "Create a bearer token restricted to a tenant",
+		response: &types.TenantToken{},
+		statuses: []int{http.StatusCreated},
+	},
+	"DELETE /admin/tenants/{tenant}/tokens/{tokenID}": {
+		summary:  "Remove a token of a tenant",
+		statuses: noContent,
+	},
+
+	"GET /admin/schedulers": {
+		summary:  "List the registered schedulers",
+		response: []*types.Scheduler{},
+	},
+	"POST /admin/schedulers": {
+		summary:  "Register a scheduler, along with its tenant",
+		request:  &types.Scheduler{},
+		response: &types.Scheduler{},
+		statuses: []int{http.StatusCreated},
+	},
+	"GET /admin/schedulers/{scheduler}": {
+		summary:  "Get a registered scheduler",
+		response: &types.Scheduler{},
+	},
+	"PUT /admin/schedulers/{scheduler}": {
+		summary:  "Replace the type, contact and tenant of a scheduler",
+		request:  &types.Scheduler{},
+		response: &types.Scheduler{},
+	},
+	"DELETE /admin/schedulers/{scheduler}": {
+		summary:  "Unregister a scheduler, the volumes stay offered to it",
+		statuses: noContent,
+	},
+	"POST /admin/schedulers/{scheduler}/heartbeat": {
+		summary:  "Record that a scheduler is alive",
+		response: &types.Scheduler{},
+	},
+	"GET /admin/schedulers/{scheduler}/volumes": {
+		summary:  "List the volumes offered to a scheduler",
+		query:    listParams,
+		response: []*types.Volume{},
+		headers:  listing,
+	},
+
+	"GET /admin/config": {
+		summary:  "Get the effective configuration, secrets redacted",
+		response: map[string]interface{}{},
+	},
+	"POST /admin/config/reload": {
+		summary:  "Read the configuration files again",
+		response: &types.ConfigReloadResponse{},
+	},
+	"GET /admin/openapi.json": {
+		summary:  "Get this document",
+		response: map[string]interface{}{},
+	},
+	"GET /metrics": {
+		summary:      "Get the Prometheus metrics",
+		response:     "",
+		responseType: "text/plain",
+	},
+	"GET /healthz": {
+		summary:  "Report whether the daemon is alive",
+		response: &types.HealthResponse{},
+		statuses: []int{http.StatusOK, http.StatusServiceUnavailable},
+	},
+	"GET /readyz": {
+		summary:  "Report whether the daemon and its dependencies are ready",
+		response: &types.HealthResponse{},
+		statuses: []int{http.StatusOK, http.StatusServiceUnavailable},
+	},
+}
+
+// queryParams describe the query parameters of the operations
+var queryParams = map[string]struct {
+	typ, description string
+}{
+	"all":      {"boolean", "Include the volumes not managed by Polly"},
+	"filter":   {"string", "Filter expression selecting the volumes"},
+	"sort":     {"string", "Sort key, descending when prefixed by -"},
+	"limit":    {"integer", "Maximum number of volumes of the page"},
+	"continue": {"string", "Token of the page to return"},
+	"fresh":    {"boolean", "List the volumes from libStorage rather than the cache"},
+
+	"scheduler":   {"string", "Scheduler the volumes are offered to"},
+	"frameworkID": {"string", "Mesos framework of the offers or lease"},
+	"stream":      {"boolean", "Stream the offers as RecordIO Mesos events"},
+}
+
+// responseHeaders describe the response headers of the operations
+var responseHeaders = map[string]string{
+	types.ContinueHeader:      "Token of the next page, missing on the last page",
+	types.InventoryTimeHeader: "Time the volumes were listed from libStorage",
+	"ETag":                    "Entity tag of the labels, schedulers and tenant of the volume",
+}
+
+var pathParamRx = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// getOpenAPIHandler serves the OpenAPI document of the admin API
+func (rtr *Router) getOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec, undocumented := rtr.openAPI()
+	if len(undocumented) > 0 {
+		log.WithField("routes", undocumented).Warn("undocumented admin routes")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	j, _ := json.MarshalIndent(spec, "", "  ")
+	w.Write(j)
+}
+
+// openAPI generates the OpenAPI 3 document of the registered routes along
+// with the routes missing from operations
+func (rtr *Router) openAPI() (map[string]interface{}, []string) {
+	g := &schemaGen{
+		names:   map[reflect.Type]string{},
+		schemas: map[string]interface{}{},
+	}
+	paths := map[string]map[string]interface{}{}
+	var undocumented []string
+
+	rtr.r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if _, ok := route.GetHandler().(methodNotAllowed); ok {
+			return nil
+		}
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			o, ok := operations[m+" "+tpl]
+			if !ok {
+				undocumented = append(undocumented, m+" "+tpl)
+				o = operation{summary: "Undocumented"}
+			}
+			if paths[tpl] == nil {
+				paths[tpl] = map[string]interface{}{}
+			}
+			paths[tpl][strings.ToLower(m)] = g.operation(m, tpl, &o)
+		}
+		return nil
+	})
+
+	g.schema(reflect.TypeOf(types.Error{}))
+	sort.Strings(undocumented)
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "Polly admin API",
+			"version": version.VersionStr,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+		// the token is optional unless polly.admin.token is set
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{},
+		},
+	}, undocumented
+}
+
+// schemaGen generates the schemas of the request and response types,
+// registering the named structs as components
+type schemaGen struct {
+	names   map[reflect.Type]string
+	schemas map[string]interface{}
+}
+
+func (g *schemaGen) operation(method, tpl string, o *operation) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(method, tpl),
+		"summary":     o.summary,
+	}
+
+	var params []interface{}
+	for _, m := range pathParamRx.FindAllStringSubmatch(tpl, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range o.query {
+		params = append(params, map[string]interface{}{
+			"name":        q,
+			"in":          "query",
+			"description": queryParams[q].description,
+			"schema":      map[string]interface{}{"type": queryParams[q].typ},
+		})
+	}
+	if strings.HasPrefix(tpl, "/admin/v2/volumes/") &&
+		method != http.MethodGet {
+		params = append(params, map[string]interface{}{
+			"name":        "If-Match",
+			"in":          "header",
+			"description": "ETag the volume must still have for the change to apply",
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	if o.request != nil {
+		ct := o.requestType
+		if ct == "" {
+			ct = "application/json"
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				ct: map[string]interface{}{
+					"schema": g.schema(reflect.TypeOf(o.request)),
+				},
+			},
+		}
+	}
+
+	statuses := o.statuses
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusOK}
+	}
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": g.schema(reflect.TypeOf(types.Error{})),
+				},
+			},
+		},
+	}
+	for _, s := range statuses {
+		res := map[string]interface{}{"description": http.StatusText(s)}
+		if o.response != nil && s != http.StatusNoContent {
+			ct := o.responseType
+			if ct == "" {
+				ct = "application/json"
+			}
+			res["content"] = map[string]interface{}{
+				ct: map[string]interface{}{
+					"schema": g.schema(reflect.TypeOf(o.response)),
+				},
+			}
+		}
+		if len(o.headers) > 0 {
+			headers := map[string]interface{}{}
+			for _, h := range o.headers {
+				headers[h] = map[string]interface{}{
+					"description": responseHeaders[h],
+					"schema":      map[string]interface{}{"type": "string"},
+				}
+			}
+			res["headers"] = headers
+		}
+		responses[fmt.Sprintf("%d", s)] = res
+	}
+	op["responses"] = responses
+	return op
+}
+
+// operationID derives the identifier of an operation from its method and
+// path, for example getAdminV2VolumesVolumeIDLabels
+func operationID(method, tpl string) string {
+	id := strings.ToLower(method)
+	for _, seg := range strings.Split(tpl, "/") {
+		seg = strings.Trim(pathParamRx.ReplaceAllString(seg, "$1"), "{}")
+		seg = strings.Replace(seg, ".", "", -1)
+		if seg == "" {
+			continue
+		}
+		id += strings.ToUpper(seg[:1]) + seg[1:]
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the JSON schema of a type, a reference for named structs
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": g.schema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": g.schema(t.Elem()),
+		}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return map[string]interface{}{}
+}
+
+// structRef registers a struct as a component and returns its reference
+func (g *schemaGen) structRef(t reflect.Type) map[string]interface{} {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.schemas[name]; taken || name == "" {
+			name = strings.Replace(strings.Title(
+				strings.Replace(t.PkgPath(), "/", " ", -1)), " ", "", -1) + t.Name()
+		}
+		g.names[t] = name
+		// registered before the fields for recursive types
+		g.schemas[name] = nil
+
+		props := map[string]interface{}{}
+		var required []string
+		g.fields(t, props, &required)
+		s := map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			s["required"] = required
+		}
+		g.schemas[name] = s
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// fields adds the JSON properties of a struct, those of embedded structs
+// being promoted as encoding/json does
+func (g *schemaGen) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.fields(ft, props, required)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	rtr := newRouter(nil, nil)
+
+	spec, undocumented := rtr.openAPI()
+	assert.Empty(t, undocumented, "routes missing from operations")
+
+	paths := spec["paths"].(map[string]map[string]interface{})
+	for key := range operations {
+		kv := strings.SplitN(key, " ", 2)
+		assert.Contains(t, paths[kv[1]], strings.ToLower(kv[0]),
+			"operation %q has no route", key)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	rtr := newRouter(nil, nil)
+	w := httptest.NewRecorder()
+	rtr.r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/openapi.json", nil))
+	assert.Equal(t, 200, w.Code)
+
+	var spec struct {
+		Paths      map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Contains(t, spec.Paths, "/admin/volumelabelsremove")
+	assert.NotContains(t, spec.Paths["/admin/version"], "post")
+
+	vol := spec.Components.Schemas["Volume"].Properties
+	for _, p := range []string{"volumeid", "serviceName", "labels", "id", "size"} {
+		assert.Contains(t, vol, p)
+	}
+	assert.Contains(t, spec.Components.Schemas["Error"].Properties, "requestID")
+}
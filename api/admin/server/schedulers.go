@@ -0,0 +1,174 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	pcontext "github.com/emccode/polly/api/context"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/schedulers"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+// getSchedulersHandler lists the registered schedulers, those of its tenant
+// for a restricted request
+func (rtr *Router) getSchedulersHandler(w http.ResponseWriter, r *http.Request) {
+	ss, err := rtr.storeFor(r).Schedulers()
+	if err != nil {
+		writeError(w, r, "problem getting schedulers", err)
+		return
+	}
+	all, err := rtr.storeFor(r).Tenants()
+	if err != nil {
+		writeError(w, r, "problem getting tenants", err)
+		return
+	}
+	schedulers.SetTenants(ss, all)
+	rtr.setStates(ss...)
+
+	visible := []*types.Scheduler{}
+	for _, s := range ss {
+		if t := pcontext.Tenant(r.Context()); t == "" || s.Tenant == t {
+			visible = append(visible, s)
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
+
+// postSchedulersHandler registers a scheduler, along with its tenant if set
+func (rtr *Router) postSchedulersHandler(w http.ResponseWriter, r *http.Request) {
+	s := &types.Scheduler{}
+	if !readJSON(w, r, s) {
+		return
+	}
+	if err := rtr.registryFor(r).Register(s); err != nil {
+		writeError(w, r, "problem registering scheduler", err)
+		return
+	}
+	rtr.setStates(s)
+
+	logger(r).WithField("scheduler", s.Name).Info("registered scheduler")
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (rtr *Router) getSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := rtr.scheduler(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// putSchedulerHandler replaces the type, contact and tenant of a scheduler
+func (rtr *Router) putSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	req := &types.Scheduler{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	s, err := rtr.registryFor(r).Update(mux.Vars(r)["scheduler"], req)
+	if err != nil {
+		writeError(w, r, "problem updating scheduler", err)
+		return
+	}
+	rtr.setStates(s)
+
+	logger(r).WithField("scheduler", s.Name).Info("updated scheduler")
+	writeJSON(w, http.StatusOK, s)
+}
+
+// deleteSchedulerHandler unregisters a scheduler, from its tenant too. The
+// volumes offered to it stay offered.
+func (rtr *Router) deleteSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["scheduler"]
+	if err := rtr.registryFor(r).Remove(name); err != nil {
+		writeError(w, r, "problem removing scheduler", err)
+		return
+	}
+
+	logger(r).WithField("scheduler", name).Info("removed scheduler")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postSchedulerHeartbeatHandler records that a scheduler is alive
+func (rtr *Router) postSchedulerHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := rtr.scheduler(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if err := rtr.storeFor(r).SaveSchedulerHeartbeat(s.Name, now); err != nil {
+		writeError(w, r, "problem saving heartbeat", err)
+		return
+	}
+	s.LastSeen = &now
+	rtr.setStates(s)
+	writeJSON(w, http.StatusOK, s)
+}
+
+// getSchedulerVolumesHandler lists the volumes offered to a scheduler, the
+// listing parameters apply as for the volumes
+func (rtr *Router) getSchedulerVolumesHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := rtr.scheduler(w, r)
+	if !ok {
+		return
+	}
+
+	vals := r.URL.Query()
+	vals.Set(volumes.SchedulersParam, s.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	l, err := rtr.vscFor(r).VolumesPage(vals)
+	if err != nil {
+		writeError(w, r, "problem getting volumes", err)
+		return
+	}
+	writeListing(w, l)
+}
+
+// scheduler returns the scheduler of the request, writing a 404 when it is
+// not registered and a 403 when it belongs to another tenant than the one
+// the request is restricted to
+func (rtr *Router) scheduler(w http.ResponseWriter, r *http.Request) (*types.Scheduler, bool) {
+	name := mux.Vars(r)["scheduler"]
+	s, err := rtr.storeFor(r).GetScheduler(name)
+	if err == nil && s == nil {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no scheduler %q", name).WithDetail("scheduler", name)
+	}
+	if err != nil {
+		writeError(w, r, "problem getting scheduler", err)
+		return nil, false
+	}
+
+	all, err := rtr.storeFor(r).Tenants()
+	if err != nil {
+		writeError(w, r, "problem getting tenants", err)
+		return nil, false
+	}
+	schedulers.SetTenants([]*types.Scheduler{s}, all)
+	rtr.setStates(s)
+
+	if t := pcontext.Tenant(r.Context()); t != "" && s.Tenant != t {
+		writeError(w, r, "", types.NewErrorf(http.StatusForbidden,
+			"scheduler %q is not registered to tenant %q", name, t).
+			WithDetail("scheduler", name))
+		return nil, false
+	}
+	return s, true
+}
+
+// registryFor returns the scheduler registry bound to the context of a
+// request
+func (rtr *Router) registryFor(r *http.Request) *schedulers.Registry {
+	return rtr.registry.WithContext(r.Context())
+}
+
+// setStates sets the liveness of schedulers given the staleness policy
+func (rtr *Router) setStates(ss ...*types.Scheduler) {
+	policy := schedulers.NewPolicy(rtr.p.Config)
+	now := time.Now()
+	for _, s := range ss {
+		s.State = policy.State(s, now)
+	}
+}
@@ -1,12 +1,26 @@
 package server
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
 	"github.com/akutz/gotil"
+	pcontext "github.com/emccode/polly/api/context"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/health"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/schedulers"
+	"github.com/emccode/polly/core/tenants"
+	"github.com/emccode/polly/core/tracing"
 	ctypes "github.com/emccode/polly/core/types"
 	"github.com/emccode/polly/core/volumes"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Router holds the router and Polly core object
@@ -14,56 +28,359 @@ type Router struct {
 	r   *mux.Router
 	p   *ctypes.Polly
 	vsc *volumes.Vsc
+
+	// listening is set to 1 while the admin listener is accepting requests
+	listening int32
+	addr      string
+	srv       *http.Server
+	errs      chan error
+
+	// done is closed on Stop to end the offer feeds, which would otherwise
+	// hold the shutdown until its context is done
+	done chan struct{}
+
+	// auth resolves the tenant of the requests, they are not authenticated
+	// when nil
+	auth *tenants.Authenticator
+
+	// registry registers the schedulers and the tenants owning them
+	registry *schedulers.Registry
 }
 
-// Start creates a new router with a nested Polly Core object
-func Start(p *ctypes.Polly) *Router {
+// newRouter creates a new router with a nested Polly Core object
+func newRouter(p *ctypes.Polly, vsc *volumes.Vsc) *Router {
 
 	r := &Router{
-		r:   mux.NewRouter(),
-		p:   p,
-		vsc: volumes.New(p),
+		r:    mux.NewRouter(),
+		p:    p,
+		vsc:  vsc,
+		done: make(chan struct{}),
 	}
 
 	//volumes
 	r.r.HandleFunc("/admin/version", r.getVersionHandler).Methods("GET")
-	r.r.HandleFunc("/admin/version",
+	r.r.Handle("/admin/version",
 		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumes", r.getVolumesHandler).Methods("GET")
 	r.r.HandleFunc("/admin/volumes", r.postVolumesHandler).Methods("POST")
-	r.r.HandleFunc("/admin/volumes",
+	r.r.Handle("/admin/volumes",
 		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumesall", r.getVolumesAllHandler).Methods("GET")
-	r.r.HandleFunc("/admin/volumesall",
+	r.r.Handle("/admin/volumesall",
 		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumes/{volumeID}", r.getVolumeInspectHandler).Methods("GET")
 	r.r.HandleFunc("/admin/volumes/{volid}", r.deleteVolumesHandler).Methods("DELETE")
-	r.r.HandleFunc("/admin/volumes/{volumeID}",
+	r.r.Handle("/admin/volumes/{volumeID}",
 		r.notAllowedHandler("GET", "DELETE")).Methods("PUT", "PATCH", "POST")
+	r.r.HandleFunc("/admin/volumesbulk", r.postVolumesBulkHandler).Methods("POST")
+	r.r.Handle("/admin/volumesbulk",
+		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumeoffer", r.postVolumeOfferHandler).Methods("POST")
-	r.r.HandleFunc("/admin/volumeoffer",
+	r.r.Handle("/admin/volumeoffer",
 		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumeofferrevoke", r.postVolumeOfferRevokeHandler).Methods("POST")
-	r.r.HandleFunc("/admin/volumeofferrevoke",
+	r.r.Handle("/admin/volumeofferrevoke",
 		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumelabel", r.postVolumeLabelHandler).Methods("POST")
-	r.r.HandleFunc("/admin/volumelabel",
+	r.r.Handle("/admin/volumelabel",
 		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
 	r.r.HandleFunc("/admin/volumelabelsremove", r.postVolumeLabelsRemoveHandler).Methods("POST")
-	r.r.HandleFunc("/admin/volumelabelsremove",
+	r.r.Handle("/admin/volumelabelsremove",
+		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
+
+	r.v2Routes()
+	r.webhookRoutes()
+	r.tenantRoutes()
+	r.schedulerRoutes()
+
+	r.r.HandleFunc("/admin/config", adminOnly(r.getConfigHandler)).Methods("GET")
+	r.r.Handle("/admin/config",
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc("/admin/config/reload", adminOnly(r.postConfigReloadHandler)).Methods("POST")
+	r.r.Handle("/admin/config/reload",
 		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
 
-	http.Handle("/", r.r)
+	r.r.HandleFunc("/admin/openapi.json", r.getOpenAPIHandler).Methods("GET")
+	r.r.Handle("/admin/openapi.json",
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+
+	r.r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.r.HandleFunc("/healthz", r.getHealthHandler).Methods("GET")
+	r.r.HandleFunc("/readyz", r.getReadyHandler).Methods("GET")
+
+	r.r.NotFoundHandler = http.HandlerFunc(r.notFoundHandler)
+
+	return r
+}
+
+// v2Routes registers the resource oriented admin API. Volume metadata
+// changes honor If-Match against the ETag of the volume.
+func (r *Router) v2Routes() {
+	const (
+		vols       = "/admin/v2/volumes"
+		vol        = vols + "/{volumeID}"
+		labels     = vol + "/labels"
+		label      = labels + "/{key}"
+		schedulers = vol + "/schedulers"
+		scheduler  = schedulers + "/{scheduler}"
+		offers     = "/admin/v2/offers"
+		accept     = offers + "/{offerID}/accept"
+		decline    = offers + "/{offerID}/decline"
+		leases     = "/admin/v2/leases"
+		lease      = leases + "/{volumeID}"
+	)
+
+	r.r.HandleFunc(vols, r.getVolumesV2Handler).Methods("GET")
+	r.r.HandleFunc(vols, r.postVolumesHandler).Methods("POST")
+	r.r.Handle(vols,
+		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(vol, r.getVolumeV2Handler).Methods("GET")
+	r.r.HandleFunc(vol, r.patchVolumeV2Handler).Methods("PATCH")
+	r.r.HandleFunc(vol, r.deleteVolumeV2Handler).Methods("DELETE")
+	r.r.Handle(vol,
+		r.notAllowedHandler("GET", "PATCH", "DELETE")).Methods("PUT", "POST")
+	r.r.HandleFunc(labels, r.getVolumeLabelsV2Handler).Methods("GET")
+	r.r.HandleFunc(labels, r.putVolumeLabelsV2Handler).Methods("PUT")
+	r.r.HandleFunc(labels, r.deleteVolumeLabelsV2Handler).Methods("DELETE")
+	r.r.Handle(labels,
+		r.notAllowedHandler("GET", "PUT", "DELETE")).Methods("PATCH", "POST")
+	r.r.HandleFunc(label, r.putVolumeLabelV2Handler).Methods("PUT")
+	r.r.HandleFunc(label, r.deleteVolumeLabelV2Handler).Methods("DELETE")
+	r.r.Handle(label,
+		r.notAllowedHandler("PUT", "DELETE")).Methods("GET", "PATCH", "POST")
+	r.r.HandleFunc(schedulers, r.getVolumeSchedulersV2Handler).Methods("GET")
+	r.r.HandleFunc(schedulers, r.putVolumeSchedulersV2Handler).Methods("PUT")
+	r.r.HandleFunc(schedulers, r.deleteVolumeSchedulersV2Handler).Methods("DELETE")
+	r.r.Handle(schedulers,
+		r.notAllowedHandler("GET", "PUT", "DELETE")).Methods("PATCH", "POST")
+	r.r.HandleFunc(scheduler, r.putVolumeSchedulerV2Handler).Methods("PUT")
+	r.r.HandleFunc(scheduler, r.deleteVolumeSchedulerV2Handler).Methods("DELETE")
+	r.r.Handle(scheduler,
+		r.notAllowedHandler("PUT", "DELETE")).Methods("GET", "PATCH", "POST")
+
+	r.r.HandleFunc(offers, r.getOffersHandler).Methods("GET")
+	r.r.Handle(offers,
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(accept, r.postOfferAcceptHandler).Methods("POST")
+	r.r.Handle(accept,
+		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(decline, r.postOfferDeclineHandler).Methods("POST")
+	r.r.Handle(decline,
+		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(leases, r.getLeasesHandler).Methods("GET")
+	r.r.Handle(leases,
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(lease, r.deleteLeaseHandler).Methods("DELETE")
+	r.r.Handle(lease,
+		r.notAllowedHandler("DELETE")).Methods("GET", "PUT", "PATCH", "POST")
+}
+
+// webhookRoutes registers the management of the webhooks called on volume
+// lifecycle events
+func (r *Router) webhookRoutes() {
+	const (
+		hooks      = "/admin/webhooks"
+		hook       = hooks + "/{webhookID}"
+		deliveries = hook + "/deliveries"
+	)
+
+	r.r.HandleFunc(hooks, adminOnly(r.getWebhooksHandler)).Methods("GET")
+	r.r.HandleFunc(hooks, adminOnly(r.postWebhooksHandler)).Methods("POST")
+	r.r.Handle(hooks,
+		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(hook, adminOnly(r.getWebhookHandler)).Methods("GET")
+	r.r.HandleFunc(hook, adminOnly(r.deleteWebhookHandler)).Methods("DELETE")
+	r.r.Handle(hook,
+		r.notAllowedHandler("GET", "DELETE")).Methods("PUT", "PATCH", "POST")
+	r.r.HandleFunc(deliveries, adminOnly(r.getWebhookDeliveriesHandler)).Methods("GET")
+	r.r.Handle(deliveries,
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+}
+
+// tenantRoutes registers the management of the tenants and of their
+// tokens. Only a tenant may get itself with its own token.
+func (r *Router) tenantRoutes() {
+	const (
+		tenants = "/admin/tenants"
+		tenant  = tenants + "/{tenant}"
+		tokens  = tenant + "/tokens"
+		token   = tokens + "/{tokenID}"
+	)
+
+	r.r.HandleFunc(tenants, adminOnly(r.getTenantsHandler)).Methods("GET")
+	r.r.HandleFunc(tenants, adminOnly(r.postTenantsHandler)).Methods("POST")
+	r.r.Handle(tenants,
+		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(tenant, r.getTenantHandler).Methods("GET")
+	r.r.HandleFunc(tenant, adminOnly(r.putTenantHandler)).Methods("PUT")
+	r.r.HandleFunc(tenant, adminOnly(r.deleteTenantHandler)).Methods("DELETE")
+	r.r.Handle(tenant,
+		r.notAllowedHandler("GET", "PUT", "DELETE")).Methods("PATCH", "POST")
+	r.r.HandleFunc(tokens, adminOnly(r.getTenantTokensHandler)).Methods("GET")
+	r.r.HandleFunc(tokens, adminOnly(r.postTenantTokensHandler)).Methods("POST")
+	r.r.Handle(tokens,
+		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(token, adminOnly(r.deleteTenantTokenHandler)).Methods("DELETE")
+	r.r.Handle(token,
+		r.notAllowedHandler("DELETE")).Methods("GET", "PUT", "PATCH", "POST")
+}
 
-	_, lAddr, err := gotil.ParseAddress(p.Config.GetString("polly.host"))
+// schedulerRoutes registers the scheduler registry. A request restricted to
+// a tenant only sees the schedulers of its tenant, and may send their
+// heartbeats.
+func (r *Router) schedulerRoutes() {
+	const (
+		schedulers = "/admin/schedulers"
+		scheduler  = schedulers + "/{scheduler}"
+		heartbeat  = scheduler + "/heartbeat"
+		volumes    = scheduler + "/volumes"
+	)
+
+	r.r.HandleFunc(schedulers, r.getSchedulersHandler).Methods("GET")
+	r.r.HandleFunc(schedulers, adminOnly(r.postSchedulersHandler)).Methods("POST")
+	r.r.Handle(schedulers,
+		r.notAllowedHandler("GET", "POST")).Methods("PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(scheduler, r.getSchedulerHandler).Methods("GET")
+	r.r.HandleFunc(scheduler, adminOnly(r.putSchedulerHandler)).Methods("PUT")
+	r.r.HandleFunc(scheduler, adminOnly(r.deleteSchedulerHandler)).Methods("DELETE")
+	r.r.Handle(scheduler,
+		r.notAllowedHandler("GET", "PUT", "DELETE")).Methods("PATCH", "POST")
+	r.r.HandleFunc(heartbeat, r.postSchedulerHeartbeatHandler).Methods("POST")
+	r.r.Handle(heartbeat,
+		r.notAllowedHandler("POST")).Methods("GET", "PUT", "PATCH", "DELETE")
+	r.r.HandleFunc(volumes, r.getSchedulerVolumesHandler).Methods("GET")
+	r.r.Handle(volumes,
+		r.notAllowedHandler("GET")).Methods("POST", "PUT", "PATCH", "DELETE")
+}
+
+// Start creates a new router with a nested Polly Core object and serves it
+// on the admin address until Stop is called
+func Start(p *ctypes.Polly, vsc *volumes.Vsc) (*Router, error) {
+	r := newRouter(p, vsc)
+	r.auth = tenants.New(p.Config, p.Store)
+	r.registry = schedulers.NewRegistry(p.Store)
+
+	host := p.Config.GetString("polly.host")
+	proto, lAddr, err := gotil.ParseAddress(host)
 	if err != nil {
-		panic(err)
+		return nil, goof.WithFieldE("host", host, "invalid admin address", err)
 	}
 
+	l, err := net.Listen(proto, lAddr)
+	if err != nil {
+		return nil, goof.WithFieldE("host", host, "cannot listen on admin address", err)
+	}
+	r.addr = l.Addr().String()
+	r.srv = &http.Server{Handler: correlate(r.instrument(r.authenticate(r.r)))}
+	r.errs = make(chan error, 1)
+	atomic.StoreInt32(&r.listening, 1)
+
 	go func() {
-		err := http.ListenAndServe(lAddr, nil)
-		panic(err)
+		err := r.srv.Serve(l)
+		atomic.StoreInt32(&r.listening, 0)
+		if err != http.ErrServerClosed {
+			r.errs <- goof.WithFieldE("host", host, "admin listener failed", err)
+		}
+		close(r.errs)
 	}()
 
-	return r
+	return r, nil
+}
+
+// Err returns a channel that receives an error if the admin listener fails.
+// The channel is closed once the listener has stopped.
+func (rtr *Router) Err() <-chan error {
+	return rtr.errs
+}
+
+// Stop closes the admin listener and waits for in-flight requests to
+// complete or for the context to be done
+func (rtr *Router) Stop(ctx context.Context) error {
+	close(rtr.done)
+	return rtr.srv.Shutdown(ctx)
+}
+
+// adminCheck reports the state of the admin listener
+func (rtr *Router) adminCheck() *types.HealthCheck {
+	if atomic.LoadInt32(&rtr.listening) == 0 {
+		return health.Fail("admin listener not accepting requests")
+	}
+	return health.OK(rtr.addr)
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Flush sends the buffered response of a streaming handler
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument records request metrics labelled with the matched route template
+// and traces the request, as a child of the span of the caller if sent
+func (rtr *Router) instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		route := "unmatched"
+		var match mux.RouteMatch
+		if rtr.r.Match(req, &match) && match.Route != nil {
+			if tpl, err := match.Route.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		ctx, span := tracing.StartSpan(
+			tracing.Extract(req.Context(), req.Header),
+			req.Method+" "+route,
+			attribute.String("http.method", req.Method),
+			attribute.String("http.route", route),
+			attribute.String("requestID", pcontext.RequestID(req.Context())))
+		req = req.WithContext(ctx)
+
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sr, req)
+		tracing.EndStatus(span, sr.status)
+		metrics.ObserveAdminRequest(route, req.Method, sr.status, start)
+		audit(req, route, sr.status, start)
+	})
+}
+
+// correlate sets the ID of an admin request, the one sent by the caller if
+// valid, on the response and on the request context so the handlers, the
+// logs and the libStorage calls of the request carry it
+func correlate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := requestID(w, req)
+		h.ServeHTTP(w, req.WithContext(pcontext.WithRequestID(req.Context(), id)))
+	})
+}
+
+// audit logs a completed admin request, at info level when it can change
+// volumes or the configuration
+func audit(req *http.Request, route string, status int, start time.Time) {
+	entry := log.WithFields(log.Fields{
+		"requestID": pcontext.RequestID(req.Context()),
+		"method":    req.Method,
+		"route":     route,
+		"path":      req.URL.Path,
+		"status":    status,
+		"remote":    req.RemoteAddr,
+		"duration":  time.Since(start).String(),
+	})
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		entry.Debug("admin request")
+	default:
+		entry.Info("admin request")
+	}
 }
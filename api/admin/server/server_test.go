@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pcontext "github.com/emccode/polly/api/context"
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelate(t *testing.T) {
+	var seen string
+	h := correlate(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = pcontext.RequestID(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/admin/volumes", nil)
+	req.Header.Set(types.RequestIDHeader, "caller-1234")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, "caller-1234", seen)
+	assert.Equal(t, "caller-1234", w.Header().Get(types.RequestIDHeader))
+
+	for _, id := range []string{"", "bad id\nlevel=error"} {
+		req = httptest.NewRequest("GET", "/admin/volumes", nil)
+		req.Header.Set(types.RequestIDHeader, id)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, w.Header().Get(types.RequestIDHeader))
+	}
+}
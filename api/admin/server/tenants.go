@@ -0,0 +1,195 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	store "github.com/docker/libkv/store"
+	pcontext "github.com/emccode/polly/api/context"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tenants"
+	"github.com/gorilla/mux"
+)
+
+// authenticate restricts the context of an admin request to the tenant of
+// its bearer token. The health and metrics endpoints are not authenticated
+// so probes and scrapers need no token.
+func (rtr *Router) authenticate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rtr.auth == nil || !strings.HasPrefix(r.URL.Path, "/admin/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		tenant, err := rtr.auth.Authenticate(r)
+		if err != nil {
+			if types.IsUnauthorized(err) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="polly"`)
+			}
+			writeError(w, r, "problem authenticating request", err)
+			return
+		}
+		if tenant != "" {
+			r = r.WithContext(pcontext.WithTenant(r.Context(), tenant))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// adminOnly rejects the requests restricted to a tenant with a 403
+func adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if t := pcontext.Tenant(r.Context()); t != "" {
+			writeError(w, r, "", types.NewErrorf(http.StatusForbidden,
+				"tenant %q is not allowed to %s %s", t, r.Method, r.URL.Path).
+				WithDetail("tenant", t))
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (rtr *Router) getTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	ts, err := rtr.storeFor(r).Tenants()
+	if err != nil {
+		writeError(w, r, "problem getting tenants", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ts)
+}
+
+func (rtr *Router) postTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	t := &types.Tenant{}
+	if !readJSON(w, r, t) {
+		return
+	}
+	if err := rtr.registryFor(r).CreateTenant(t); err != nil {
+		writeError(w, r, "problem creating tenant", err)
+		return
+	}
+
+	logger(r).WithField("tenant", t.Name).Info("created tenant")
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// getTenantHandler returns a tenant, a request restricted to a tenant may
+// only get its own
+func (rtr *Router) getTenantHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["tenant"]
+	if t := pcontext.Tenant(r.Context()); t != "" && t != name {
+		writeError(w, r, "", types.NewErrorf(http.StatusForbidden,
+			"tenant %q is not allowed to get tenant %q", t, name).
+			WithDetail("tenant", t))
+		return
+	}
+	t, ok := rtr.tenant(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// putTenantHandler replaces the description and the schedulers of a tenant
+func (rtr *Router) putTenantHandler(w http.ResponseWriter, r *http.Request) {
+	req := &types.Tenant{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	t, err := rtr.registryFor(r).UpdateTenant(mux.Vars(r)["tenant"], req)
+	if err != nil {
+		writeError(w, r, "problem updating tenant", err)
+		return
+	}
+
+	logger(r).WithField("tenant", t.Name).Info("updated tenant")
+	writeJSON(w, http.StatusOK, t)
+}
+
+// deleteTenantHandler removes a tenant along with its tokens, it is
+// rejected while volumes belong to it
+func (rtr *Router) deleteTenantHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["tenant"]
+	if err := rtr.registryFor(r).RemoveTenant(name); err != nil {
+		writeError(w, r, "problem removing tenant", err)
+		return
+	}
+
+	logger(r).WithField("tenant", name).Info("removed tenant")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rtr *Router) getTenantTokensHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := rtr.tenant(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := rtr.storeFor(r).TenantTokens(t.Name)
+	if err != nil {
+		writeError(w, r, "problem getting tokens", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// postTenantTokensHandler creates a token of a tenant, the reply is the only
+// one holding its value
+func (rtr *Router) postTenantTokensHandler(w http.ResponseWriter, r *http.Request) {
+	if err := rtr.auth.CanIssue(); err != nil {
+		writeError(w, r, "cannot create token", err)
+		return
+	}
+	t, ok := rtr.tenant(w, r)
+	if !ok {
+		return
+	}
+	token, hash := tenants.NewToken(t.Name)
+	if err := rtr.storeFor(r).SaveTenantToken(token, hash); err != nil {
+		writeError(w, r, "problem saving token", err)
+		return
+	}
+
+	logger(r).WithFields(log.Fields{
+		"tenant":  t.Name,
+		"tokenID": token.ID,
+	}).Info("created tenant token")
+	writeJSON(w, http.StatusCreated, token)
+}
+
+func (rtr *Router) deleteTenantTokenHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := rtr.tenant(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["tokenID"]
+	err := rtr.storeFor(r).RemoveTenantToken(t.Name, id)
+	if err == store.ErrKeyNotFound {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no token %q of tenant %q", id, t.Name).WithDetail("tokenID", id)
+	}
+	if err != nil {
+		writeError(w, r, "problem removing token", err)
+		return
+	}
+
+	logger(r).WithFields(log.Fields{
+		"tenant":  t.Name,
+		"tokenID": id,
+	}).Info("removed tenant token")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tenant returns the tenant of the request, writing a 404 when it does not
+// exist
+func (rtr *Router) tenant(w http.ResponseWriter, r *http.Request) (*types.Tenant, bool) {
+	name := mux.Vars(r)["tenant"]
+	t, err := rtr.storeFor(r).GetTenant(name)
+	if err == nil && t == nil {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no tenant %q", name).WithDetail("tenant", name)
+	}
+	if err != nil {
+		writeError(w, r, "problem getting tenant", err)
+		return nil, false
+	}
+	return t, true
+}
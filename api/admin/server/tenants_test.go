@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pcontext "github.com/emccode/polly/api/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminOnly(t *testing.T) {
+	called := false
+	h := adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	req := httptest.NewRequest("GET", "/admin/webhooks", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	called = false
+	req = req.WithContext(pcontext.WithTenant(req.Context(), "blue"))
+	w = httptest.NewRecorder()
+	h(w, req)
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/webhooks"
+	"github.com/gorilla/mux"
+)
+
+// storeFor returns the store bound to the context of the request
+func (rtr *Router) storeFor(r *http.Request) *store.PollyStore {
+	return rtr.p.Store.WithContext(r.Context())
+}
+
+func (rtr *Router) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	hooks, err := rtr.storeFor(r).Webhooks()
+	if err != nil {
+		writeError(w, r, "problem getting webhooks", err)
+		return
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// postWebhooksHandler registers a webhook, the reply is the only one holding
+// its secret
+func (rtr *Router) postWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	req := &types.WebhookCreateRequest{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	hook, err := webhooks.NewWebhook(req)
+	if err != nil {
+		writeError(w, r, "", err)
+		return
+	}
+	if err := rtr.storeFor(r).SaveWebhook(hook); err != nil {
+		writeError(w, r, "problem saving webhook", err)
+		return
+	}
+
+	logger(r).WithField("webhookID", hook.ID).Info("registered webhook")
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func (rtr *Router) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := rtr.webhook(w, r)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (rtr *Router) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := rtr.webhook(w, r)
+	if !ok {
+		return
+	}
+	if err := rtr.storeFor(r).RemoveWebhook(hook.ID); err != nil {
+		writeError(w, r, "problem removing webhook", err)
+		return
+	}
+
+	logger(r).WithField("webhookID", hook.ID).Info("removed webhook")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveriesHandler lists the last deliveries to a webhook, oldest
+// first
+func (rtr *Router) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := rtr.webhook(w, r)
+	if !ok {
+		return
+	}
+	ds, err := rtr.storeFor(r).WebhookDeliveries(hook.ID)
+	if err != nil {
+		writeError(w, r, "problem getting deliveries", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ds)
+}
+
+// webhook returns the webhook of the request, writing a 404 when it does
+// not exist
+func (rtr *Router) webhook(w http.ResponseWriter, r *http.Request) (*types.Webhook, bool) {
+	id := mux.Vars(r)["webhookID"]
+	hook, err := rtr.storeFor(r).GetWebhook(id)
+	if err == nil && hook == nil {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no webhook %q", id).WithDetail("webhookID", id)
+	}
+	if err != nil {
+		writeError(w, r, "problem getting webhook", err)
+		return nil, false
+	}
+	return hook, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	j, _ := json.Marshal(v)
+	w.Write(j)
+}
@@ -1,6 +1,10 @@
 package context
 
-import "github.com/emccode/libstorage/api/context"
+import (
+	gocontext "context"
+
+	"github.com/emccode/libstorage/api/context"
+)
 
 // PollyHeaderKey is the type for Polly HTTP header keys.
 type PollyHeaderKey int
@@ -8,16 +12,57 @@ type PollyHeaderKey int
 const (
 	// RequestPathHeaderKey is the header key for the Polly-Requestpath header.
 	RequestPathHeaderKey PollyHeaderKey = iota
+
+	// RequestIDHeaderKey is the header key for the X-Request-ID header
+	// correlating the libStorage calls with the admin request causing them.
+	RequestIDHeaderKey
+
+	// TraceParentHeaderKey is the header key for the W3C traceparent header
+	// carrying the span of a libStorage call to the libStorage server.
+	TraceParentHeaderKey
 )
 
 func (k PollyHeaderKey) String() string {
 	switch k {
 	case RequestPathHeaderKey:
 		return "Polly-Requestpath"
+	case RequestIDHeaderKey:
+		return "X-Request-ID"
+	case TraceParentHeaderKey:
+		return "Traceparent"
 	}
 	return ""
 }
 
 func init() {
 	context.RegisterCustomKey(RequestPathHeaderKey, context.CustomHeaderKey)
+	context.RegisterCustomKey(RequestIDHeaderKey, context.CustomKeyTypesAll)
+	context.RegisterCustomKey(TraceParentHeaderKey, context.CustomHeaderKey)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the request ID
+func WithRequestID(ctx gocontext.Context, id string) gocontext.Context {
+	return gocontext.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of the context, empty when it has none
+func RequestID(ctx gocontext.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context restricted to a tenant
+func WithTenant(ctx gocontext.Context, tenant string) gocontext.Context {
+	return gocontext.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant the context is restricted to, empty when it is
+// not restricted
+func Tenant(ctx gocontext.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(string)
+	return t
 }
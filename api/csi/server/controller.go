@@ -0,0 +1,463 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/query"
+	"github.com/emccode/polly/core/volumes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// gib is the unit of libStorage volume sizes
+	gib = 1 << 30
+
+	// storageClassParam selects the storage class of a volume
+	storageClassParam = "storageClass"
+
+	// coParamPrefix prefixes the parameters the Kubernetes sidecars add
+	coParamPrefix = "csi.storage.k8s.io/"
+)
+
+// controllerCaps are the controller capabilities of the plugin
+var controllerCaps = []csi.ControllerServiceCapability_RPC_Type{
+	csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+	csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
+	csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+	csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+}
+
+// CreateVolume creates a volume offered to the calling orchestrator.
+// Creating a volume of a name that is already offered returns it.
+func (s *Server) CreateVolume(
+	ctx context.Context,
+	req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if err := validateCapabilities(req.GetVolumeCapabilities()); err != nil {
+		return nil, err
+	}
+
+	var n int64
+	if cr := req.GetCapacityRange(); cr != nil {
+		var err error
+		if n, err = size(cr); err != nil {
+			return nil, err
+		}
+	}
+
+	sched := s.schedulerOf(ctx)
+	vol, err := s.volume(ctx, sched, func(vol *types.Volume) bool {
+		return vol.Volume != nil && vol.Name == req.GetName()
+	})
+	if err == nil {
+		if vol.Size < n {
+			return nil, status.Errorf(codes.AlreadyExists,
+				"volume %q exists with a smaller size", req.GetName())
+		}
+		return &csi.CreateVolumeResponse{Volume: csiVolume(vol)}, nil
+	} else if !types.IsNotFound(err) {
+		return nil, grpcError(err)
+	}
+
+	params, err := s.parameters(req.GetParameters())
+	if err != nil {
+		return nil, err
+	}
+	m := &types.VolumeCreateRequest{
+		ServiceName: s.service,
+		Name:        req.GetName(),
+		Schedulers:  []string{sched},
+	}
+	if err := volumes.CreateOptions(m, params); err != nil {
+		return nil, grpcError(err)
+	}
+	if m.ServiceName == "" {
+		return nil, status.Error(codes.InvalidArgument,
+			"mandatory service parameter missing")
+	}
+	if n > 0 {
+		m.Size = n
+	}
+
+	vol, err = s.vols(ctx).VolumeCreate(m)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	log.WithFields(log.Fields{
+		"volumeID":  vol.VolumeID,
+		"scheduler": sched,
+	}).Info("created volume for csi")
+	return &csi.CreateVolumeResponse{Volume: csiVolume(vol)}, nil
+}
+
+// DeleteVolume removes a volume offered to the calling orchestrator,
+// removing an unknown volume succeeds
+func (s *Server) DeleteVolume(
+	ctx context.Context,
+	req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume_id is required")
+	}
+	vol, err := s.volumeByID(ctx, req.GetVolumeId())
+	if types.IsNotFound(err) {
+		return &csi.DeleteVolumeResponse{}, nil
+	} else if err != nil {
+		return nil, grpcError(err)
+	}
+
+	if err := s.vols(ctx).VolumeRemove(vol.VolumeID); err != nil &&
+		!types.IsNotFound(err) {
+		return nil, grpcError(err)
+	}
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// ControllerPublishVolume checks the volume is offered to the calling
+// orchestrator and returns the libStorage identity of the volume, the node
+// attaches it through its libStorage integration driver
+func (s *Server) ControllerPublishVolume(
+	ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+
+	if req.GetVolumeId() == "" || req.GetNodeId() == "" {
+		return nil, status.Error(codes.InvalidArgument,
+			"volume_id and node_id are required")
+	}
+	if req.GetVolumeCapability() == nil {
+		return nil, status.Error(codes.InvalidArgument, "volume_capability is required")
+	}
+	if err := validateCapabilities(
+		[]*csi.VolumeCapability{req.GetVolumeCapability()}); err != nil {
+		return nil, err
+	}
+	vol, err := s.volumeByID(ctx, req.GetVolumeId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &csi.ControllerPublishVolumeResponse{
+		PublishContext: map[string]string{
+			"service":      vol.ServiceName,
+			"libsVolumeID": vol.ID,
+			"readonly":     strconv.FormatBool(req.GetReadonly()),
+		},
+	}, nil
+}
+
+// ControllerUnpublishVolume succeeds for any volume, the node detaches it
+func (s *Server) ControllerUnpublishVolume(
+	ctx context.Context,
+	req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume_id is required")
+	}
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
+}
+
+// ValidateVolumeCapabilities confirms the capabilities that attach a volume
+// to a single node
+func (s *Server) ValidateVolumeCapabilities(
+	ctx context.Context,
+	req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume_id is required")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, status.Error(codes.InvalidArgument,
+			"volume_capabilities are required")
+	}
+	if _, err := s.volumeByID(ctx, req.GetVolumeId()); err != nil {
+		return nil, grpcError(err)
+	}
+
+	if err := validateCapabilities(req.GetVolumeCapabilities()); err != nil {
+		return &csi.ValidateVolumeCapabilitiesResponse{
+			Message: status.Convert(err).Message(),
+		}, nil
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+			Parameters:         req.GetParameters(),
+		},
+	}, nil
+}
+
+// ListVolumes lists a page of the volumes offered to the calling
+// orchestrator
+func (s *Server) ListVolumes(
+	ctx context.Context,
+	req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_entries is negative")
+	}
+	vals := url.Values{volumes.SchedulersParam: {s.schedulerOf(ctx)}}
+	if req.GetMaxEntries() > 0 {
+		vals.Set("limit", strconv.Itoa(int(req.GetMaxEntries())))
+	}
+	if req.GetStartingToken() != "" {
+		vals.Set("continue", req.GetStartingToken())
+	}
+
+	l, err := s.vols(ctx).VolumesPage(vals)
+	if qe, ok := err.(*query.Error); ok && qe.Param == "continue" {
+		return nil, status.Error(codes.Aborted, err.Error())
+	} else if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &csi.ListVolumesResponse{NextToken: l.Continue}
+	for _, vol := range l.Volumes {
+		res.Entries = append(res.Entries,
+			&csi.ListVolumesResponse_Entry{Volume: csiVolume(vol)})
+	}
+	return res, nil
+}
+
+// ControllerGetCapabilities reports the controller calls the plugin serves
+func (s *Server) ControllerGetCapabilities(
+	ctx context.Context,
+	req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+
+	res := &csi.ControllerGetCapabilitiesResponse{}
+	for _, c := range controllerCaps {
+		res.Capabilities = append(res.Capabilities, &csi.ControllerServiceCapability{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{Type: c},
+			},
+		})
+	}
+	return res, nil
+}
+
+// CreateSnapshot snapshots a volume offered to the calling orchestrator
+func (s *Server) CreateSnapshot(
+	ctx context.Context,
+	req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+
+	if req.GetName() == "" || req.GetSourceVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument,
+			"name and source_volume_id are required")
+	}
+	vol, err := s.volumeByID(ctx, req.GetSourceVolumeId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	snap, err := s.vols(ctx).VolumeSnapshot(vol.VolumeID, req.GetName())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &csi.CreateSnapshotResponse{Snapshot: &csi.Snapshot{
+		SnapshotId:     snap.SnapshotID,
+		SourceVolumeId: vol.VolumeID,
+		SizeBytes:      snap.VolumeSize * gib,
+		CreationTime:   timestamppb.New(time.Unix(snap.StartTime, 0)),
+		ReadyToUse:     snap.Status != "pending",
+	}}, nil
+}
+
+// DeleteSnapshot removes a snapshot of a volume offered to the calling
+// orchestrator, removing an unknown snapshot succeeds
+func (s *Server) DeleteSnapshot(
+	ctx context.Context,
+	req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+
+	if req.GetSnapshotId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot_id is required")
+	}
+	snap, err := s.vols(ctx).SnapshotInspect(req.GetSnapshotId())
+	if types.IsNotFound(err) {
+		return &csi.DeleteSnapshotResponse{}, nil
+	} else if err != nil {
+		return nil, grpcError(err)
+	}
+	if _, err := s.volumeByID(ctx, snap.SourceVolumeID); types.IsNotFound(err) {
+		return nil, grpcError(types.NewErrorf(http.StatusForbidden,
+			"snapshot %q is not of a volume offered to %s",
+			req.GetSnapshotId(), s.schedulerOf(ctx)).
+			WithDetail("snapshotID", req.GetSnapshotId()))
+	} else if err != nil {
+		return nil, grpcError(err)
+	}
+
+	if err := s.vols(ctx).SnapshotRemove(req.GetSnapshotId()); err != nil &&
+		!types.IsNotFound(err) {
+		return nil, grpcError(err)
+	}
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+// schedulerOf returns the scheduler of the calling orchestrator, the common
+// name of its verified client certificate or the configured scheduler
+func (s *Server) schedulerOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return s.scheduler
+	}
+	ti, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(ti.State.VerifiedChains) == 0 ||
+		len(ti.State.VerifiedChains[0]) == 0 {
+		return s.scheduler
+	}
+	if cn := ti.State.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+		return cn
+	}
+	return s.scheduler
+}
+
+// volume returns the first volume offered to the scheduler that matches
+func (s *Server) volume(
+	ctx context.Context,
+	sched string,
+	match func(vol *types.Volume) bool) (*types.Volume, error) {
+
+	l, err := s.vols(ctx).VolumesPage(url.Values{volumes.SchedulersParam: {sched}})
+	if err != nil {
+		return nil, err
+	}
+	for _, vol := range l.Volumes {
+		if match(vol) {
+			return vol, nil
+		}
+	}
+	return nil, types.NewErrorf(http.StatusNotFound,
+		"no matching volume offered to %s", sched)
+}
+
+// volumeByID returns the volume offered to the calling orchestrator with the
+// Polly volume ID
+func (s *Server) volumeByID(ctx context.Context, volumeID string) (*types.Volume, error) {
+	sched := s.schedulerOf(ctx)
+	vol, err := s.volume(ctx, sched, func(vol *types.Volume) bool {
+		return vol.VolumeID == volumeID
+	})
+	if types.IsNotFound(err) {
+		return nil, types.NewErrorf(http.StatusNotFound,
+			"no volume %q offered to %s", volumeID, sched).
+			WithDetail("volumeID", volumeID)
+	}
+	return vol, err
+}
+
+// parameters returns the create options of the CSI parameters, those of the
+// selected storage class overridden by the explicit parameters
+func (s *Server) parameters(params map[string]string) (map[string]string, error) {
+	opts := make(map[string]string)
+	for k, v := range params {
+		if !strings.EqualFold(k, storageClassParam) {
+			continue
+		}
+		class, ok := s.classes[strings.ToLower(v)]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"unknown storage class %q", v)
+		}
+		for ck, cv := range class {
+			opts[ck] = cv
+		}
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, storageClassParam) ||
+			strings.HasPrefix(k, coParamPrefix) {
+			continue
+		}
+		opts[k] = v
+	}
+	return opts, nil
+}
+
+// validateCapabilities rejects the access modes of volumes attached to
+// several nodes, libStorage attaches a volume to a single instance
+func validateCapabilities(caps []*csi.VolumeCapability) error {
+	if len(caps) == 0 {
+		return status.Error(codes.InvalidArgument, "volume_capabilities are required")
+	}
+	for _, c := range caps {
+		switch c.GetAccessMode().GetMode() {
+		case csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+			csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		default:
+			return status.Errorf(codes.InvalidArgument,
+				"unsupported access mode %s", c.GetAccessMode().GetMode())
+		}
+	}
+	return nil
+}
+
+// size returns the size in GiB of a capacity range, the required bytes
+// rounded up
+func size(cr *csi.CapacityRange) (int64, error) {
+	req, limit := cr.GetRequiredBytes(), cr.GetLimitBytes()
+	if req < 0 || limit < 0 {
+		return 0, status.Error(codes.InvalidArgument, "capacity_range is negative")
+	}
+	if req == 0 {
+		req = limit
+	}
+	n := (req + gib - 1) / gib
+	if limit > 0 && n*gib > limit {
+		return 0, status.Errorf(codes.OutOfRange,
+			"capacity_range has no whole GiB between %d and %d bytes", req, limit)
+	}
+	return n, nil
+}
+
+func csiVolume(vol *types.Volume) *csi.Volume {
+	cv := &csi.Volume{
+		VolumeId: vol.VolumeID,
+		VolumeContext: map[string]string{
+			"service": vol.ServiceName,
+		},
+	}
+	if vol.Volume != nil {
+		cv.CapacityBytes = vol.Size * gib
+		cv.VolumeContext["libsVolumeID"] = vol.ID
+	}
+	return cv
+}
+
+// grpcError returns the gRPC status of an admin API error
+func grpcError(err error) error {
+	if _, ok := err.(*query.Error); ok {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	code := codes.Internal
+	switch types.ErrorStatus(err) {
+	case http.StatusBadRequest, 422:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		code = codes.FailedPrecondition
+	case http.StatusNotImplemented:
+		code = codes.Unimplemented
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes/volumestest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func singleNode() []*csi.VolumeCapability {
+	return []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}}
+}
+
+func code(err error) codes.Code {
+	return status.Code(err)
+}
+
+func TestController(t *testing.T) {
+	vols := volumestest.New(&types.Volume{
+		Volume:      &lstypes.Volume{ID: "vol-other", Name: "other"},
+		VolumeID:    "ebs-vol-other",
+		ServiceName: "ebs",
+		Schedulers:  []string{"mesos"},
+	})
+	s := newServer(func(context.Context) Volumes { return vols }, "csi", "",
+		map[string]map[string]string{
+			"gold": {"service": "ebs", "volumetype": "io1", "iops": "50"},
+		})
+
+	l := bufconn.Listen(1 << 20)
+	go s.srv.Serve(l)
+	defer s.Stop(context.Background())
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return l.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	id, ctl := csi.NewIdentityClient(conn), csi.NewControllerClient(conn)
+
+	info, err := id.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, pluginName, info.Name)
+	}
+
+	// volumes not offered to the orchestrator are not visible
+	list, err := ctl.ListVolumes(ctx, &csi.ListVolumesRequest{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, list.Entries)
+	}
+	_, err = ctl.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+		VolumeId: "ebs-vol-other", NodeId: "i-1", VolumeCapability: singleNode()[0]})
+	assert.Equal(t, codes.NotFound, code(err))
+
+	_, err = ctl.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "data", VolumeCapabilities: singleNode(),
+		Parameters: map[string]string{"storageClass": "silver"}})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+	_, err = ctl.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "data", Parameters: map[string]string{"storageClass": "gold"},
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+		}}})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+
+	req := &csi.CreateVolumeRequest{
+		Name:               "data",
+		VolumeCapabilities: singleNode(),
+		CapacityRange:      &csi.CapacityRange{RequiredBytes: 3 << 29},
+		Parameters: map[string]string{
+			"StorageClass":                     "Gold",
+			"iops":                             "100",
+			"tier":                             "fast",
+			"csi.storage.k8s.io/pvc/namespace": "default",
+		},
+	}
+	created, err := ctl.CreateVolume(ctx, req)
+	if !assert.NoError(t, err) || !assert.Len(t, vols.Created, 1) {
+		return
+	}
+	assert.Equal(t, "ebs-vol-data", created.Volume.VolumeId)
+	assert.EqualValues(t, 2<<30, created.Volume.CapacityBytes)
+	m := vols.Created[0]
+	assert.Equal(t, "ebs", m.ServiceName)
+	assert.Equal(t, "io1", m.VolumeType)
+	assert.EqualValues(t, 100, m.IOPS)
+	assert.EqualValues(t, 2, m.Size)
+	assert.Equal(t, []string{"csi"}, m.Schedulers)
+	assert.Equal(t, map[string]string{"tier": "fast"}, m.Labels)
+
+	again, err := ctl.CreateVolume(ctx, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ebs-vol-data", again.Volume.VolumeId)
+		assert.Len(t, vols.Created, 1)
+	}
+
+	list, err = ctl.ListVolumes(ctx, &csi.ListVolumesRequest{})
+	if assert.NoError(t, err) && assert.Len(t, list.Entries, 1) {
+		assert.Equal(t, "ebs-vol-data", list.Entries[0].Volume.VolumeId)
+	}
+	_, err = ctl.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "stale"})
+	assert.Equal(t, codes.Aborted, code(err))
+
+	pub, err := ctl.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+		VolumeId: "ebs-vol-data", NodeId: "i-1", VolumeCapability: singleNode()[0]})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "vol-data", pub.PublishContext["libsVolumeID"])
+	}
+
+	snap, err := ctl.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{
+		Name: "nightly", SourceVolumeId: "ebs-vol-data"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ebs-snap-nightly", snap.Snapshot.SnapshotId)
+		assert.True(t, snap.Snapshot.ReadyToUse)
+	}
+	_, err = ctl.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "ebs-snap-gone"})
+	assert.NoError(t, err)
+
+	// another orchestrator may not remove the snapshots of the volume
+	other := newServer(func(context.Context) Volumes { return vols }, "k8s", "", nil)
+	_, err = other.DeleteSnapshot(ctx,
+		&csi.DeleteSnapshotRequest{SnapshotId: "ebs-snap-nightly"})
+	assert.Equal(t, codes.PermissionDenied, code(err))
+	assert.NotNil(t, vols.Snaps["ebs-snap-nightly"])
+	_, err = ctl.DeleteSnapshot(ctx,
+		&csi.DeleteSnapshotRequest{SnapshotId: "ebs-snap-nightly"})
+	assert.NoError(t, err)
+	assert.Nil(t, vols.Snaps["ebs-snap-nightly"])
+
+	_, err = ctl.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "ebs-vol-other"})
+	assert.NoError(t, err)
+	assert.NotNil(t, vols.Vols["ebs-vol-other"])
+	_, err = ctl.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "ebs-vol-data"})
+	assert.NoError(t, err)
+	assert.Nil(t, vols.Vols["ebs-vol-data"])
+}
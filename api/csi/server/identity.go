@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/emccode/polly/core/version"
+)
+
+// pluginName is the name of the CSI plugin
+const pluginName = "polly.emccode.github.com"
+
+// GetPluginInfo returns the name and version of the plugin
+func (s *Server) GetPluginInfo(
+	ctx context.Context,
+	req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+
+	return &csi.GetPluginInfoResponse{
+		Name:          pluginName,
+		VendorVersion: version.VersionStr,
+	}, nil
+}
+
+// GetPluginCapabilities reports the controller service. Volumes are not
+// constrained to a topology, they are offered to schedulers instead.
+func (s *Server) GetPluginCapabilities(
+	ctx context.Context,
+	req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
+
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: []*csi.PluginCapability{{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
+				},
+			},
+		}},
+	}, nil
+}
+
+// Probe reports the plugin as ready, the libStorage services are checked by
+// the calls themselves
+func (s *Server) Probe(
+	ctx context.Context,
+	req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+
+	return &csi.ProbeResponse{}, nil
+}
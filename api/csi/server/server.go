@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	ctypes "github.com/emccode/polly/core/types"
+	"github.com/emccode/polly/core/volumes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	enabledKey        = "polly.csi.enabled"
+	endpointKey       = "polly.csi.endpoint"
+	schedulerKey      = "polly.csi.scheduler"
+	serviceKey        = "polly.csi.service"
+	storageClassesKey = "polly.csi.storageClasses"
+	certFileKey       = "polly.csi.tls.certFile"
+	keyFileKey        = "polly.csi.tls.keyFile"
+	clientCAFileKey   = "polly.csi.tls.clientCAFile"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Volumes is the volume service the CSI calls are mapped to
+type Volumes interface {
+	VolumesPage(vals url.Values) (*volumes.Listing, error)
+	VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, error)
+	VolumeRemove(volumeID string) error
+	VolumeSnapshot(volumeID, name string) (*types.Snapshot, error)
+	SnapshotInspect(snapshotID string) (*types.Snapshot, error)
+	SnapshotRemove(snapshotID string) error
+}
+
+// Server serves the CSI identity and controller services. Each container
+// orchestrator is a scheduler, it sees the volumes offered to it and the
+// volumes it creates are offered to it.
+type Server struct {
+	csi.UnimplementedControllerServer
+
+	// vols returns the volume service scoped to a call
+	vols      func(ctx context.Context) Volumes
+	scheduler string
+	service   string
+
+	// classes holds the parameters of each storage class
+	classes map[string]map[string]string
+
+	srv  *grpc.Server
+	path string
+	errs chan error
+}
+
+// Enabled returns whether the CSI services are configured
+func Enabled(config gofig.Config) bool {
+	return config.GetBool(enabledKey)
+}
+
+// newServer creates the CSI services for the orchestrators not identified by
+// a client certificate, volumes are created on service unless the parameters
+// select another
+func newServer(
+	vols func(ctx context.Context) Volumes,
+	scheduler, service string,
+	classes map[string]map[string]string,
+	opts ...grpc.ServerOption) *Server {
+
+	s := &Server{
+		vols:      vols,
+		scheduler: scheduler,
+		service:   service,
+		classes:   classes,
+		srv:       grpc.NewServer(opts...),
+	}
+	csi.RegisterIdentityServer(s.srv, s)
+	csi.RegisterControllerServer(s.srv, s)
+	return s
+}
+
+// Start serves the CSI services on the configured endpoint until Stop is
+// called
+func Start(p *ctypes.Polly, vsc *volumes.Vsc) (*Server, error) {
+	var opts []grpc.ServerOption
+	if p.Config.GetString(certFileKey) != "" {
+		creds, err := serverCredentials(p.Config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := newServer(
+		func(ctx context.Context) Volumes { return vsc.WithContext(ctx) },
+		p.Config.GetString(schedulerKey), p.Config.GetString(serviceKey),
+		config.Sections(p.Config, storageClassesKey), opts...)
+
+	if err := s.listen(p.Config.GetString(endpointKey)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serverCredentials loads the TLS certificate of the server. Orchestrators
+// must present a certificate signed by the client CA when one is set.
+func serverCredentials(config gofig.Config) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(
+		config.GetString(certFileKey), config.GetString(keyFileKey))
+	if err != nil {
+		return nil, goof.WithError("cannot load csi certificate", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if caFile := config.GetString(clientCAFileKey); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, goof.WithFieldE("file", caFile, "cannot read csi client CA", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, goof.WithField("file", caFile, "no certificate in csi client CA")
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tc), nil
+}
+
+// listen serves the services on a unix:// or tcp:// endpoint, a unix socket
+// left by a previous daemon is replaced
+func (s *Server) listen(endpoint string) error {
+	network, addr := "unix", endpoint
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		network, addr = endpoint[:i], endpoint[i+3:]
+	}
+
+	if network == "unix" {
+		if err := os.MkdirAll(filepath.Dir(addr), 0755); err != nil {
+			return goof.WithFieldE("endpoint", endpoint, "cannot create csi socket directory", err)
+		}
+		os.Remove(addr)
+		s.path = addr
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return goof.WithFieldE("endpoint", endpoint, "cannot listen on csi endpoint", err)
+	}
+	s.errs = make(chan error, 1)
+
+	go func() {
+		if err := s.srv.Serve(l); err != nil {
+			s.errs <- goof.WithFieldE("endpoint", endpoint, "csi listener failed", err)
+		}
+		close(s.errs)
+	}()
+
+	log.WithFields(log.Fields{
+		"endpoint":  endpoint,
+		"scheduler": s.scheduler,
+	}).Info("serving csi identity and controller")
+	return nil
+}
+
+// Err returns a channel that receives an error if the CSI listener fails.
+// The channel is closed once the listener has stopped.
+func (s *Server) Err() <-chan error {
+	return s.errs
+}
+
+// Stop closes the CSI listener, waiting for in-flight calls to complete or
+// for the context to be done, and removes a unix socket
+func (s *Server) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.srv.Stop()
+		err = ctx.Err()
+	}
+	if s.path != "" {
+		os.Remove(s.path)
+	}
+	return err
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("CSI")
+	r.Key(gofig.Bool, "", false,
+		"Serve the CSI identity and controller services", enabledKey)
+	r.Key(gofig.String, "", "unix:///var/run/polly/csi.sock",
+		"The unix:// or tcp:// endpoint of the CSI services", endpointKey)
+	r.Key(gofig.String, "", "csi",
+		"The scheduler of orchestrators without a client certificate",
+		schedulerKey)
+	r.Key(gofig.String, "", "",
+		"The service of the volumes created without a service parameter",
+		serviceKey)
+	r.Key(gofig.String, "", "",
+		"The TLS certificate of the CSI services", certFileKey)
+	r.Key(gofig.String, "", "",
+		"The TLS key of the CSI services", keyFileKey)
+	r.Key(gofig.String, "", "",
+		"The CA of the orchestrator certificates, their common name is "+
+			"the scheduler", clientCAFileKey)
+	return r
+}
@@ -0,0 +1,406 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes"
+)
+
+// contentType is the media type of the plugin API
+const contentType = "application/vnd.docker.plugins.v1.2+json"
+
+// pluginRequest is the body of the volume driver requests, ID identifies the
+// container of a mount
+type pluginRequest struct {
+	Name string
+	Opts map[string]string
+	ID   string
+}
+
+type pluginVolume struct {
+	Name       string
+	Mountpoint string                 `json:",omitempty"`
+	Status     map[string]interface{} `json:",omitempty"`
+}
+
+type capabilities struct {
+	Scope string
+}
+
+// pluginResponse is the body of the plugin responses, Err is set when the
+// request failed
+type pluginResponse struct {
+	Implements   []string        `json:",omitempty"`
+	Mountpoint   string          `json:",omitempty"`
+	Volume       *pluginVolume   `json:",omitempty"`
+	Volumes      []*pluginVolume `json:",omitempty"`
+	Capabilities *capabilities   `json:",omitempty"`
+	Err          string
+}
+
+func (rtr *Router) activateHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, &pluginResponse{Implements: []string{"VolumeDriver"}})
+}
+
+// createHandler creates a volume offered to the Docker engine. Options other
+// than service, size, iops, volumeType and availabilityZone are labels.
+// Creating a volume that is already offered succeeds.
+func (rtr *Router) createHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := rtr.volume(req.Name); err == nil {
+		writeResponse(w, &pluginResponse{})
+		return
+	} else if !types.IsNotFound(err) {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	m := &types.VolumeCreateRequest{
+		ServiceName: rtr.service,
+		Name:        req.Name,
+		Schedulers:  []string{rtr.scheduler},
+	}
+	if err := volumes.CreateOptions(m, req.Opts); err != nil {
+		writeErr(w, req, "", err)
+		return
+	}
+	if m.ServiceName == "" {
+		writeErr(w, req, "", types.NewError(422, "mandatory service option missing"))
+		return
+	}
+
+	if _, err := rtr.vols.VolumeCreate(m); err != nil {
+		writeErr(w, req, "volume creation failed", err)
+		return
+	}
+	writeResponse(w, &pluginResponse{})
+}
+
+// removeHandler removes a volume that no container uses, the volume being
+// locked so that no container mounts it meanwhile
+func (rtr *Router) removeHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	vol, err := rtr.volume(req.Name)
+	if err != nil {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	unlock := rtr.lock(vol.VolumeID)
+	defer unlock()
+
+	if rtr.mounted(vol.VolumeID) {
+		writeErr(w, req, "", types.NewErrorf(http.StatusConflict,
+			"volume %q is in use", req.Name).WithDetail("volumeID", vol.VolumeID))
+		return
+	}
+
+	if err := rtr.vols.VolumeRemove(vol.VolumeID); err != nil {
+		writeErr(w, req, "volume removal failed", err)
+		return
+	}
+	writeResponse(w, &pluginResponse{})
+}
+
+// mountHandler mounts a volume for a container. A volume is attached and
+// mounted once, other containers share its mount point.
+func (rtr *Router) mountHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	vol, err := rtr.volume(req.Name)
+	if err != nil {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	unlock := rtr.lock(vol.VolumeID)
+	defer unlock()
+
+	var mp string
+	if rtr.mounted(vol.VolumeID) {
+		mp, err = rtr.mounter.VolumePath(vol.ServiceName, vol.ID, vol.Name)
+	} else {
+		mp, err = rtr.mounter.VolumeMount(vol.ServiceName, vol.ID, vol.Name)
+	}
+	if err != nil {
+		writeErr(w, req, "volume mount failed", err)
+		return
+	}
+
+	rtr.mu.Lock()
+	if rtr.mounts[vol.VolumeID] == nil {
+		rtr.mounts[vol.VolumeID] = make(map[string]bool)
+	}
+	rtr.mounts[vol.VolumeID][req.ID] = true
+	rtr.mu.Unlock()
+	rtr.save(vol.VolumeID)
+
+	log.WithFields(log.Fields{
+		"volumeID":   vol.VolumeID,
+		"id":         req.ID,
+		"mountpoint": mp,
+	}).Info("mounted volume for docker")
+	writeResponse(w, &pluginResponse{Mountpoint: mp})
+}
+
+// unmountHandler releases the mount of a container, the volume is unmounted
+// and detached when no container uses it anymore
+func (rtr *Router) unmountHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	vol, err := rtr.volume(req.Name)
+	if err != nil {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	unlock := rtr.lock(vol.VolumeID)
+	defer unlock()
+
+	rtr.mu.Lock()
+	delete(rtr.mounts[vol.VolumeID], req.ID)
+	rtr.mu.Unlock()
+	rtr.save(vol.VolumeID)
+	if rtr.mounted(vol.VolumeID) {
+		writeResponse(w, &pluginResponse{})
+		return
+	}
+
+	if err := rtr.mounter.VolumeUnmount(vol.ServiceName, vol.ID, vol.Name); err != nil {
+		writeErr(w, req, "volume unmount failed", err)
+		return
+	}
+	rtr.mu.Lock()
+	delete(rtr.mounts, vol.VolumeID)
+	rtr.mu.Unlock()
+
+	log.WithField("volumeID", vol.VolumeID).Info("unmounted volume for docker")
+	writeResponse(w, &pluginResponse{})
+}
+
+func (rtr *Router) pathHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	vol, err := rtr.volume(req.Name)
+	if err != nil {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	mp, err := rtr.mountpoint(vol)
+	if err != nil {
+		writeErr(w, req, "volume path failed", err)
+		return
+	}
+	writeResponse(w, &pluginResponse{Mountpoint: mp})
+}
+
+func (rtr *Router) getHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	vol, err := rtr.volume(req.Name)
+	if err != nil {
+		writeErr(w, req, "volume lookup failed", err)
+		return
+	}
+
+	pv := &pluginVolume{
+		Name: req.Name,
+		Status: map[string]interface{}{
+			"volumeID":   vol.VolumeID,
+			"service":    vol.ServiceName,
+			"schedulers": vol.Schedulers,
+			"labels":     vol.Labels,
+		},
+	}
+	if pv.Mountpoint, err = rtr.mountpoint(vol); err != nil {
+		writeErr(w, req, "volume path failed", err)
+		return
+	}
+	writeResponse(w, &pluginResponse{Volume: pv})
+}
+
+func (rtr *Router) listHandler(w http.ResponseWriter, r *http.Request) {
+	vols, err := rtr.offered()
+	if err != nil {
+		writeErr(w, &pluginRequest{}, "volume listing failed", err)
+		return
+	}
+
+	res := &pluginResponse{Volumes: []*pluginVolume{}}
+	for _, vol := range vols {
+		res.Volumes = append(res.Volumes, &pluginVolume{Name: dockerName(vol)})
+	}
+	writeResponse(w, res)
+}
+
+// capabilitiesHandler reports the volumes as global, they are reachable from
+// any Docker host that is offered them
+func (rtr *Router) capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, &pluginResponse{Capabilities: &capabilities{Scope: "global"}})
+}
+
+// offered returns the volumes offered to the Docker engine
+func (rtr *Router) offered() ([]*types.Volume, error) {
+	return rtr.vols.Volumes(url.Values{volumes.SchedulersParam: {rtr.scheduler}})
+}
+
+// volume returns the volume offered to the Docker engine named name, the
+// Polly volume ID also selects a volume
+func (rtr *Router) volume(name string) (*types.Volume, error) {
+	vols, err := rtr.offered()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *types.Volume
+	for _, vol := range vols {
+		if vol.VolumeID == name {
+			return vol, nil
+		}
+		if dockerName(vol) != name {
+			continue
+		}
+		if found != nil {
+			return nil, types.NewErrorf(http.StatusConflict,
+				"several volumes are named %q, use the volume ID", name)
+		}
+		found = vol
+	}
+	if found == nil {
+		return nil, types.NewErrorf(http.StatusNotFound,
+			"no volume %q offered to %s", name, rtr.scheduler)
+	}
+	return found, nil
+}
+
+// mountpoint returns the mount point of a volume mounted by the plugin,
+// empty when it is not mounted
+func (rtr *Router) mountpoint(vol *types.Volume) (string, error) {
+	unlock := rtr.lock(vol.VolumeID)
+	defer unlock()
+
+	if !rtr.mounted(vol.VolumeID) {
+		return "", nil
+	}
+	return rtr.mounter.VolumePath(vol.ServiceName, vol.ID, vol.Name)
+}
+
+// mounted returns whether a container uses a volume
+func (rtr *Router) mounted(volumeID string) bool {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	return len(rtr.mounts[volumeID]) > 0
+}
+
+// save persists the containers using a volume. A failure is only logged,
+// the plugin still counting them until the daemon restarts.
+func (rtr *Router) save(volumeID string) {
+	rtr.mu.Lock()
+	ids := []string{}
+	for id := range rtr.mounts[volumeID] {
+		ids = append(ids, id)
+	}
+	rtr.mu.Unlock()
+	sort.Strings(ids)
+
+	if err := rtr.store.SaveDockerMounts(rtr.host, volumeID, ids); err != nil {
+		log.WithError(err).WithField("volumeID", volumeID).Warn(
+			"problem saving docker mounts")
+	}
+}
+
+// volumeLock serializes the requests on a volume, refs counting the
+// requests holding or waiting for it
+type volumeLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks a volume until the returned func is called, the requests on
+// other volumes going on meanwhile
+func (rtr *Router) lock(volumeID string) func() {
+	rtr.mu.Lock()
+	l, ok := rtr.locks[volumeID]
+	if !ok {
+		l = &volumeLock{}
+		rtr.locks[volumeID] = l
+	}
+	l.refs++
+	rtr.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		rtr.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(rtr.locks, volumeID)
+		}
+		rtr.mu.Unlock()
+	}
+}
+
+// dockerName is the name of a volume in the Docker engine, the libStorage
+// name of the volume
+func dockerName(vol *types.Volume) string {
+	if vol.Volume == nil || vol.Name == "" {
+		return vol.VolumeID
+	}
+	return vol.Name
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request) (*pluginRequest, bool) {
+	req := &pluginRequest{}
+	b, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(b, req); err != nil {
+		writeErr(w, req, "", types.NewError(http.StatusBadRequest, "json is unparsable"))
+		return nil, false
+	}
+	return req, true
+}
+
+// writeErr writes a failed response. The Docker engine shows the error to
+// the user, errors other than admin API errors are prefixed by mesg.
+func writeErr(w http.ResponseWriter, req *pluginRequest, mesg string, err error) {
+	msg := err.Error()
+	if _, ok := err.(*types.Error); !ok && mesg != "" {
+		msg = mesg + ": " + msg
+	}
+	log.WithFields(log.Fields{
+		"name": req.Name,
+		"id":   req.ID,
+	}).WithError(err).Warn("docker volume plugin request failed")
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusInternalServerError)
+	j, _ := json.Marshal(&pluginResponse{Err: msg})
+	w.Write(j)
+}
+
+func writeResponse(w http.ResponseWriter, res *pluginResponse) {
+	w.Header().Set("Content-Type", contentType)
+	j, _ := json.Marshal(res)
+	w.Write(j)
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes/volumestest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMounter counts the mounts of each volume. When hold is set, mounting
+// the volume named slow signals held and waits for hold.
+type fakeMounter struct {
+	mu      sync.Mutex
+	mounted map[string]int
+	held    chan struct{}
+	hold    chan struct{}
+}
+
+func (f *fakeMounter) VolumeMount(serviceName, volumeID, volumeName string) (string, error) {
+	if volumeName == "slow" && f.hold != nil {
+		f.held <- struct{}{}
+		<-f.hold
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mounted[volumeID]++
+	return "/var/lib/polly/" + volumeName, nil
+}
+
+func (f *fakeMounter) VolumeUnmount(serviceName, volumeID, volumeName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mounted[volumeID]--
+	return nil
+}
+
+func (f *fakeMounter) VolumePath(serviceName, volumeID, volumeName string) (string, error) {
+	return "/var/lib/polly/" + volumeName, nil
+}
+
+// fakeMountStore holds the saved mounts in memory by host and volume ID
+type fakeMountStore struct {
+	mu    sync.Mutex
+	saved map[string]map[string][]string
+}
+
+func (f *fakeMountStore) DockerMounts(host string) (map[string][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mounts := make(map[string][]string)
+	for volumeID, ids := range f.saved[host] {
+		mounts[volumeID] = ids
+	}
+	return mounts, nil
+}
+
+func (f *fakeMountStore) SaveDockerMounts(host, volumeID string, ids []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.saved[host] == nil {
+		f.saved[host] = make(map[string][]string)
+	}
+	if len(ids) == 0 {
+		delete(f.saved[host], volumeID)
+	} else {
+		f.saved[host][volumeID] = ids
+	}
+	return nil
+}
+
+func newFakeMountStore() *fakeMountStore {
+	return &fakeMountStore{saved: make(map[string]map[string][]string)}
+}
+
+// call serves a plugin request without a socket
+func call(t *testing.T, rtr *Router, method string, req pluginRequest) *pluginResponse {
+	b, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	rtr.r.ServeHTTP(rec, httptest.NewRequest("POST", "/"+method, bytes.NewReader(b)))
+	var pr pluginResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pr), method)
+	return &pr
+}
+
+// engine stands in for the Docker engine calling the plugin on its socket
+type engine struct {
+	t *testing.T
+	c *http.Client
+}
+
+func (e *engine) call(method string, req interface{}) *pluginResponse {
+	b, _ := json.Marshal(req)
+	res, err := e.c.Post("http://plugin/"+method, contentType, bytes.NewReader(b))
+	if !assert.NoError(e.t, err) {
+		return &pluginResponse{Err: err.Error()}
+	}
+	defer res.Body.Close()
+	b, _ = ioutil.ReadAll(res.Body)
+
+	var pr pluginResponse
+	assert.NoError(e.t, json.Unmarshal(b, &pr), method)
+	assert.Equal(e.t, pr.Err == "", res.StatusCode == http.StatusOK, method)
+	return &pr
+}
+
+func TestPlugin(t *testing.T) {
+	vols := volumestest.New(&types.Volume{
+		Volume:      &lstypes.Volume{ID: "vol-other", Name: "other"},
+		VolumeID:    "ebs-vol-other",
+		ServiceName: "ebs",
+		Schedulers:  []string{"mesos"},
+	})
+	mounter := &fakeMounter{mounted: map[string]int{}}
+	rtr := newRouter(vols, mounter, newFakeMountStore(), "host-1", "docker", "ebs")
+
+	path := filepath.Join(t.TempDir(), "plugins", "polly.sock")
+	if !assert.NoError(t, rtr.listen(path)) {
+		return
+	}
+	defer rtr.Stop(context.Background())
+
+	e := &engine{t: t, c: &http.Client{Transport: &http.Transport{
+		Dial: func(string, string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}}
+
+	assert.Equal(t, []string{"VolumeDriver"}, e.call("Plugin.Activate", nil).Implements)
+	assert.Equal(t, "global",
+		e.call("VolumeDriver.Capabilities", nil).Capabilities.Scope)
+
+	// volumes not offered to docker are not visible
+	assert.Empty(t, e.call("VolumeDriver.List", nil).Volumes)
+	assert.Contains(t, e.call("VolumeDriver.Get", pluginRequest{Name: "other"}).Err,
+		"no volume")
+
+	assert.Contains(t, e.call("VolumeDriver.Create", pluginRequest{
+		Name: "data", Opts: map[string]string{"size": "ten"}}).Err, "invalid option")
+	assert.Empty(t, e.call("VolumeDriver.Create", pluginRequest{
+		Name: "data", Opts: map[string]string{"size": "10", "tier": "gold"}}).Err)
+	vol := vols.Vols["ebs-vol-data"]
+	if !assert.NotNil(t, vol) {
+		return
+	}
+	assert.Equal(t, []string{"ebs", "docker"}, vol.Schedulers)
+	assert.Equal(t, map[string]string{"tier": "gold"}, vol.Labels)
+	assert.EqualValues(t, 10, vol.Size)
+	assert.Empty(t, e.call("VolumeDriver.Create", pluginRequest{Name: "data"}).Err)
+
+	list := e.call("VolumeDriver.List", nil).Volumes
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "data", list[0].Name)
+	}
+
+	// two containers share one mount
+	res := e.call("VolumeDriver.Mount", pluginRequest{Name: "data", ID: "c1"})
+	assert.Equal(t, "/var/lib/polly/data", res.Mountpoint)
+	e.call("VolumeDriver.Mount", pluginRequest{Name: "data", ID: "c2"})
+	assert.Equal(t, 1, mounter.mounted["vol-data"])
+	assert.Equal(t, "/var/lib/polly/data",
+		e.call("VolumeDriver.Path", pluginRequest{Name: "data"}).Mountpoint)
+	get := e.call("VolumeDriver.Get", pluginRequest{Name: "ebs-vol-data"}).Volume
+	if assert.NotNil(t, get) {
+		assert.Equal(t, "/var/lib/polly/data", get.Mountpoint)
+		assert.Equal(t, "ebs-vol-data", get.Status["volumeID"])
+	}
+
+	assert.Contains(t,
+		e.call("VolumeDriver.Remove", pluginRequest{Name: "data"}).Err, "in use")
+
+	e.call("VolumeDriver.Unmount", pluginRequest{Name: "data", ID: "c1"})
+	assert.Equal(t, 1, mounter.mounted["vol-data"])
+	e.call("VolumeDriver.Unmount", pluginRequest{Name: "data", ID: "c2"})
+	assert.Equal(t, 0, mounter.mounted["vol-data"])
+	assert.Empty(t, e.call("VolumeDriver.Path", pluginRequest{Name: "data"}).Mountpoint)
+
+	assert.Empty(t, e.call("VolumeDriver.Remove", pluginRequest{Name: "data"}).Err)
+	assert.Nil(t, vols.Vols["ebs-vol-data"])
+}
+
+func TestPluginVolumeLock(t *testing.T) {
+	offered := func(name string) *types.Volume {
+		return &types.Volume{
+			Volume:      &lstypes.Volume{ID: "vol-" + name, Name: name},
+			VolumeID:    "ebs-vol-" + name,
+			ServiceName: "ebs",
+			Schedulers:  []string{"docker"},
+		}
+	}
+	vols := volumestest.New(offered("slow"), offered("fast"))
+	mounter := &fakeMounter{
+		mounted: map[string]int{},
+		held:    make(chan struct{}),
+		hold:    make(chan struct{}),
+	}
+	rtr := newRouter(vols, mounter, newFakeMountStore(), "host-1", "docker", "ebs")
+
+	done := make(chan *pluginResponse)
+	go func() {
+		done <- call(t, rtr, "VolumeDriver.Mount", pluginRequest{Name: "slow", ID: "c1"})
+	}()
+	<-mounter.held
+
+	// the mounts of other volumes go on while one is being attached
+	assert.Empty(t,
+		call(t, rtr, "VolumeDriver.Mount", pluginRequest{Name: "fast", ID: "c2"}).Err)
+	assert.Equal(t, "/var/lib/polly/fast",
+		call(t, rtr, "VolumeDriver.Path", pluginRequest{Name: "fast"}).Mountpoint)
+
+	// a removal waits for the mount in progress and finds the volume in use
+	removed := make(chan *pluginResponse)
+	go func() {
+		removed <- call(t, rtr, "VolumeDriver.Remove", pluginRequest{Name: "slow"})
+	}()
+	for {
+		rtr.mu.Lock()
+		waiting := rtr.locks["ebs-vol-slow"].refs == 2
+		rtr.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(mounter.hold)
+	assert.Empty(t, (<-done).Err)
+	assert.Contains(t, (<-removed).Err, "in use")
+	assert.NotNil(t, vols.Vols["ebs-vol-slow"])
+	assert.Equal(t, map[string]int{"vol-slow": 1, "vol-fast": 1}, mounter.mounted)
+	assert.Empty(t, rtr.locks)
+}
+
+func TestPluginRestore(t *testing.T) {
+	vols := volumestest.New(&types.Volume{
+		Volume:      &lstypes.Volume{ID: "vol-data", Name: "data"},
+		VolumeID:    "ebs-vol-data",
+		ServiceName: "ebs",
+		Schedulers:  []string{"docker"},
+	})
+	mounter := &fakeMounter{mounted: map[string]int{}}
+	ms := newFakeMountStore()
+	rtr := newRouter(vols, mounter, ms, "host-1", "docker", "ebs")
+	assert.NoError(t, rtr.restore())
+	call(t, rtr, "VolumeDriver.Mount", pluginRequest{Name: "data", ID: "c1"})
+	call(t, rtr, "VolumeDriver.Mount", pluginRequest{Name: "data", ID: "c2"})
+	assert.Equal(t, map[string][]string{"ebs-vol-data": {"c1", "c2"}}, ms.saved["host-1"])
+
+	// a restarted daemon keeps the mount until its last container stops
+	rtr = newRouter(vols, mounter, ms, "host-1", "docker", "ebs")
+	assert.NoError(t, rtr.restore())
+	assert.Equal(t, "/var/lib/polly/data",
+		call(t, rtr, "VolumeDriver.Path", pluginRequest{Name: "data"}).Mountpoint)
+	assert.Contains(t,
+		call(t, rtr, "VolumeDriver.Remove", pluginRequest{Name: "data"}).Err, "in use")
+	call(t, rtr, "VolumeDriver.Unmount", pluginRequest{Name: "data", ID: "c1"})
+	assert.Equal(t, 1, mounter.mounted["vol-data"])
+	call(t, rtr, "VolumeDriver.Unmount", pluginRequest{Name: "data", ID: "c2"})
+	assert.Equal(t, 0, mounter.mounted["vol-data"])
+	assert.Empty(t, ms.saved["host-1"])
+
+	// the mounts of other hosts are not restored
+	ms.saved["host-2"] = map[string][]string{"ebs-vol-data": {"c3"}}
+	rtr = newRouter(vols, mounter, ms, "host-1", "docker", "ebs")
+	assert.NoError(t, rtr.restore())
+	assert.Empty(t,
+		call(t, rtr, "VolumeDriver.Path", pluginRequest{Name: "data"}).Mountpoint)
+}
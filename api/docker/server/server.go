@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
+	ctypes "github.com/emccode/polly/core/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+const (
+	enabledKey   = "polly.docker.enabled"
+	pluginDirKey = "polly.docker.pluginDir"
+	nameKey      = "polly.docker.name"
+	schedulerKey = "polly.docker.scheduler"
+	serviceKey   = "polly.docker.service"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Volumes is the volume service the plugin requests are mapped to
+type Volumes interface {
+	Volumes(vals url.Values) ([]*types.Volume, error)
+	VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, error)
+	VolumeRemove(volumeID string) error
+}
+
+// Mounter attaches and mounts volumes on the local host
+type Mounter interface {
+	VolumeMount(serviceName, volumeID, volumeName string) (string, error)
+	VolumeUnmount(serviceName, volumeID, volumeName string) error
+	VolumePath(serviceName, volumeID, volumeName string) (string, error)
+}
+
+// MountStore persists the containers using the volumes mounted by the
+// plugin of a host, so a restarted daemon keeps counting them
+type MountStore interface {
+	DockerMounts(host string) (map[string][]string, error)
+	SaveDockerMounts(host, volumeID string, ids []string) error
+}
+
+// Router serves the Docker volume plugin API. The Docker engine is a
+// scheduler, it sees the volumes offered to it and the volumes it creates
+// are offered to it.
+type Router struct {
+	r         *mux.Router
+	vols      Volumes
+	mounter   Mounter
+	store     MountStore
+	host      string
+	scheduler string
+	service   string
+
+	// mounts holds the IDs of the containers using each mounted volume, the
+	// requests on a volume being serialized by its lock in locks
+	mu     sync.Mutex
+	mounts map[string]map[string]bool
+	locks  map[string]*volumeLock
+
+	path string
+	srv  *http.Server
+	errs chan error
+}
+
+// Enabled returns whether the Docker volume plugin is configured
+func Enabled(config gofig.Config) bool {
+	return config.GetBool(enabledKey)
+}
+
+// newRouter creates the plugin router for a scheduler, volumes are created
+// on service unless the request selects another. The mounts are saved under
+// host.
+func newRouter(
+	vols Volumes, mounter Mounter, store MountStore,
+	host, scheduler, service string) *Router {
+
+	r := &Router{
+		r:         mux.NewRouter(),
+		vols:      vols,
+		mounter:   mounter,
+		store:     store,
+		host:      host,
+		scheduler: scheduler,
+		service:   service,
+		mounts:    make(map[string]map[string]bool),
+		locks:     make(map[string]*volumeLock),
+	}
+
+	r.r.HandleFunc("/Plugin.Activate", r.activateHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Create", r.createHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Remove", r.removeHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Mount", r.mountHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Unmount", r.unmountHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Path", r.pathHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Get", r.getHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.List", r.listHandler).Methods("POST")
+	r.r.HandleFunc("/VolumeDriver.Capabilities", r.capabilitiesHandler).Methods("POST")
+
+	return r
+}
+
+// Start serves the Docker volume plugin on a unix socket in the plugin
+// directory of the Docker engine until Stop is called
+func Start(p *ctypes.Polly, vsc *volumes.Vsc) (*Router, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, goof.WithError("cannot get host name", err)
+	}
+	r := newRouter(vsc, p.LsClient, p.Store, host,
+		p.Config.GetString(schedulerKey), p.Config.GetString(serviceKey))
+	if err := r.restore(); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(p.Config.GetString(pluginDirKey),
+		p.Config.GetString(nameKey)+".sock")
+	if err := r.listen(path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// restore loads the mounts saved by the previous daemons of the host
+func (rtr *Router) restore() error {
+	saved, err := rtr.store.DockerMounts(rtr.host)
+	if err != nil {
+		return goof.WithFieldE("host", rtr.host, "cannot load docker mounts", err)
+	}
+	for volumeID, ids := range saved {
+		rtr.mounts[volumeID] = make(map[string]bool)
+		for _, id := range ids {
+			rtr.mounts[volumeID][id] = true
+		}
+	}
+	log.WithFields(log.Fields{
+		"host":    rtr.host,
+		"volumes": len(saved),
+	}).Debug("restored docker mounts")
+	return nil
+}
+
+// listen serves the plugin on a unix socket, replacing a stale socket left
+// by a previous daemon
+func (rtr *Router) listen(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return goof.WithFieldE("path", path, "cannot create plugin directory", err)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return goof.WithFieldE("path", path, "cannot listen on plugin socket", err)
+	}
+	rtr.path = path
+	rtr.srv = &http.Server{Handler: rtr.r}
+	rtr.errs = make(chan error, 1)
+
+	go func() {
+		err := rtr.srv.Serve(l)
+		if err != http.ErrServerClosed {
+			rtr.errs <- goof.WithFieldE("path", path, "docker plugin listener failed", err)
+		}
+		close(rtr.errs)
+	}()
+
+	log.WithFields(log.Fields{
+		"path":      path,
+		"scheduler": rtr.scheduler,
+	}).Info("serving docker volume plugin")
+	return nil
+}
+
+// Err returns a channel that receives an error if the plugin listener fails.
+// The channel is closed once the listener has stopped.
+func (rtr *Router) Err() <-chan error {
+	return rtr.errs
+}
+
+// Stop closes the plugin listener, waiting for in-flight requests to
+// complete or for the context to be done, and removes the socket
+func (rtr *Router) Stop(ctx context.Context) error {
+	err := rtr.srv.Shutdown(ctx)
+	os.Remove(rtr.path)
+	return err
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Docker Volume Plugin")
+	r.Key(gofig.Bool, "", false,
+		"Serve the Docker volume plugin API", enabledKey)
+	r.Key(gofig.String, "", "/run/docker/plugins",
+		"The directory of the Docker plugin sockets", pluginDirKey)
+	r.Key(gofig.String, "", "polly",
+		"The volume driver name of the plugin", nameKey)
+	r.Key(gofig.String, "", "docker",
+		"The scheduler the Docker engine is offered volumes as", schedulerKey)
+	r.Key(gofig.String, "", "",
+		"The service of the volumes created without a service option",
+		serviceKey)
+	return r
+}
@@ -0,0 +1,547 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// apiVersionHeader carries the broker API version of a request
+	apiVersionHeader = "X-Broker-API-Version"
+
+	// serviceID is the ID of the volume service of the catalog, its plans
+	// are prefixed by it
+	serviceID = "polly-volume"
+
+	// the labels tying a volume to its service instance and bindings
+	instanceLabel = "osb.instanceID"
+	planLabel     = "osb.planID"
+	orgLabel      = "osb.organizationGUID"
+	spaceLabel    = "osb.spaceGUID"
+	bindingPrefix = "osb.binding."
+
+	// the states of a last operation
+	inProgress = "in progress"
+	succeeded  = "succeeded"
+	failed     = "failed"
+)
+
+// validID matches the instance and binding IDs, which are used in filters
+// and label keys
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type catalog struct {
+	Services []*service `json:"services"`
+}
+
+type service struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Bindable       bool     `json:"bindable"`
+	PlanUpdateable bool     `json:"plan_updateable"`
+	Requires       []string `json:"requires"`
+	Plans          []*plan  `json:"plans"`
+}
+
+type plan struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Free        bool   `json:"free"`
+}
+
+type provisionRequest struct {
+	ServiceID        string                 `json:"service_id"`
+	PlanID           string                 `json:"plan_id"`
+	OrganizationGUID string                 `json:"organization_guid"`
+	SpaceGUID        string                 `json:"space_guid"`
+	Parameters       map[string]interface{} `json:"parameters"`
+}
+
+type bindRequest struct {
+	ServiceID    string `json:"service_id"`
+	PlanID       string `json:"plan_id"`
+	AppGUID      string `json:"app_guid"`
+	BindResource *struct {
+		AppGUID string `json:"app_guid"`
+	} `json:"bind_resource"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// operation is the state of an asynchronous provision
+type operation struct {
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+}
+
+type asyncResponse struct {
+	Operation string `json:"operation"`
+}
+
+type bindResponse struct {
+	Credentials  map[string]interface{} `json:"credentials"`
+	VolumeMounts []*volumeMount         `json:"volume_mounts"`
+}
+
+// volumeMount tells the Cloud Foundry cells how to mount a bound volume
+type volumeMount struct {
+	Driver       string  `json:"driver"`
+	ContainerDir string  `json:"container_dir"`
+	Mode         string  `json:"mode"`
+	DeviceType   string  `json:"device_type"`
+	Device       *device `json:"device"`
+}
+
+type device struct {
+	VolumeID    string                 `json:"volume_id"`
+	MountConfig map[string]interface{} `json:"mount_config,omitempty"`
+}
+
+// errorResponse is the body of the failed broker responses
+type errorResponse struct {
+	Error       string `json:"error,omitempty"`
+	Description string `json:"description"`
+}
+
+// catalogHandler returns the volume service with a plan per storage class
+func (rtr *Router) catalogHandler(w http.ResponseWriter, r *http.Request) {
+	svc := &service{
+		ID:          serviceID,
+		Name:        serviceID,
+		Description: "Persistent volumes managed by Polly",
+		Bindable:    true,
+		Requires:    []string{"volume_mount"},
+		Plans:       []*plan{},
+	}
+	for _, name := range rtr.planNames() {
+		desc := fmt.Sprintf("Volumes of the %s plan", name)
+		if s := rtr.plans[name]["service"]; s != "" {
+			desc = fmt.Sprintf("Volumes of the %s libStorage service", s)
+		}
+		svc.Plans = append(svc.Plans, &plan{
+			ID:          planID(name),
+			Name:        name,
+			Description: desc,
+			Free:        true,
+		})
+	}
+	writeJSON(w, http.StatusOK, &catalog{Services: []*service{svc}})
+}
+
+// provisionHandler creates the volume of an instance from the options of its
+// plan, overridden by the parameters. The volume is created in the
+// background when the Cloud Controller accepts an incomplete provision.
+func (rtr *Router) provisionHandler(w http.ResponseWriter, r *http.Request) {
+	iid, ok := pathID(w, r, "instanceID")
+	if !ok {
+		return
+	}
+	req := &provisionRequest{}
+	if !readRequest(w, r, req) {
+		return
+	}
+	if req.ServiceID != serviceID {
+		writeErr(w, types.NewErrorf(http.StatusBadRequest,
+			"unknown service_id %q", req.ServiceID))
+		return
+	}
+	name := strings.TrimPrefix(req.PlanID, serviceID+"-")
+	opts, ok := rtr.plans[name]
+	if !ok || planID(name) != req.PlanID {
+		writeErr(w, types.NewErrorf(http.StatusBadRequest,
+			"unknown plan_id %q", req.PlanID))
+		return
+	}
+
+	if rtr.inProgress(iid) {
+		writeJSON(w, http.StatusAccepted, &asyncResponse{Operation: "provision"})
+		return
+	}
+	vols := rtr.vols(r.Context())
+	if vol, err := instance(vols, iid); err == nil {
+		if vol.Labels[planLabel] != req.PlanID {
+			writeErr(w, types.NewErrorf(http.StatusConflict,
+				"instance %q exists with another plan", iid))
+			return
+		}
+		writeJSON(w, http.StatusOK, struct{}{})
+		return
+	} else if !types.IsNotFound(err) {
+		writeErr(w, err)
+		return
+	}
+
+	m := &types.VolumeCreateRequest{Name: iid}
+	params, err := planParameters(opts, req.Parameters)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if err := volumes.CreateOptions(m, params); err != nil {
+		writeErr(w, err)
+		return
+	}
+	if m.ServiceName == "" {
+		writeErr(w, types.NewError(422, "mandatory service parameter missing"))
+		return
+	}
+	if m.Labels == nil {
+		m.Labels = make(map[string]string)
+	}
+	m.Labels[instanceLabel] = iid
+	m.Labels[planLabel] = req.PlanID
+	m.Labels[orgLabel] = req.OrganizationGUID
+	m.Labels[spaceLabel] = req.SpaceGUID
+
+	if r.URL.Query().Get("accepts_incomplete") != "true" {
+		if _, err := vols.VolumeCreate(m); err != nil {
+			writeErr(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, struct{}{})
+		return
+	}
+
+	rtr.mu.Lock()
+	if op, ok := rtr.ops[iid]; ok && op.State == inProgress {
+		rtr.mu.Unlock()
+		writeJSON(w, http.StatusAccepted, &asyncResponse{Operation: "provision"})
+		return
+	}
+	op := &operation{State: inProgress, Description: "creating volume"}
+	rtr.ops[iid] = op
+	rtr.wg.Add(1)
+	rtr.mu.Unlock()
+
+	go func() {
+		defer rtr.wg.Done()
+		vol, err := rtr.vols(context.Background()).VolumeCreate(m)
+
+		rtr.mu.Lock()
+		defer rtr.mu.Unlock()
+		if err != nil {
+			log.WithField("instanceID", iid).WithError(err).Warn(
+				"service instance provision failed")
+			op.State, op.Description = failed, err.Error()
+			return
+		}
+		log.WithFields(log.Fields{
+			"instanceID": iid,
+			"volumeID":   vol.VolumeID,
+		}).Info("provisioned service instance")
+		op.State, op.Description = succeeded, "created volume "+vol.VolumeID
+	}()
+	writeJSON(w, http.StatusAccepted, &asyncResponse{Operation: "provision"})
+}
+
+// lastOperationHandler reports the state of an asynchronous provision. A
+// finished operation is forgotten once reported.
+func (rtr *Router) lastOperationHandler(w http.ResponseWriter, r *http.Request) {
+	iid, ok := pathID(w, r, "instanceID")
+	if !ok {
+		return
+	}
+
+	rtr.mu.Lock()
+	op, ok := rtr.ops[iid]
+	var res operation
+	if ok {
+		res = *op
+		if op.State != inProgress {
+			delete(rtr.ops, iid)
+		}
+	}
+	rtr.mu.Unlock()
+	if ok {
+		writeJSON(w, http.StatusOK, &res)
+		return
+	}
+
+	if _, err := instance(rtr.vols(r.Context()), iid); types.IsNotFound(err) {
+		writeJSON(w, http.StatusGone, struct{}{})
+		return
+	} else if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &operation{State: succeeded})
+}
+
+// deprovisionHandler removes the volume of an unbound instance
+func (rtr *Router) deprovisionHandler(w http.ResponseWriter, r *http.Request) {
+	iid, ok := pathID(w, r, "instanceID")
+	if !ok {
+		return
+	}
+	if rtr.inProgress(iid) {
+		writeErr(w, types.NewErrorf(422,
+			"instance %q is being provisioned", iid).WithDetail("error", "ConcurrencyError"))
+		return
+	}
+
+	vols := rtr.vols(r.Context())
+	vol, err := instance(vols, iid)
+	if types.IsNotFound(err) {
+		writeJSON(w, http.StatusGone, struct{}{})
+		return
+	} else if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if len(bindings(vol)) > 0 {
+		writeErr(w, types.NewErrorf(422, "instance %q has bindings", iid))
+		return
+	}
+
+	if err := vols.VolumeRemove(vol.VolumeID); err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// bindHandler offers the volume of an instance to the Cloud Foundry
+// scheduler and returns how to mount it, a 409 while the scheduler is
+// suspended. The parameters select the mount directory, mount, and a read
+// only mount, readonly.
+func (rtr *Router) bindHandler(w http.ResponseWriter, r *http.Request) {
+	iid, ok := pathID(w, r, "instanceID")
+	if !ok {
+		return
+	}
+	bid, ok := pathID(w, r, "bindingID")
+	if !ok {
+		return
+	}
+	req := &bindRequest{}
+	if !readRequest(w, r, req) {
+		return
+	}
+	appGUID := req.AppGUID
+	if req.BindResource != nil && req.BindResource.AppGUID != "" {
+		appGUID = req.BindResource.AppGUID
+	}
+
+	vols := rtr.vols(r.Context())
+	vol, err := instance(vols, iid)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	// the offers to a suspended scheduler are withheld, binding would hand
+	// the volume out anyway
+	if err := vols.Suspended(rtr.scheduler); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	vol, err = vols.VolumeUpdate(vol.VolumeID, "", func(vol *types.Volume) error {
+		if _, ok := vol.Labels[bindingPrefix+bid]; ok {
+			status = http.StatusOK
+			return nil
+		}
+		if vol.Labels == nil {
+			vol.Labels = make(map[string]string)
+		}
+		vol.Labels[bindingPrefix+bid] = appGUID
+		for _, s := range vol.Schedulers {
+			if s == rtr.scheduler {
+				return nil
+			}
+		}
+		vol.Schedulers = append(vol.Schedulers, rtr.scheduler)
+		return nil
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	dir := "/var/vcap/data/" + iid
+	if v, ok := req.Parameters["mount"].(string); ok && v != "" {
+		dir = v
+	}
+	mode := "rw"
+	if v := req.Parameters["readonly"]; v == true || v == "true" {
+		mode = "r"
+	}
+	mc := map[string]interface{}{"service": vol.ServiceName}
+	if vol.Volume != nil {
+		mc["libsVolumeID"] = vol.ID
+	}
+	writeJSON(w, status, &bindResponse{
+		Credentials: map[string]interface{}{},
+		VolumeMounts: []*volumeMount{{
+			Driver:       rtr.driver,
+			ContainerDir: dir,
+			Mode:         mode,
+			DeviceType:   "shared",
+			Device:       &device{VolumeID: vol.VolumeID, MountConfig: mc},
+		}},
+	})
+}
+
+// unbindHandler removes a binding, the offer to the Cloud Foundry scheduler
+// is revoked with the last binding
+func (rtr *Router) unbindHandler(w http.ResponseWriter, r *http.Request) {
+	iid, ok := pathID(w, r, "instanceID")
+	if !ok {
+		return
+	}
+	bid, ok := pathID(w, r, "bindingID")
+	if !ok {
+		return
+	}
+
+	vols := rtr.vols(r.Context())
+	vol, err := instance(vols, iid)
+	if types.IsNotFound(err) {
+		writeJSON(w, http.StatusGone, struct{}{})
+		return
+	} else if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	gone := false
+	_, err = vols.VolumeUpdate(vol.VolumeID, "", func(vol *types.Volume) error {
+		if _, ok := vol.Labels[bindingPrefix+bid]; !ok {
+			gone = true
+			return nil
+		}
+		delete(vol.Labels, bindingPrefix+bid)
+		if len(bindings(vol)) > 0 {
+			return nil
+		}
+		var scheds []string
+		for _, s := range vol.Schedulers {
+			if s != rtr.scheduler {
+				scheds = append(scheds, s)
+			}
+		}
+		vol.Schedulers = scheds
+		return nil
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if gone {
+		writeJSON(w, http.StatusGone, struct{}{})
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// inProgress returns whether an instance is being provisioned
+func (rtr *Router) inProgress(iid string) bool {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	op, ok := rtr.ops[iid]
+	return ok && op.State == inProgress
+}
+
+// instance returns the volume of a service instance
+func instance(vols Volumes, iid string) (*types.Volume, error) {
+	l, err := vols.Volumes(url.Values{
+		"filter": {fmt.Sprintf("labels.%s=%s", instanceLabel, iid)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(l) == 0 {
+		return nil, types.NewErrorf(http.StatusNotFound,
+			"no service instance %q", iid).WithDetail("instanceID", iid)
+	}
+	return l[0], nil
+}
+
+// bindings returns the binding IDs of the volume of an instance
+func bindings(vol *types.Volume) []string {
+	var ids []string
+	for k := range vol.Labels {
+		if strings.HasPrefix(k, bindingPrefix) {
+			ids = append(ids, strings.TrimPrefix(k, bindingPrefix))
+		}
+	}
+	return ids
+}
+
+func planID(name string) string {
+	return serviceID + "-" + name
+}
+
+// planParameters returns the create options of a plan along with the
+// provision parameters, which may not override the settings of the plan
+func planParameters(
+	plan map[string]string,
+	params map[string]interface{}) (map[string]string, error) {
+
+	opts := make(map[string]string)
+	set := make(map[string]bool)
+	for k, v := range plan {
+		opts[k] = v
+		set[strings.ToLower(k)] = true
+	}
+	for k, v := range params {
+		if set[strings.ToLower(k)] {
+			return nil, types.NewErrorf(http.StatusBadRequest,
+				"parameter %q is set by the plan", k).WithDetail("parameter", k)
+		}
+		opts[k] = fmt.Sprint(v)
+	}
+	return opts, nil
+}
+
+// pathID returns a path variable, writing a failed response when it is not
+// a valid ID
+func pathID(w http.ResponseWriter, r *http.Request, key string) (string, bool) {
+	id := mux.Vars(r)[key]
+	if !validID.MatchString(id) {
+		writeErr(w, types.NewErrorf(http.StatusBadRequest, "invalid %s %q", key, id))
+		return "", false
+	}
+	return id, true
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	b, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(b, req); err != nil {
+		writeErr(w, types.NewError(http.StatusBadRequest, "json is unparsable"))
+		return false
+	}
+	return true
+}
+
+// writeErr writes a failed response with the status of an admin API error.
+// The broker error code, such as ConcurrencyError, is the error detail.
+func writeErr(w http.ResponseWriter, err error) {
+	status := types.ErrorStatus(err)
+	res := &errorResponse{Description: err.Error()}
+	if e, ok := err.(*types.Error); ok {
+		res.Error, _ = e.Details["error"].(string)
+	}
+	if status >= http.StatusInternalServerError {
+		log.WithError(err).Warn("service broker request failed")
+	}
+	writeJSON(w, status, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	j, _ := json.Marshal(v)
+	w.Write(j)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emccode/polly/core/volumes/volumestest"
+	"github.com/stretchr/testify/assert"
+)
+
+// controller stands in for the Cloud Controller calling the broker
+type controller struct {
+	t   *testing.T
+	url string
+}
+
+func (c *controller) call(method, path string, body, reply interface{}) int {
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, c.url+path, bytes.NewReader(b))
+	req.SetBasicAuth("cc", "secret")
+	req.Header.Set(apiVersionHeader, "2.14")
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(c.t, err) {
+		return 0
+	}
+	defer res.Body.Close()
+	if reply != nil {
+		assert.NoError(c.t, json.NewDecoder(res.Body).Decode(reply), path)
+	}
+	return res.StatusCode
+}
+
+func TestBroker(t *testing.T) {
+	vols := volumestest.New()
+	rtr := newRouter(func(context.Context) Volumes { return vols },
+		"cloudfoundry", "polly", map[string]map[string]string{
+			"gold":   {"service": "ebs", "volumetype": "io1"},
+			"silver": {},
+		})
+	rtr.username, rtr.password = "cc", "secret"
+	srv := httptest.NewServer(rtr.authenticate(rtr.r))
+	defer srv.Close()
+	cc := &controller{t: t, url: srv.URL}
+
+	res, err := http.Get(srv.URL + "/v2/catalog")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		res.Body.Close()
+	}
+
+	cat := &catalog{}
+	assert.Equal(t, http.StatusOK, cc.call("GET", "/v2/catalog", nil, cat))
+	if assert.Len(t, cat.Services, 1) && assert.Len(t, cat.Services[0].Plans, 2) {
+		assert.Equal(t, "polly-volume-gold", cat.Services[0].Plans[0].ID)
+		assert.Equal(t, []string{"volume_mount"}, cat.Services[0].Requires)
+	}
+
+	prov := &provisionRequest{ServiceID: serviceID, PlanID: "polly-volume-silver"}
+	assert.Equal(t, 422, cc.call("PUT", "/v2/service_instances/i1", prov, nil))
+
+	// the parameters may not override the plan
+	for _, params := range []map[string]interface{}{
+		{"service": "gce"},
+		{"VolumeType": "gp2"},
+	} {
+		prov = &provisionRequest{
+			ServiceID: serviceID, PlanID: "polly-volume-gold", Parameters: params}
+		assert.Equal(t, http.StatusBadRequest,
+			cc.call("PUT", "/v2/service_instances/i1", prov, nil), params)
+	}
+	assert.Empty(t, vols.Created)
+
+	// a slow create is polled
+	vols.Release = make(chan struct{})
+	prov = &provisionRequest{
+		ServiceID:  serviceID,
+		PlanID:     "polly-volume-gold",
+		SpaceGUID:  "space",
+		Parameters: map[string]interface{}{"size": 16, "tier": "fast"},
+	}
+	async := &asyncResponse{}
+	assert.Equal(t, http.StatusAccepted, cc.call("PUT",
+		"/v2/service_instances/i1?accepts_incomplete=true", prov, async))
+	assert.Equal(t, "provision", async.Operation)
+	op := &operation{}
+	cc.call("GET", "/v2/service_instances/i1/last_operation", nil, op)
+	assert.Equal(t, inProgress, op.State)
+	assert.Equal(t, 422, cc.call("DELETE", "/v2/service_instances/i1", nil, nil))
+
+	close(vols.Release)
+	rtr.wg.Wait()
+	cc.call("GET", "/v2/service_instances/i1/last_operation", nil, op)
+	assert.Equal(t, succeeded, op.State)
+	vol := vols.Vols["ebs-vol-i1"]
+	if !assert.NotNil(t, vol) {
+		return
+	}
+	assert.Equal(t, "io1", vol.Type)
+	assert.EqualValues(t, 16, vol.Size)
+	assert.Equal(t, "fast", vol.Labels["tier"])
+	assert.Equal(t, "i1", vol.Labels[instanceLabel])
+	assert.Equal(t, []string{"ebs"}, vol.Schedulers)
+
+	assert.Equal(t, http.StatusOK,
+		cc.call("PUT", "/v2/service_instances/i1", prov, nil))
+	prov.PlanID = "polly-volume-silver"
+	assert.Equal(t, http.StatusConflict,
+		cc.call("PUT", "/v2/service_instances/i1", prov, nil))
+
+	// binding offers the volume to cloud foundry until the last unbind
+	bind := &bindRequest{ServiceID: serviceID, AppGUID: "app",
+		Parameters: map[string]interface{}{"readonly": true}}
+	vols.Stale = map[string]bool{"cloudfoundry": true}
+	assert.Equal(t, http.StatusConflict, cc.call("PUT",
+		"/v2/service_instances/i1/service_bindings/b1", bind, nil))
+	assert.Equal(t, []string{"ebs"}, vol.Schedulers)
+	vols.Stale = nil
+	br := &bindResponse{}
+	assert.Equal(t, http.StatusCreated, cc.call("PUT",
+		"/v2/service_instances/i1/service_bindings/b1", bind, br))
+	if assert.Len(t, br.VolumeMounts, 1) {
+		vm := br.VolumeMounts[0]
+		assert.Equal(t, "polly", vm.Driver)
+		assert.Equal(t, "r", vm.Mode)
+		assert.Equal(t, "/var/vcap/data/i1", vm.ContainerDir)
+		assert.Equal(t, "ebs-vol-i1", vm.Device.VolumeID)
+	}
+	assert.Equal(t, http.StatusOK, cc.call("PUT",
+		"/v2/service_instances/i1/service_bindings/b1", bind, nil))
+	cc.call("PUT", "/v2/service_instances/i1/service_bindings/b2", bind, nil)
+	assert.Equal(t, []string{"ebs", "cloudfoundry"}, vol.Schedulers)
+	assert.Equal(t, 422, cc.call("DELETE", "/v2/service_instances/i1", nil, nil))
+
+	assert.Equal(t, http.StatusOK, cc.call("DELETE",
+		"/v2/service_instances/i1/service_bindings/b1", nil, nil))
+	assert.Equal(t, []string{"ebs", "cloudfoundry"}, vol.Schedulers)
+	assert.Equal(t, http.StatusGone, cc.call("DELETE",
+		"/v2/service_instances/i1/service_bindings/b1", nil, nil))
+	cc.call("DELETE", "/v2/service_instances/i1/service_bindings/b2", nil, nil)
+	assert.Equal(t, []string{"ebs"}, vol.Schedulers)
+
+	assert.Equal(t, http.StatusOK,
+		cc.call("DELETE", "/v2/service_instances/i1", nil, nil))
+	assert.Empty(t, vols.Vols)
+	assert.Equal(t, http.StatusGone,
+		cc.call("DELETE", "/v2/service_instances/i1", nil, nil))
+}
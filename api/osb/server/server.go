@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	ctypes "github.com/emccode/polly/core/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/gorilla/mux"
+)
+
+const (
+	enabledKey   = "polly.osb.enabled"
+	hostKey      = "polly.osb.host"
+	usernameKey  = "polly.osb.username"
+	passwordKey  = "polly.osb.password"
+	schedulerKey = "polly.osb.scheduler"
+	driverKey    = "polly.osb.driver"
+	plansKey     = "polly.osb.plans"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Volumes is the volume service the broker requests are mapped to
+type Volumes interface {
+	Volumes(vals url.Values) ([]*types.Volume, error)
+	VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, error)
+	VolumeRemove(volumeID string) error
+	VolumeUpdate(
+		volumeID, ifMatch string,
+		change func(vol *types.Volume) error) (*types.Volume, error)
+	Suspended(scheduler string) error
+}
+
+// Router serves the Open Service Broker API. A service instance is a volume,
+// binding it to an application offers it to the Cloud Foundry scheduler.
+type Router struct {
+	r *mux.Router
+
+	// vols returns the volume service scoped to a request
+	vols      func(ctx context.Context) Volumes
+	scheduler string
+	driver    string
+	username  string
+	password  string
+
+	// plans holds the create options of each plan of the catalog
+	plans map[string]map[string]string
+
+	// ops holds the last operation of the instances provisioned
+	// asynchronously, wg tracks those in flight
+	mu  sync.Mutex
+	ops map[string]*operation
+	wg  sync.WaitGroup
+
+	addr string
+	srv  *http.Server
+	errs chan error
+}
+
+// Enabled returns whether the service broker is configured
+func Enabled(config gofig.Config) bool {
+	return config.GetBool(enabledKey)
+}
+
+// newRouter creates the broker router for a scheduler, the volumes of
+// instances are mounted by the volume driver of the Cloud Foundry cells
+func newRouter(
+	vols func(ctx context.Context) Volumes,
+	scheduler, driver string,
+	plans map[string]map[string]string) *Router {
+
+	r := &Router{
+		r:         mux.NewRouter(),
+		vols:      vols,
+		scheduler: scheduler,
+		driver:    driver,
+		plans:     plans,
+		ops:       make(map[string]*operation),
+	}
+
+	r.r.HandleFunc("/v2/catalog", r.catalogHandler).Methods("GET")
+	r.r.HandleFunc("/v2/service_instances/{instanceID}",
+		r.provisionHandler).Methods("PUT")
+	r.r.HandleFunc("/v2/service_instances/{instanceID}",
+		r.deprovisionHandler).Methods("DELETE")
+	r.r.HandleFunc("/v2/service_instances/{instanceID}/last_operation",
+		r.lastOperationHandler).Methods("GET")
+	r.r.HandleFunc("/v2/service_instances/{instanceID}/service_bindings/{bindingID}",
+		r.bindHandler).Methods("PUT")
+	r.r.HandleFunc("/v2/service_instances/{instanceID}/service_bindings/{bindingID}",
+		r.unbindHandler).Methods("DELETE")
+
+	return r
+}
+
+// Start serves the broker on the configured address until Stop is called.
+// The catalog has a plan for each libStorage service unless plans are
+// configured.
+func Start(p *ctypes.Polly, vsc *volumes.Vsc) (*Router, error) {
+	plans := config.Sections(p.Config, plansKey)
+	if len(plans) == 0 {
+		for _, s := range p.LsClient.DriverService {
+			plans[strings.ToLower(s)] = map[string]string{"service": s}
+		}
+	}
+
+	// the broker keeps its state in the labels reserved for it
+	broker := vsc.WithReservedLabels()
+	r := newRouter(
+		func(ctx context.Context) Volumes { return broker.WithContext(ctx) },
+		p.Config.GetString(schedulerKey), p.Config.GetString(driverKey), plans)
+	r.username = p.Config.GetString(usernameKey)
+	r.password = p.Config.GetString(passwordKey)
+
+	if err := r.listen(p.Config.GetString(hostKey)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (rtr *Router) listen(host string) error {
+	proto, lAddr, err := gotil.ParseAddress(host)
+	if err != nil {
+		return goof.WithFieldE("host", host, "invalid service broker address", err)
+	}
+	l, err := net.Listen(proto, lAddr)
+	if err != nil {
+		return goof.WithFieldE("host", host, "cannot listen on service broker address", err)
+	}
+	rtr.addr = l.Addr().String()
+	rtr.srv = &http.Server{Handler: rtr.authenticate(rtr.r)}
+	rtr.errs = make(chan error, 1)
+
+	go func() {
+		err := rtr.srv.Serve(l)
+		if err != http.ErrServerClosed {
+			rtr.errs <- goof.WithFieldE("host", host, "service broker listener failed", err)
+		}
+		close(rtr.errs)
+	}()
+
+	log.WithFields(log.Fields{
+		"host":      host,
+		"scheduler": rtr.scheduler,
+		"plans":     rtr.planNames(),
+	}).Info("serving open service broker")
+	return nil
+}
+
+// authenticate checks the basic auth credentials of the Cloud Controller,
+// when set, and the broker API version of the requests
+func (rtr *Router) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rtr.username != "" {
+			u, p, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(u), []byte(rtr.username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(p), []byte(rtr.password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="polly"`)
+				writeErr(w, types.NewError(http.StatusUnauthorized, "unauthorized"))
+				return
+			}
+		}
+		if v := r.Header.Get(apiVersionHeader); !strings.HasPrefix(v, "2.") {
+			writeErr(w, types.NewErrorf(http.StatusPreconditionFailed,
+				"unsupported broker API version %q, expected 2.x", v))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// planNames returns the sorted plan names of the catalog
+func (rtr *Router) planNames() []string {
+	var names []string
+	for name := range rtr.plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Err returns a channel that receives an error if the broker listener fails.
+// The channel is closed once the listener has stopped.
+func (rtr *Router) Err() <-chan error {
+	return rtr.errs
+}
+
+// Stop closes the broker listener, waiting for in-flight requests and
+// provisions to complete or for the context to be done
+func (rtr *Router) Stop(ctx context.Context) error {
+	if err := rtr.srv.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rtr.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Open Service Broker")
+	r.Key(gofig.Bool, "", false,
+		"Serve the Open Service Broker API", enabledKey)
+	r.Key(gofig.String, "", "tcp://127.0.0.1:7982",
+		"The address of the service broker", hostKey)
+	r.Key(gofig.String, "", "",
+		"The user name of the Cloud Controller", usernameKey)
+	r.Key(gofig.SecureString, "", "",
+		"The password of the Cloud Controller", passwordKey)
+	r.Key(gofig.String, "", "cloudfoundry",
+		"The scheduler the bound volumes are offered to", schedulerKey)
+	r.Key(gofig.String, "", "polly",
+		"The volume driver of the Cloud Foundry cells", driverKey)
+	return r
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequestIDHeader carries the identifier of an admin API request
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	// ErrCodeInvalid indicates a malformed request
+	ErrCodeInvalid = "invalid"
+	// ErrCodeUnauthorized indicates a request without valid credentials
+	ErrCodeUnauthorized = "unauthorized"
+	// ErrCodeForbidden indicates a request that is not permitted
+	ErrCodeForbidden = "forbidden"
+	// ErrCodeNotFound indicates an unknown volume, service or route
+	ErrCodeNotFound = "notFound"
+	// ErrCodeMethodNotAllowed indicates a method a route does not support
+	ErrCodeMethodNotAllowed = "methodNotAllowed"
+	// ErrCodeConflict indicates a request conflicting with the current state
+	ErrCodeConflict = "conflict"
+	// ErrCodePreconditionFailed indicates a resource modified since the
+	// caller read it
+	ErrCodePreconditionFailed = "preconditionFailed"
+	// ErrCodeUnsupportedMediaType indicates a body of an unsupported type
+	ErrCodeUnsupportedMediaType = "unsupportedMediaType"
+	// ErrCodeUnprocessable indicates a request missing mandatory elements
+	ErrCodeUnprocessable = "unprocessable"
+	// ErrCodeInternal indicates a failure of Polly or of its dependencies
+	ErrCodeInternal = "internal"
+	// ErrCodeNotImplemented indicates an operation that is not available yet
+	ErrCodeNotImplemented = "notImplemented"
+)
+
+// errCodes are the error codes of the HTTP statuses
+var errCodes = map[int]string{
+	http.StatusBadRequest:           ErrCodeInvalid,
+	http.StatusUnauthorized:         ErrCodeUnauthorized,
+	http.StatusForbidden:            ErrCodeForbidden,
+	http.StatusNotFound:             ErrCodeNotFound,
+	http.StatusMethodNotAllowed:     ErrCodeMethodNotAllowed,
+	http.StatusConflict:             ErrCodeConflict,
+	http.StatusPreconditionFailed:   ErrCodePreconditionFailed,
+	http.StatusUnsupportedMediaType: ErrCodeUnsupportedMediaType,
+	422:                             ErrCodeUnprocessable,
+	http.StatusInternalServerError:  ErrCodeInternal,
+	http.StatusNotImplemented:       ErrCodeNotImplemented,
+}
+
+// Error is the body of every admin API error response. It is also returned
+// by the admin client so callers can check the reason of a failure.
+type Error struct {
+	// Status is the HTTP status of the response
+	Status int `json:"-"`
+
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"requestID,omitempty"`
+}
+
+// NewError returns an error with the code of the HTTP status
+func NewError(status int, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    ErrCode(status),
+		Message: message,
+	}
+}
+
+// NewErrorf returns an error with the code of the HTTP status and a
+// formatted message
+func NewErrorf(status int, format string, a ...interface{}) *Error {
+	return NewError(status, fmt.Sprintf(format, a...))
+}
+
+// ErrCode returns the error code of an HTTP status
+func ErrCode(status int) string {
+	if c, ok := errCodes[status]; ok {
+		return c
+	}
+	if status >= 400 && status < 500 {
+		return ErrCodeInvalid
+	}
+	return ErrCodeInternal
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Message
+}
+
+// WithDetail adds a detail to the error and returns it
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// AsError returns err as an admin API error, an internal error when it is
+// not one
+func AsError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return NewError(http.StatusInternalServerError, err.Error())
+}
+
+// ErrorStatus returns the HTTP status of an error, 500 when err is not an
+// admin API error
+func ErrorStatus(err error) int {
+	if e, ok := err.(*Error); ok && e.Status != 0 {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// IsInvalid returns whether err rejects a malformed request
+func IsInvalid(err error) bool {
+	return ErrorStatus(err) == http.StatusBadRequest
+}
+
+// IsUnauthorized returns whether err rejects missing or invalid credentials
+func IsUnauthorized(err error) bool {
+	return ErrorStatus(err) == http.StatusUnauthorized
+}
+
+// IsForbidden returns whether err denies a request
+func IsForbidden(err error) bool {
+	return ErrorStatus(err) == http.StatusForbidden
+}
+
+// IsNotFound returns whether err reports an unknown volume or service
+func IsNotFound(err error) bool {
+	return ErrorStatus(err) == http.StatusNotFound
+}
+
+// IsConflict returns whether err reports a conflict with the current state
+func IsConflict(err error) bool {
+	return ErrorStatus(err) == http.StatusConflict
+}
+
+// IsPreconditionFailed returns whether err reports a resource modified since
+// the caller read it
+func IsPreconditionFailed(err error) bool {
+	return ErrorStatus(err) == http.StatusPreconditionFailed
+}
@@ -0,0 +1,90 @@
+package types
+
+// The Mesos types are the JSON form of the Mesos v1 scheduler API messages
+// the volume offer feed is made of. Volumes are disk resources of the
+// polly resource provider, reserved for the role of the scheduler.
+
+const (
+	// MesosEventOffers is the type of the events carrying new offers
+	MesosEventOffers = "OFFERS"
+	// MesosEventRescind is the type of the events rescinding an offer
+	MesosEventRescind = "RESCIND"
+	// MesosEventHeartbeat is the type of the events keeping a feed alive
+	MesosEventHeartbeat = "HEARTBEAT"
+)
+
+// MesosValue is a Mesos identifier
+type MesosValue struct {
+	Value string `json:"value"`
+}
+
+// MesosEvent is an event of the volume offer feed
+type MesosEvent struct {
+	Type    string        `json:"type"`
+	Offers  *MesosOffers  `json:"offers,omitempty"`
+	Rescind *MesosRescind `json:"rescind,omitempty"`
+}
+
+// MesosOffers holds the offers of an OFFERS event
+type MesosOffers struct {
+	Offers []*MesosOffer `json:"offers"`
+}
+
+// MesosRescind holds the offer of a RESCIND event
+type MesosRescind struct {
+	OfferID *MesosValue `json:"offer_id"`
+}
+
+// MesosOffer offers a volume to a framework
+type MesosOffer struct {
+	ID          *MesosValue      `json:"id"`
+	FrameworkID *MesosValue      `json:"framework_id,omitempty"`
+	Resources   []*MesosResource `json:"resources"`
+}
+
+// MesosResource is the disk resource of a volume
+type MesosResource struct {
+	ProviderID   *MesosValue         `json:"provider_id"`
+	Name         string              `json:"name"`
+	Type         string              `json:"type"`
+	Scalar       *MesosScalar        `json:"scalar"`
+	Reservations []*MesosReservation `json:"reservations,omitempty"`
+	Disk         *MesosDisk          `json:"disk"`
+	Labels       *MesosLabels        `json:"labels,omitempty"`
+}
+
+// MesosScalar is the size of a disk resource in MB
+type MesosScalar struct {
+	Value float64 `json:"value"`
+}
+
+// MesosReservation reserves a resource for a role
+type MesosReservation struct {
+	Type string `json:"type"`
+	Role string `json:"role"`
+}
+
+// MesosDisk describes the volume backing a disk resource
+type MesosDisk struct {
+	Source *MesosDiskSource `json:"source"`
+}
+
+// MesosDiskSource identifies a volume by its Polly volume ID, the profile is
+// the libStorage service
+type MesosDiskSource struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Vendor  string `json:"vendor"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// MesosLabels are the admin labels of a volume
+type MesosLabels struct {
+	Labels []*MesosLabel `json:"labels"`
+}
+
+// MesosLabel is a label of a resource
+type MesosLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
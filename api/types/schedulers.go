@@ -0,0 +1,58 @@
+package types
+
+import "time"
+
+const (
+	// SchedulerTypeMesos is the type of the Mesos frameworks
+	SchedulerTypeMesos = "mesos"
+	// SchedulerTypeKubernetes is the type of the Kubernetes clusters
+	SchedulerTypeKubernetes = "k8s"
+	// SchedulerTypeSwarm is the type of the Docker Swarm clusters
+	SchedulerTypeSwarm = "swarm"
+	// SchedulerTypeCloudFoundry is the type of the Cloud Foundry platforms
+	SchedulerTypeCloudFoundry = "cloudfoundry"
+	// SchedulerTypeOther is the type of any other scheduler
+	SchedulerTypeOther = "other"
+)
+
+const (
+	// SchedulerStateLive is the state of the schedulers seen recently
+	SchedulerStateLive = "live"
+	// SchedulerStateWarning is the state of the schedulers not seen for the
+	// warning period
+	SchedulerStateWarning = "warning"
+	// SchedulerStateStale is the state of the schedulers not seen for the
+	// stale period, whose offers are revoked or suspended
+	SchedulerStateStale = "stale"
+)
+
+// SchedulerTypes are the types of the registered schedulers
+var SchedulerTypes = []string{
+	SchedulerTypeMesos,
+	SchedulerTypeKubernetes,
+	SchedulerTypeSwarm,
+	SchedulerTypeCloudFoundry,
+	SchedulerTypeOther,
+}
+
+// Scheduler is a scheduler registered to receive volume offers
+type Scheduler struct {
+	// Name is the name volumes are offered to
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Contact string `json:"contact,omitempty"`
+
+	// Tenant is the tenant the scheduler is registered to, it is kept in
+	// the schedulers of the tenant
+	Tenant string `json:"tenant,omitempty"`
+
+	Created time.Time `json:"created"`
+
+	// LastSeen is the time of the last heartbeat of the scheduler, nil
+	// before the first one
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+
+	// State is the liveness of the scheduler given its last heartbeat, or
+	// its registration before the first one
+	State string `json:"state,omitempty"`
+}
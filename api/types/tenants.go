@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// Tenant is a business unit or project owning volumes and schedulers. The
+// admin requests authenticated by a token of a tenant only see its volumes
+// and may only offer them to its schedulers.
+type Tenant struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Schedulers are the schedulers registered to the tenant, a scheduler
+	// belongs to at most one tenant
+	Schedulers []string `json:"schedulers,omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+// TenantToken is a bearer token of the admin API restricted to a tenant
+type TenantToken struct {
+	ID     string `json:"id"`
+	Tenant string `json:"tenant"`
+
+	// Token is only returned when the token is created, the store keeps its
+	// hash
+	Token string `json:"token,omitempty"`
+
+	Created time.Time `json:"created"`
+}
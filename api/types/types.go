@@ -11,6 +11,60 @@ type VersionResponse struct {
 	VersionPollyBuild        string `json:"versionPollyBuild,omitempty"`
 }
 
+const (
+	// HealthStatusOK indicates a healthy daemon or dependency
+	HealthStatusOK = "ok"
+	// HealthStatusFail indicates an unhealthy daemon or dependency
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck is the status of a single Polly dependency
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthResponse is the status of the Polly daemon and its dependencies
+type HealthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// ContinueHeader carries the token of the next page of a volume listing
+const ContinueHeader = "Polly-Continue"
+
+// InventoryTimeHeader carries the time the volumes of a listing were listed
+// from libStorage
+const InventoryTimeHeader = "Polly-Inventory-Time"
+
+// VolumeListOptions select, order and page a volume listing
+type VolumeListOptions struct {
+	// Filter is a filter expression selecting the volumes
+	Filter string
+
+	// Sort is the key volumes are ordered by, descending when prefixed by -
+	Sort string
+
+	// Limit is the maximum number of volumes of a page, all when zero
+	Limit int
+
+	// Continue is the token of the page to return
+	Continue string
+
+	// Fresh lists the volumes from libStorage rather than from the cache
+	Fresh bool
+}
+
+// ConfigReloadResponse reports the configuration keys changed by a reload
+type ConfigReloadResponse struct {
+	// Applied are the keys whose new value is in effect
+	Applied []string `json:"applied,omitempty"`
+
+	// RestartRequired are the keys whose new value is only used after the
+	// daemon restarts
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
+
 // VolumeOfferRequest contains offer information
 type VolumeOfferRequest struct {
 	VolumeID   string   `json:"volumeID,omitempty"`
@@ -35,6 +89,93 @@ type VolumeLabelsRemoveRequest struct {
 	Labels   []string `json:"labels,omitempty"`
 }
 
+// Lease reserves a volume for the Mesos framework that accepted its offer
+type Lease struct {
+	VolumeID    string `json:"volumeID"`
+	Scheduler   string `json:"scheduler"`
+	FrameworkID string `json:"frameworkID"`
+
+	// Expires is the unix time the lease ends unless the offer is accepted
+	// again, 0 when it does not expire
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// OfferState is the lease of a volume and the frameworks that declined its
+// offer, with the unix time until which it is not offered to them again
+type OfferState struct {
+	Lease    *Lease           `json:"lease,omitempty"`
+	Declines map[string]int64 `json:"declines,omitempty"`
+}
+
+// OfferAcceptRequest accepts a volume offer on behalf of a framework
+type OfferAcceptRequest struct {
+	Scheduler   string `json:"scheduler"`
+	FrameworkID string `json:"frameworkID"`
+}
+
+// OfferDeclineRequest declines a volume offer on behalf of a framework,
+// the volume is not offered to it again for RefuseSeconds
+type OfferDeclineRequest struct {
+	FrameworkID   string  `json:"frameworkID"`
+	RefuseSeconds float64 `json:"refuseSeconds,omitempty"`
+}
+
+// VolumeMergePatch is the JSON merge patch of the metadata of a volume. The
+// labels are merged, a null value removing the label, and the schedulers
+// replace those the volume is offered to.
+type VolumeMergePatch struct {
+	Labels     map[string]*string `json:"labels,omitempty"`
+	Schedulers []string           `json:"schedulers,omitempty"`
+}
+
+const (
+	// BulkOffer offers the volumes to the schedulers of a bulk request
+	BulkOffer = "offer"
+	// BulkRevoke revokes the offer of the volumes from the schedulers
+	BulkRevoke = "revoke"
+	// BulkLabel creates the labels of a bulk request on the volumes
+	BulkLabel = "label"
+	// BulkUnlabel removes the label keys of a bulk request from the volumes
+	BulkUnlabel = "unlabel"
+	// BulkRemove removes the volumes
+	BulkRemove = "remove"
+)
+
+// VolumeBulkRequest applies one operation to many volumes, either those
+// listed by ID or those matching a filter expression
+type VolumeBulkRequest struct {
+	Operation  string            `json:"operation"`
+	VolumeIDs  []string          `json:"volumeIDs,omitempty"`
+	Filter     string            `json:"filter,omitempty"`
+	Schedulers []string          `json:"schedulers,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	LabelKeys  []string          `json:"labelKeys,omitempty"`
+
+	// DryRun reports the selected volumes and the result of the operation
+	// without applying it
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// VolumeBulkResult is the outcome of a bulk operation on a single volume
+type VolumeBulkResult struct {
+	VolumeID string `json:"volumeID"`
+
+	// Volume is the volume after the operation, or as it would be after a
+	// dry run. It is empty when the operation failed or removed the volume.
+	Volume *Volume `json:"volume,omitempty"`
+
+	Error *Error `json:"error,omitempty"`
+}
+
+// VolumeBulkResponse reports the outcome of a bulk operation
+type VolumeBulkResponse struct {
+	Operation string              `json:"operation"`
+	DryRun    bool                `json:"dryRun,omitempty"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []*VolumeBulkResult `json:"results"`
+}
+
 // VolumeCreateRequest creates a volume
 type VolumeCreateRequest struct {
 	ServiceName      string            `json:"service,omitempty"`
@@ -46,6 +187,9 @@ type VolumeCreateRequest struct {
 	Schedulers       []string          `json:"schedulers,omitempty"`
 	Labels           map[string]string `json:"labels,omitempty"`
 	Fields           map[string]string `json:"fields,omitempty"`
+
+	// Tenant owns the volume, the tenant of the credentials when empty
+	Tenant string `json:"tenant,omitempty"`
 }
 
 // Volume is a storage libStorage Volume with Polly annotations
@@ -63,6 +207,13 @@ type Volume struct {
 
 	// Labels are (admin)user applied via API
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// Tenant owns the volume, none when empty
+	Tenant string `json:"tenant,omitempty"`
+
+	// Created is the time in seconds since the epoch Polly first saved the
+	// volume metadata
+	Created int64 `json:"created,omitempty"`
 }
 
 // Snapshot is a libStorage Volume snap with Polly annotations
@@ -72,6 +223,9 @@ type Snapshot struct {
 	// SnapshotID is the Polly SnapshotID
 	SnapshotID string `json:"snapshotid,omitempty"`
 
+	// SourceVolumeID is the Polly VolumeID of the snapshotted volume
+	SourceVolumeID string `json:"sourceVolumeID,omitempty"`
+
 	// the Storage provider identifier
 	ServiceName string `json:"serviceName,omitempty"`
 
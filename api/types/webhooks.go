@@ -0,0 +1,121 @@
+package types
+
+import "time"
+
+const (
+	// EventVolumeCreate is the type of the events of created volumes
+	EventVolumeCreate = "volume.create"
+	// EventVolumeRemove is the type of the events of removed volumes
+	EventVolumeRemove = "volume.remove"
+	// EventVolumeOffer is the type of the events of volumes offered to
+	// schedulers
+	EventVolumeOffer = "volume.offer"
+	// EventVolumeRevoke is the type of the events of volume offers revoked
+	// from schedulers
+	EventVolumeRevoke = "volume.revoke"
+	// EventVolumeLabel is the type of the events of volumes whose admin
+	// labels changed
+	EventVolumeLabel = "volume.label"
+	// EventVolumeLease is the type of the events of volumes leased to a
+	// framework
+	EventVolumeLease = "volume.lease"
+	// EventVolumeRelease is the type of the events of released or expired
+	// volume leases
+	EventVolumeRelease = "volume.release"
+	// EventSchedulerWarning is the type of the events of schedulers not seen
+	// for the warning period, about to go stale
+	EventSchedulerWarning = "scheduler.warning"
+	// EventSchedulerStale is the type of the events of schedulers not seen
+	// for the stale period, whose offers are acted upon
+	EventSchedulerStale = "scheduler.stale"
+	// EventSchedulerLive is the type of the events of warned or stale
+	// schedulers seen again
+	EventSchedulerLive = "scheduler.live"
+)
+
+// EventTypes are the types of the volume lifecycle and scheduler liveness
+// events
+var EventTypes = []string{
+	EventVolumeCreate,
+	EventVolumeRemove,
+	EventVolumeOffer,
+	EventVolumeRevoke,
+	EventVolumeLabel,
+	EventVolumeLease,
+	EventVolumeRelease,
+	EventSchedulerWarning,
+	EventSchedulerStale,
+	EventSchedulerLive,
+}
+
+const (
+	// WebhookEventHeader carries the type of the event of a webhook call
+	WebhookEventHeader = "X-Polly-Event"
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the payload of a
+	// webhook call keyed by the secret of the webhook, as sha256=<hex>
+	WebhookSignatureHeader = "X-Polly-Signature"
+)
+
+// Webhook is an HTTP endpoint called on volume lifecycle events
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+
+	// Events are the types of the events delivered, all of them when empty
+	Events []string `json:"events,omitempty"`
+
+	// Secret keys the signature of the payloads, it is only returned when
+	// the webhook is created
+	Secret string `json:"secret,omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+// WebhookCreateRequest registers a webhook, a secret is generated when none
+// is set
+type WebhookCreateRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// Event is a volume lifecycle or scheduler liveness event, the payload of
+// the webhook calls
+type Event struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// VolumeID is the volume of a volume event
+	VolumeID string `json:"volumeID,omitempty"`
+
+	// Volume is the volume once changed, missing for removed volumes
+	Volume *Volume `json:"volume,omitempty"`
+
+	// Schedulers are the schedulers a volume was offered to or revoked from
+	Schedulers []string `json:"schedulers,omitempty"`
+
+	// Lease is the lease of a lease or release event
+	Lease *Lease `json:"lease,omitempty"`
+
+	// Scheduler is the scheduler of a scheduler event
+	Scheduler *Scheduler `json:"scheduler,omitempty"`
+
+	// RequestID is the ID of the admin request causing the event
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	EventID   string    `json:"eventID"`
+	EventType string    `json:"eventType"`
+	Attempt   int       `json:"attempt"`
+	Time      time.Time `json:"time"`
+
+	// Status is the HTTP status of the reply, missing when the call failed
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// Duration is the time the call took in seconds
+	Duration float64 `json:"duration"`
+}
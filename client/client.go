@@ -12,6 +12,16 @@ type Client interface {
 	// VolumesAll returns all volumes
 	VolumesAll() ([]*types.Volume, error)
 
+	// VolumesFilter returns the registered volumes matching a filter
+	VolumesFilter(filter string) ([]*types.Volume, error)
+
+	// VolumesAllFilter returns all volumes matching a filter
+	VolumesAllFilter(filter string) ([]*types.Volume, error)
+
+	// VolumesList returns the registered, or all when all is set, volumes
+	// selected and ordered by the options
+	VolumesList(all bool, opts *types.VolumeListOptions) ([]*types.Volume, error)
+
 	// VolumeInspect will retrieve details about a volume
 	VolumeInspect(volumeID string) (*types.Volume, error)
 
@@ -27,9 +37,84 @@ type Client interface {
 	// VolumeLabelsRemove removes labels from a volume
 	VolumeLabelsRemove(volumeID string, labels []string) (*types.Volume, error)
 
+	// VolumesBulk applies one operation to many volumes
+	VolumesBulk(req *types.VolumeBulkRequest) (*types.VolumeBulkResponse, error)
+
+	// VolumesBulkLabel creates key=value labels on many volumes
+	VolumesBulkLabel(req *types.VolumeBulkRequest, labels []string) (*types.VolumeBulkResponse, error)
+
 	// VolumeCreate creates a volume
 	VolumeCreate(service, name, volumeType string, size, IOPS int64, availabilityZone string, schedulers, labels, fields []string) (*types.Volume, error)
 
 	// VolumeRemove removes a volume
 	VolumeRemove(volumeID string) error
+
+	// Health returns the liveness of the Polly daemon
+	Health() (*types.HealthResponse, error)
+
+	// Ready returns the readiness of the Polly daemon and its dependencies
+	Ready() (*types.HealthResponse, error)
+
+	// Config returns the effective configuration of the Polly daemon
+	Config() (map[string]interface{}, error)
+
+	// ConfigReload makes the Polly daemon read its configuration again
+	ConfigReload() (*types.ConfigReloadResponse, error)
+
+	// Webhooks returns the registered webhooks
+	Webhooks() ([]*types.Webhook, error)
+
+	// WebhookCreate registers a webhook called on the events of the types,
+	// all of them when empty
+	WebhookCreate(url, secret string, events []string) (*types.Webhook, error)
+
+	// WebhookRemove removes a webhook
+	WebhookRemove(id string) error
+
+	// WebhookDeliveries returns the last deliveries to a webhook
+	WebhookDeliveries(id string) ([]*types.WebhookDelivery, error)
+
+	// Tenants returns the tenants
+	Tenants() ([]*types.Tenant, error)
+
+	// TenantCreate creates a tenant owning the schedulers
+	TenantCreate(name, description string, schedulers []string) (*types.Tenant, error)
+
+	// TenantUpdate replaces the description and the schedulers of a tenant
+	TenantUpdate(name, description string, schedulers []string) (*types.Tenant, error)
+
+	// TenantRemove removes a tenant owning no volume
+	TenantRemove(name string) error
+
+	// TenantTokens returns the tokens of a tenant
+	TenantTokens(name string) ([]*types.TenantToken, error)
+
+	// TenantTokenCreate creates a bearer token restricted to a tenant
+	TenantTokenCreate(name string) (*types.TenantToken, error)
+
+	// TenantTokenRemove removes a token of a tenant
+	TenantTokenRemove(name, id string) error
+
+	// Schedulers returns the registered schedulers
+	Schedulers() ([]*types.Scheduler, error)
+
+	// SchedulerCreate registers a scheduler of a type, owned by the tenant
+	// if set
+	SchedulerCreate(name, schedulerType, contact, tenant string) (*types.Scheduler, error)
+
+	// SchedulerInspect returns a registered scheduler
+	SchedulerInspect(name string) (*types.Scheduler, error)
+
+	// SchedulerUpdate replaces the type, contact and tenant of a scheduler
+	SchedulerUpdate(name, schedulerType, contact, tenant string) (*types.Scheduler, error)
+
+	// SchedulerRemove unregisters a scheduler
+	SchedulerRemove(name string) error
+
+	// SchedulerHeartbeat records that a scheduler is alive
+	SchedulerHeartbeat(name string) (*types.Scheduler, error)
+
+	// SchedulerVolumes returns the volumes offered to a scheduler selected
+	// and ordered by the options
+	SchedulerVolumes(name string, opts *types.VolumeListOptions) ([]*types.Volume, error)
 }
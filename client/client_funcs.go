@@ -2,6 +2,7 @@ package client
 
 import (
 	"github.com/emccode/polly/api/types"
+	"net/http"
 	"strings"
 )
 
@@ -13,6 +14,19 @@ func (c *pc) VolumesAll() ([]*types.Volume, error) {
 	return c.Client.VolumesAll()
 }
 
+func (c *pc) VolumesFilter(filter string) ([]*types.Volume, error) {
+	return c.Client.VolumesFilter(filter)
+}
+
+func (c *pc) VolumesAllFilter(filter string) ([]*types.Volume, error) {
+	return c.Client.VolumesAllFilter(filter)
+}
+
+func (c *pc) VolumesList(
+	all bool, opts *types.VolumeListOptions) ([]*types.Volume, error) {
+	return c.Client.VolumesList(all, opts)
+}
+
 func (c *pc) VolumeInspect(volumeID string) (*types.Volume, error) {
 	return c.Client.VolumeInspect(volumeID)
 }
@@ -43,21 +57,44 @@ func splitLabel(label string) (key, value string) {
 	return kv[0], kv[1]
 }
 
-func labelMap(labels []string) map[string]string {
+// labelMap splits key=value labels, the empty values of a list flag are
+// skipped and any other value without a key is rejected
+func labelMap(labels []string) (map[string]string, error) {
 	lm := make(map[string]string)
 	for _, l := range labels {
-		if k, v := splitLabel(l); k != "" {
-			lm[k] = v
+		if l == "" {
+			continue
+		}
+		k, v := splitLabel(l)
+		if k == "" {
+			return nil, types.NewErrorf(http.StatusBadRequest,
+				"invalid label %q, expected key=value", l).WithDetail("label", l)
+		}
+		lm[k] = v
+	}
+	return lm, nil
+}
+
+// nonEmpty drops the empty values of a list flag
+func nonEmpty(s []string) []string {
+	var r []string
+	for _, e := range s {
+		if e != "" {
+			r = append(r, e)
 		}
 	}
-	return lm
+	return r
 }
 
 func (c *pc) VolumeLabel(volumeID string,
 	labels []string) (*types.Volume, error) {
+	lm, err := labelMap(labels)
+	if err != nil {
+		return nil, err
+	}
 	lc := &types.VolumeLabelRequest{
 		VolumeID: volumeID,
-		Labels:   labelMap(labels),
+		Labels:   lm,
 	}
 	return c.Client.VolumeLabel(lc)
 }
@@ -71,10 +108,36 @@ func (c *pc) VolumeLabelsRemove(volumeID string,
 	return c.Client.VolumeLabelsRemove(lc)
 }
 
+// VolumesBulk applies one operation to many volumes
+func (c *pc) VolumesBulk(
+	req *types.VolumeBulkRequest) (*types.VolumeBulkResponse, error) {
+	return c.Client.VolumesBulk(req)
+}
+
+// VolumesBulkLabel creates key=value labels on many volumes
+func (c *pc) VolumesBulkLabel(req *types.VolumeBulkRequest,
+	labels []string) (*types.VolumeBulkResponse, error) {
+	lm, err := labelMap(labels)
+	if err != nil {
+		return nil, err
+	}
+	req.Operation = types.BulkLabel
+	req.Labels = lm
+	return c.Client.VolumesBulk(req)
+}
+
 // VolumeCreate creates a volume
 func (c *pc) VolumeCreate(service, name, volumeType string,
 	size, IOPS int64, availabilityZone string,
 	schedulers, labels, fields []string) (*types.Volume, error) {
+	lm, err := labelMap(labels)
+	if err != nil {
+		return nil, err
+	}
+	fm, err := labelMap(fields)
+	if err != nil {
+		return nil, err
+	}
 	lc := &types.VolumeCreateRequest{
 		ServiceName:      service,
 		Name:             name,
@@ -83,8 +146,8 @@ func (c *pc) VolumeCreate(service, name, volumeType string,
 		IOPS:             IOPS,
 		AvailabilityZone: availabilityZone,
 		Schedulers:       schedulers,
-		Labels:           labelMap(labels),
-		Fields:           labelMap(fields),
+		Labels:           lm,
+		Fields:           fm,
 	}
 	return c.Client.VolumeCreate(lc)
 }
@@ -93,3 +156,141 @@ func (c *pc) VolumeCreate(service, name, volumeType string,
 func (c *pc) VolumeRemove(volumeID string) error {
 	return c.Client.VolumeRemove(volumeID)
 }
+
+// Health returns the liveness of the Polly daemon
+func (c *pc) Health() (*types.HealthResponse, error) {
+	return c.Client.Health()
+}
+
+// Ready returns the readiness of the Polly daemon and its dependencies
+func (c *pc) Ready() (*types.HealthResponse, error) {
+	return c.Client.Ready()
+}
+
+// Config returns the effective configuration of the Polly daemon
+func (c *pc) Config() (map[string]interface{}, error) {
+	return c.Client.Config()
+}
+
+// ConfigReload makes the Polly daemon read its configuration again
+func (c *pc) ConfigReload() (*types.ConfigReloadResponse, error) {
+	return c.Client.ConfigReload()
+}
+
+// Webhooks returns the registered webhooks
+func (c *pc) Webhooks() ([]*types.Webhook, error) {
+	return c.Client.Webhooks()
+}
+
+// WebhookCreate registers a webhook
+func (c *pc) WebhookCreate(
+	url, secret string, events []string) (*types.Webhook, error) {
+	return c.Client.WebhookCreate(&types.WebhookCreateRequest{
+		URL:    url,
+		Secret: secret,
+		Events: nonEmpty(events),
+	})
+}
+
+// WebhookRemove removes a webhook
+func (c *pc) WebhookRemove(id string) error {
+	return c.Client.WebhookRemove(id)
+}
+
+// WebhookDeliveries returns the last deliveries to a webhook
+func (c *pc) WebhookDeliveries(id string) ([]*types.WebhookDelivery, error) {
+	return c.Client.WebhookDeliveries(id)
+}
+
+// Tenants returns the tenants
+func (c *pc) Tenants() ([]*types.Tenant, error) {
+	return c.Client.Tenants()
+}
+
+// TenantCreate creates a tenant
+func (c *pc) TenantCreate(
+	name, description string, schedulers []string) (*types.Tenant, error) {
+	return c.Client.TenantCreate(&types.Tenant{
+		Name:        name,
+		Description: description,
+		Schedulers:  nonEmpty(schedulers),
+	})
+}
+
+// TenantUpdate replaces the description and the schedulers of a tenant
+func (c *pc) TenantUpdate(
+	name, description string, schedulers []string) (*types.Tenant, error) {
+	return c.Client.TenantUpdate(&types.Tenant{
+		Name:        name,
+		Description: description,
+		Schedulers:  nonEmpty(schedulers),
+	})
+}
+
+// TenantRemove removes a tenant
+func (c *pc) TenantRemove(name string) error {
+	return c.Client.TenantRemove(name)
+}
+
+// TenantTokens returns the tokens of a tenant
+func (c *pc) TenantTokens(name string) ([]*types.TenantToken, error) {
+	return c.Client.TenantTokens(name)
+}
+
+// TenantTokenCreate creates a token of a tenant
+func (c *pc) TenantTokenCreate(name string) (*types.TenantToken, error) {
+	return c.Client.TenantTokenCreate(name)
+}
+
+// TenantTokenRemove removes a token of a tenant
+func (c *pc) TenantTokenRemove(name, id string) error {
+	return c.Client.TenantTokenRemove(name, id)
+}
+
+// Schedulers returns the registered schedulers
+func (c *pc) Schedulers() ([]*types.Scheduler, error) {
+	return c.Client.Schedulers()
+}
+
+// SchedulerCreate registers a scheduler
+func (c *pc) SchedulerCreate(
+	name, schedulerType, contact, tenant string) (*types.Scheduler, error) {
+	return c.Client.SchedulerCreate(&types.Scheduler{
+		Name:    name,
+		Type:    schedulerType,
+		Contact: contact,
+		Tenant:  tenant,
+	})
+}
+
+// SchedulerInspect returns a registered scheduler
+func (c *pc) SchedulerInspect(name string) (*types.Scheduler, error) {
+	return c.Client.SchedulerInspect(name)
+}
+
+// SchedulerUpdate replaces the type, contact and tenant of a scheduler
+func (c *pc) SchedulerUpdate(
+	name, schedulerType, contact, tenant string) (*types.Scheduler, error) {
+	return c.Client.SchedulerUpdate(&types.Scheduler{
+		Name:    name,
+		Type:    schedulerType,
+		Contact: contact,
+		Tenant:  tenant,
+	})
+}
+
+// SchedulerRemove unregisters a scheduler
+func (c *pc) SchedulerRemove(name string) error {
+	return c.Client.SchedulerRemove(name)
+}
+
+// SchedulerHeartbeat records that a scheduler is alive
+func (c *pc) SchedulerHeartbeat(name string) (*types.Scheduler, error) {
+	return c.Client.SchedulerHeartbeat(name)
+}
+
+// SchedulerVolumes returns the volumes offered to a scheduler
+func (c *pc) SchedulerVolumes(
+	name string, opts *types.VolumeListOptions) ([]*types.Volume, error) {
+	return c.Client.SchedulerVolumes(name, opts)
+}
@@ -27,6 +27,7 @@ const (
 	logRequestsKey       = "polly.client.http.logging.logrequest"
 	logResponsesKey      = "polly.client.http.logging.logresponse"
 	disableKeepAlivesKey = "polly.client.http.disableKeepAlives"
+	tokenKey             = "polly.client.token"
 )
 
 type pc struct {
@@ -55,10 +56,15 @@ func New(config gofig.Config) (Client, error) {
 		return nil, err
 	}
 
+	headers := http.Header{}
+	if token := config.GetString(tokenKey); token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
 	return &pc{
 		Client: apiclient.Client{
 			Host:         getHost(proto, lAddr, tlsConfig),
-			Headers:      http.Header{},
+			Headers:      headers,
 			LogRequests:  config.GetBool(logRequestsKey),
 			LogResponses: config.GetBool(logResponsesKey),
 			Client: &http.Client{
@@ -94,5 +100,7 @@ func registerConfig() {
 	r.Key(gofig.Bool, "", false, "", logRequestsKey)
 	r.Key(gofig.Bool, "", false, "", logResponsesKey)
 	r.Key(gofig.Bool, "", false, "", disableKeepAlivesKey)
+	r.Key(gofig.SecureString, "", "",
+		"The bearer token of the admin requests", tokenKey)
 	gofig.Register(r)
 }
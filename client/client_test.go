@@ -11,8 +11,10 @@ import (
 	"fmt"
 
 	"github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
 	config "github.com/emccode/polly/core/config"
 	"github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/volumes"
 	"github.com/emccode/polly/daemon"
 	"github.com/stretchr/testify/assert"
 )
@@ -82,6 +84,18 @@ func TestPolly(t *testing.T) {
 	assert.NotEqual(t, tpc, nil)
 }
 
+func TestReady(t *testing.T) {
+	h, err := tpc.Ready()
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, "ok", h.Status)
+	assert.Contains(t, h.Checks, "admin")
+	assert.Contains(t, h.Checks, "store")
+	assert.Contains(t, h.Checks, "libstorage")
+}
+
 func TestVolumesAll(t *testing.T) {
 	vols, err := tpc.VolumesAll()
 
@@ -111,6 +125,22 @@ func TestVolumeInspect(t *testing.T) {
 	assert.Equal(t, "mockservice", vol.ServiceName)
 }
 
+func TestVolumeInspectErrors(t *testing.T) {
+	_, err := tpc.VolumeInspect("nosuchservice-vol-000")
+	assert.True(t, types.IsNotFound(err))
+	if e, ok := err.(*types.Error); assert.True(t, ok) {
+		assert.Equal(t, types.ErrCodeNotFound, e.Code)
+		assert.Equal(t, "nosuchservice-vol-000", e.Details["volumeID"])
+		assert.NotEmpty(t, e.RequestID)
+	}
+
+	_, err = tpc.VolumeInspect("novolumeid")
+	assert.True(t, types.IsInvalid(err))
+
+	_, err = tpc.VolumeOffer("nosuchservice-vol-000", []string{"mesos"})
+	assert.True(t, types.IsNotFound(err))
+}
+
 func TestVolumeOffer(t *testing.T) {
 	vol, err := tpc.VolumeOffer("mock-vol-001", []string{"mesos"})
 	assert.NoError(t, err)
@@ -184,6 +214,37 @@ func TestVolumeOfferRevoke(t *testing.T) {
 	assert.Len(t, vol.Schedulers, 0)
 }
 
+func TestOfferAcceptRevoked(t *testing.T) {
+	vol, err := tpc.VolumeOffer("mock-vol-001", []string{"mesos0", "mesos1"})
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	ac := &tpc.(*pc).Client
+	_, err = ac.OfferAccept(volumes.OfferID(vol),
+		&types.OfferAcceptRequest{Scheduler: "mesos0", FrameworkID: "fw0"})
+	assert.NoError(t, err)
+
+	// revoking the offer to mesos0 releases the lease of its framework
+	vol, err = tpc.VolumeOfferRevoke("mock-vol-001", []string{"mesos0"})
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	lease, err := ac.OfferAccept(volumes.OfferID(vol),
+		&types.OfferAcceptRequest{Scheduler: "mesos1", FrameworkID: "fw1"})
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, "mesos1", lease.Scheduler)
+	assert.Equal(t, "fw1", lease.FrameworkID)
+
+	_, err = tpc.VolumeOfferRevoke("mock-vol-001", []string{"mesos1"})
+	assert.NoError(t, err)
+}
+
 func TestVolumeLabel(t *testing.T) {
 	vol, err := tpc.VolumeInspect("mock-vol-000")
 	assert.NoError(t, err)
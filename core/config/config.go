@@ -2,6 +2,10 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	gofig "github.com/akutz/gofig"
 	goof "github.com/akutz/goof"
@@ -52,3 +56,79 @@ func NewWithConfig(yamlConfig string) (gofig.Config, error) {
 
 	return cfg, nil
 }
+
+// Sections returns the named sections of settings under key, such as
+// storage classes, keyed by their lower cased names
+func Sections(config gofig.Config, key string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	for name, settings := range stringMap(config.Get(key)) {
+		section := make(map[string]string)
+		for k, v := range stringMap(settings) {
+			section[k] = fmt.Sprint(v)
+		}
+		sections[strings.ToLower(name)] = section
+	}
+	return sections
+}
+
+// Duration returns the duration set at key, def when it is not set, not a
+// valid duration or not positive
+func Duration(config gofig.Config, key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(config.GetString(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// Decode decodes the settings under key, such as a list of rules, into v
+// through their JSON form. Nothing is decoded when the key is not set.
+func Decode(config gofig.Config, key string, v interface{}) error {
+	s := config.Get(key)
+	if s == nil {
+		return nil
+	}
+	j, err := json.Marshal(normalize(s))
+	if err != nil {
+		return goof.WithFieldE("key", key, "invalid settings", err)
+	}
+	if err := json.Unmarshal(j, v); err != nil {
+		return goof.WithFieldE("key", key, "invalid settings", err)
+	}
+	return nil
+}
+
+// normalize converts the maps read from YAML to maps keyed by strings
+func normalize(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, iv := range stringMap(tv) {
+			m[k] = normalize(iv)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(tv))
+		for i, iv := range tv {
+			l[i] = normalize(iv)
+		}
+		return l
+	}
+	return v
+}
+
+func stringMap(v interface{}) map[string]interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		return tv
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for k, iv := range tv {
+			if sk, ok := k.(string); ok {
+				m[sk] = iv
+			}
+		}
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuration(t *testing.T) {
+	cfg, err := NewWithConfig(`
+polly:
+  interval: 90s
+  negative: -1s
+  invalid: soon
+`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 90*time.Second, Duration(cfg, "polly.interval", time.Minute))
+	assert.Equal(t, time.Minute, Duration(cfg, "polly.negative", time.Minute))
+	assert.Equal(t, time.Minute, Duration(cfg, "polly.invalid", time.Minute))
+	assert.Equal(t, time.Minute, Duration(cfg, "polly.unset", time.Minute))
+}
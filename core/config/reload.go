@@ -0,0 +1,213 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	gofig "github.com/akutz/gofig"
+	goof "github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
+)
+
+// Redacted replaces the value of secret keys in the effective configuration
+const Redacted = "******"
+
+// secretWords mark keys whose values are never returned by Settings
+var secretWords = []string{
+	"password", "secret", "token", "credential", "accesskey", "privatekey",
+}
+
+// Loader returns a freshly read configuration
+type Loader func() (gofig.Config, error)
+
+// FileLoader returns a loader reading the global and user configuration
+// files, followed by path when it is not empty
+func FileLoader(path string) Loader {
+	return func() (gofig.Config, error) {
+		cfg, err := New()
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			if err := cfg.ReadConfigFile(path); err != nil {
+				return nil, goof.WithFieldE(
+					"path", path, "problem reading config", err)
+			}
+		}
+		return cfg, nil
+	}
+}
+
+type handler struct {
+	prefix string
+	apply  func(gofig.Config) error
+}
+
+// Reloader applies a freshly loaded configuration to a running daemon. Keys
+// with a registered handler take effect in place, any other changed key is
+// reported as requiring a restart.
+type Reloader struct {
+	mu       sync.Mutex
+	config   gofig.Config
+	load     Loader
+	handlers []*handler
+}
+
+// NewReloader returns a reloader updating the effective config of a daemon
+func NewReloader(config gofig.Config, load Loader) *Reloader {
+	return &Reloader{
+		config: config,
+		load:   load,
+	}
+}
+
+// Handle registers a function applying the keys under prefix in place. The
+// function receives the freshly loaded configuration.
+func (r *Reloader) Handle(prefix string, apply func(gofig.Config) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, &handler{
+		prefix: strings.ToLower(prefix),
+		apply:  apply,
+	})
+}
+
+// handler returns the handler with the longest prefix matching key
+func (r *Reloader) handler(key string) *handler {
+	var match *handler
+	for _, h := range r.handlers {
+		if key != h.prefix && !strings.HasPrefix(key, h.prefix+".") {
+			continue
+		}
+		if match == nil || len(h.prefix) > len(match.prefix) {
+			match = h
+		}
+	}
+	return match
+}
+
+// Reload reads the configuration again and applies the changed keys that
+// can be applied in place to the effective config. When a handler fails the
+// keys of the handlers that were applied before it are still set, and the
+// partial response is returned alongside the error.
+func (r *Reloader) Reload() (*types.ConfigReloadResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &types.ConfigReloadResponse{}
+	var handlers []*handler
+	keys := make(map[*handler][]string)
+	for _, k := range Diff(r.config, cfg) {
+		h := r.handler(k)
+		if h == nil {
+			res.RestartRequired = append(res.RestartRequired, k)
+			continue
+		}
+		if _, ok := keys[h]; !ok {
+			handlers = append(handlers, h)
+		}
+		keys[h] = append(keys[h], k)
+	}
+
+	for _, h := range handlers {
+		if err := h.apply(cfg); err != nil {
+			sort.Strings(res.Applied)
+			return res, goof.WithFieldE(
+				"key", h.prefix, "problem applying config", err)
+		}
+		for _, k := range keys[h] {
+			r.config.Set(k, cfg.Get(k))
+			res.Applied = append(res.Applied, k)
+		}
+	}
+	sort.Strings(res.Applied)
+
+	log.WithFields(log.Fields{
+		"applied":         res.Applied,
+		"restartRequired": res.RestartRequired,
+	}).Info("reloaded config")
+
+	return res, nil
+}
+
+// Settings returns the effective configuration as flattened keys with the
+// values of secret keys redacted
+func (r *Reloader) Settings() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	settings := flatten("", r.config.AllSettings())
+	for k := range settings {
+		if isSecret(k) {
+			settings[k] = Redacted
+		}
+	}
+	return settings
+}
+
+// Diff returns the sorted keys whose value differs between two configs
+func Diff(current, loaded gofig.Config) []string {
+	o := flatten("", current.AllSettings())
+	n := flatten("", loaded.AllSettings())
+
+	var keys []string
+	for k, v := range o {
+		if nv, ok := n[k]; !ok || !reflect.DeepEqual(v, nv) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func flatten(prefix string, m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range m {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch tv := v.(type) {
+		case map[string]interface{}:
+			for fk, fv := range flatten(key, tv) {
+				out[fk] = fv
+			}
+		case map[interface{}]interface{}:
+			sm := make(map[string]interface{}, len(tv))
+			for ik, iv := range tv {
+				if sk, ok := ik.(string); ok {
+					sm[sk] = iv
+				}
+			}
+			for fk, fv := range flatten(key, sm) {
+				out[fk] = fv
+			}
+		default:
+			out[key] = v
+		}
+	}
+	return out
+}
+
+func isSecret(key string) bool {
+	k := strings.ToLower(key)
+	for _, w := range secretWords {
+		if strings.Contains(k, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	gofig "github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	reloadConfigBefore = `
+polly:
+  logLevel: warn
+  host: tcp://127.0.0.1:7978
+  reconciler:
+    purge: false
+scaleio:
+  password: before
+`
+	reloadConfigAfter = `
+polly:
+  logLevel: debug
+  host: tcp://127.0.0.1:7979
+  reconciler:
+    purge: true
+scaleio:
+  password: after
+`
+)
+
+func TestReload(t *testing.T) {
+	cfg, err := NewWithConfig(reloadConfigBefore)
+	assert.NoError(t, err)
+
+	r := NewReloader(cfg, func() (gofig.Config, error) {
+		return NewWithConfig(reloadConfigAfter)
+	})
+
+	var applied int
+	r.Handle("polly.logLevel", func(c gofig.Config) error {
+		applied++
+		assert.Equal(t, "debug", c.GetString("polly.logLevel"))
+		return nil
+	})
+	r.Handle("polly.reconciler", func(gofig.Config) error {
+		applied++
+		return nil
+	})
+
+	res, err := r.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.Equal(t,
+		[]string{"polly.loglevel", "polly.reconciler.purge"}, res.Applied)
+	assert.Equal(t,
+		[]string{"polly.host", "scaleio.password"}, res.RestartRequired)
+
+	assert.Equal(t, "debug", cfg.GetString("polly.logLevel"))
+	assert.Equal(t, "tcp://127.0.0.1:7978", cfg.GetString("polly.host"))
+
+	settings := r.Settings()
+	assert.Equal(t, Redacted, settings["scaleio.password"])
+	assert.Equal(t, "tcp://127.0.0.1:7978", settings["polly.host"])
+}
+
+func TestReloadFailure(t *testing.T) {
+	cfg, err := NewWithConfig(reloadConfigBefore)
+	assert.NoError(t, err)
+
+	r := NewReloader(cfg, func() (gofig.Config, error) {
+		return NewWithConfig(reloadConfigAfter)
+	})
+
+	r.Handle("polly.logLevel", func(gofig.Config) error {
+		return nil
+	})
+	r.Handle("polly.reconciler", func(gofig.Config) error {
+		return errors.New("bad reconciler")
+	})
+
+	res, err := r.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, []string{"polly.loglevel"}, res.Applied)
+	assert.Equal(t, "debug", cfg.GetString("polly.logLevel"))
+	assert.Equal(t, false, cfg.GetBool("polly.reconciler.purge"))
+}
@@ -1,25 +1,42 @@
 package core
 
 import (
+	gocontext "context"
 	"fmt"
+	"sync"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gofig"
 	"github.com/akutz/goof"
 	"github.com/emccode/libstorage/api/context"
 	apivolroute "github.com/emccode/libstorage/api/server/router/volume"
 	apitypes "github.com/emccode/libstorage/api/types"
 	adminserver "github.com/emccode/polly/api/admin/server"
+	csiserver "github.com/emccode/polly/api/csi/server"
+	dockerserver "github.com/emccode/polly/api/docker/server"
+	osbserver "github.com/emccode/polly/api/osb/server"
 	catypes "github.com/emccode/polly/api/types"
 	"github.com/emccode/polly/core/libstorage/client"
 	// "github.com/emccode/polly/core/libstorage/server"
 	pcontext "github.com/emccode/polly/api/context"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/kubernetes"
+	"github.com/emccode/polly/core/leader"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/schedulers"
 	store "github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/tenants"
+	"github.com/emccode/polly/core/tracing"
 	ctypes "github.com/emccode/polly/core/types"
+	"github.com/emccode/polly/core/volumes"
+	"github.com/emccode/polly/core/webhooks"
 	util "github.com/emccode/polly/util"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
+	"time"
 )
 
-//NewWithConfigFile init the lib
+// NewWithConfigFile init the lib
 func NewWithConfigFile(path string) (*ctypes.Polly, error) {
 	config := gofig.New()
 	if err := config.ReadConfigFile(path); err != nil {
@@ -54,15 +71,50 @@ func NewWithConfig(config gofig.Config) *ctypes.Polly {
 	}
 }
 
-// Start starts the Polly core services and returns
-func Start(p *ctypes.Polly) error {
+// Service is a running instance of the Polly core services
+type Service struct {
+	p     *ctypes.Polly
+	vsc   *volumes.Vsc
+	admin *adminserver.Router
+	errs  chan error
+	done  chan struct{}
+	once  sync.Once
+
+	// docker serves the Docker volume plugin, nil when disabled
+	docker *dockerserver.Router
+
+	// csi serves the CSI identity and controller services, nil when disabled
+	csi *csiserver.Server
+
+	// osb serves the Open Service Broker API, nil when disabled
+	osb *osbserver.Router
+
+	// stopTracing flushes the recorded spans
+	stopTracing func(gocontext.Context) error
+}
+
+// Start starts the Polly core services and returns a handle used to stop them
+func Start(p *ctypes.Polly) (*Service, error) {
 	scfg, _ := p.Config.Copy()
 	ps, err := store.NewWithConfig(scfg.Scope("polly.store"))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	p.Store = ps
 
+	// cleanups undo the steps taken so far, they run in reverse order when a
+	// later step fails
+	cleanups := []func(){ps.Close}
+	fail := func(err error) (*Service, error) {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+		return nil, err
+	}
+	if err := tenants.Check(p.Config, ps); err != nil {
+		return fail(err)
+	}
+
 	lcfg, _ := p.Config.Copy()
 
 	ctx := context.Background()
@@ -75,9 +127,20 @@ func Start(p *ctypes.Polly) error {
 		for k, v := range lcfg.AllSettings() {
 			ctx.Errorf("%s=%v", k, v)
 		}
-		return err
+		return fail(err)
 	}
 	p.LsClient = lsc
+	cleanups = append(cleanups, func() { lsc.Close() })
+
+	// the volume service and its inventory cache are shared by the admin
+	// API and the leader jobs
+	vsc := volumes.New(p)
+	if err := vsc.LoadLabelSchema(p.Config); err != nil {
+		return fail(err)
+	}
+
+	// events are queued until the dispatcher starts along with the services
+	p.Webhooks = webhooks.New(p.Config, ps)
 
 	// filterVolume sets a filter in place for requests
 	filterVolume := func(
@@ -86,6 +149,13 @@ func Start(p *ctypes.Polly) error {
 		store apitypes.Store,
 		volume *apitypes.Volume) (bool, error) {
 
+		// the ID of the admin request causing the call is a log field of
+		// the context, so the filter logs can be tied to the admin logs
+		if id := req.Header.Get(pcontext.RequestIDHeaderKey.String()); id != "" &&
+			ctx.Value(pcontext.RequestIDHeaderKey) == nil {
+			ctx = ctx.WithValue(pcontext.RequestIDHeaderKey, id)
+		}
+
 		// set the polly volume settings
 		volumeNew, err := client.NewVolume(lsc, volume, context.MustService(ctx).Name())
 		if err != nil {
@@ -103,29 +173,283 @@ func Start(p *ctypes.Polly) error {
 		if rt.GetName() == "volumeCreate" {
 			// establish new volume metadata for new libstorage inbound requests
 			ctx.WithField("route", rt).Debug("volumes create route")
+			vsc.Invalidate()
 			volumeNew.Schedulers = []string{context.MustService(ctx).Name()}
 			err = p.Store.SaveVolumeMetadata(volumeNew)
 			if err != nil {
 				return false, goof.WithError("failed to save metadata", err)
 			}
+			if rp != "admin" {
+				// volumes created by the admin API are notified by the
+				// volume service
+				vsc.Created(volumeNew)
+			}
 
-			return updateVolume(ctx, p, volumeNew, volume, true, rp)
+			return updateVolume(ctx, p, vsc, volumeNew, volume, true, rp)
 		} else if rp == "admin" {
 			ctx.WithField("requestPath", rp).Debug("volumes from admin request")
-			return updateVolume(ctx, p, volumeNew, volume, false, rp)
+			return updateVolume(ctx, p, vsc, volumeNew, volume, false, rp)
 		}
 		ctx.WithField("requestPath", rp).Debug("volumes from non-admin request")
 
-		return updateVolume(ctx, p, volumeNew, volume, true, rp)
+		return updateVolume(ctx, p, vsc, volumeNew, volume, true, rp)
 	}
 
-	apivolroute.OnVolume = filterVolume
+	setFilter(vsc, func(
+		ctx apitypes.Context,
+		req *http.Request,
+		store apitypes.Store,
+		volume *apitypes.Volume) (bool, error) {
+
+		start := time.Now()
+		ok, err := filterVolume(ctx, req, store, volume)
+		metrics.ObserveFilter(routeName(ctx), filterResult(ok, err), start)
+		tracing.Record(tracing.Extract(req.Context(), req.Header),
+			"libstorage.filter", start, err,
+			attribute.String("route", routeName(ctx)),
+			attribute.Bool("accepted", ok))
+		return ok, err
+	})
+	cleanups = append(cleanups, func() { clearFilter(vsc) })
+
+	var pvSync *kubernetes.Syncer
+	if kubernetes.Enabled(p.Config) {
+		if pvSync, err = kubernetes.New(p.Config, vsc); err != nil {
+			return fail(err)
+		}
+	}
 
-	_ = adminserver.Start(p)
+	stopTracing, err := tracing.Start(p.Config)
+	if err != nil {
+		return fail(err)
+	}
+	cleanups = append(cleanups, func() { stopTracing(gocontext.Background()) })
+
+	admin, err := adminserver.Start(p, vsc)
+	if err != nil {
+		return fail(err)
+	}
+	cleanups = append(cleanups, func() { admin.Stop(gocontext.Background()) })
+
+	var docker *dockerserver.Router
+	if dockerserver.Enabled(p.Config) {
+		if docker, err = dockerserver.Start(p, vsc); err != nil {
+			return fail(err)
+		}
+		cleanups = append(cleanups, func() { docker.Stop(gocontext.Background()) })
+	}
+	var csi *csiserver.Server
+	if csiserver.Enabled(p.Config) {
+		if csi, err = csiserver.Start(p, vsc); err != nil {
+			return fail(err)
+		}
+		cleanups = append(cleanups, func() { csi.Stop(gocontext.Background()) })
+	}
+	var osb *osbserver.Router
+	if osbserver.Enabled(p.Config) {
+		if osb, err = osbserver.Start(p, vsc); err != nil {
+			return fail(err)
+		}
+	}
+	vsc.Start()
+	p.Webhooks.Start()
+
+	if p.Reloader == nil {
+		p.Reloader = config.NewReloader(p.Config, config.FileLoader(""))
+	}
+	p.Reloader.Handle("polly.logLevel", applyLogLevel)
+	p.Reloader.Handle("polly.labels", vsc.LoadLabelSchema)
+	// the volume service reads the scheduler validation and staleness
+	// policy on every offer and liveness check
+	for _, k := range []string{"validate", "warnAfter", "staleAfter", "staleAction"} {
+		p.Reloader.Handle("polly.schedulers."+k, func(gofig.Config) error {
+			return nil
+		})
+	}
+	// the reconciler reads the purge setting on every pass
+	p.Reloader.Handle("polly.reconciler.purge", func(gofig.Config) error {
+		return nil
+	})
+
+	// every daemon serves requests, singleton jobs only run on the leader
+	p.Elector = leader.New(p.Config, ps)
+	p.Elector.Register(&leader.Job{
+		Name:     "reconciler",
+		Interval: volumes.ReconcileInterval(p.Config),
+		Run:      vsc.ReconcileJob,
+	})
+	p.Elector.Register(&leader.Job{
+		Name:     "leasereaper",
+		Interval: volumes.ReapInterval(p.Config),
+		Run:      vsc.ReapLeases,
+	})
+	p.Elector.Register(&leader.Job{
+		Name:     "schedulerliveness",
+		Interval: schedulers.CheckInterval(p.Config),
+		Run:      vsc.SchedulerLiveness,
+	})
+	if pvSync != nil {
+		p.Elector.Register(&leader.Job{
+			Name:     "pvsync",
+			Interval: kubernetes.Interval(p.Config),
+			Run:      pvSync.Sync,
+		})
+	}
+	p.Elector.Start()
+
+	s := &Service{
+		p:     p,
+		vsc:   vsc,
+		admin: admin,
+		errs:  make(chan error, 5),
+		done:  make(chan struct{}),
+
+		docker:      docker,
+		csi:         csi,
+		osb:         osb,
+		stopTracing: stopTracing,
+	}
+	go s.watch(admin.Err())
+	go s.watch(lsc.Err())
+	if docker != nil {
+		go s.watch(docker.Err())
+	}
+	if csi != nil {
+		go s.watch(csi.Err())
+	}
+	if osb != nil {
+		go s.watch(osb.Err())
+	}
+
+	return s, nil
+}
+
+// watch forwards the first error from a component channel until stopped
+func (s *Service) watch(errs <-chan error) {
+	if errs == nil {
+		return
+	}
+	select {
+	case err, ok := <-errs:
+		if ok && err != nil {
+			s.errs <- err
+		}
+	case <-s.done:
+	}
+}
+
+// Err returns a channel that receives an error when a core service fails
+func (s *Service) Err() <-chan error {
+	return s.errs
+}
+
+// Stop hands over the leadership, drains in-flight admin requests, then shuts
+// down the libStorage client, closes the store and flushes the trace spans.
+// Stop is safe to call more than once.
+func (s *Service) Stop(ctx gocontext.Context) error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+
+		s.p.Elector.Stop()
+
+		if e := s.admin.Stop(ctx); e != nil {
+			err = goof.WithError("problem stopping admin server", e)
+		}
+		if s.docker != nil {
+			if e := s.docker.Stop(ctx); e != nil && err == nil {
+				err = goof.WithError("problem stopping docker volume plugin", e)
+			}
+		}
+		if s.csi != nil {
+			if e := s.csi.Stop(ctx); e != nil && err == nil {
+				err = goof.WithError("problem stopping csi services", e)
+			}
+		}
+		if s.osb != nil {
+			if e := s.osb.Stop(ctx); e != nil && err == nil {
+				err = goof.WithError("problem stopping service broker", e)
+			}
+		}
+
+		clearFilter(s.vsc)
+		s.vsc.Close()
+
+		if e := s.p.Webhooks.Stop(ctx); e != nil && err == nil {
+			err = goof.WithError("problem delivering webhook events", e)
+		}
+
+		if e := s.p.LsClient.Close(); e != nil && err == nil {
+			err = goof.WithError("problem stopping libstorage", e)
+		}
+
+		s.p.Store.Close()
+
+		if e := s.stopTracing(ctx); e != nil && err == nil {
+			err = goof.WithError("problem flushing trace spans", e)
+		}
+	})
+	return err
+}
+
+var (
+	// filterMu guards the volume filter of libStorage, a package global,
+	// along with the volume service it belongs to
+	filterMu    sync.Mutex
+	filterOwner *volumes.Vsc
+)
+
+// setFilter installs the volume filter of a volume service
+func setFilter(
+	owner *volumes.Vsc,
+	filter func(apitypes.Context, *http.Request, apitypes.Store,
+		*apitypes.Volume) (bool, error)) {
+
+	filterMu.Lock()
+	defer filterMu.Unlock()
+	apivolroute.OnVolume = filter
+	filterOwner = owner
+}
+
+// clearFilter removes the volume filter if it still is the one of a volume
+// service, so stopping a service leaves the filter of another one in place
+func clearFilter(owner *volumes.Vsc) {
+	filterMu.Lock()
+	defer filterMu.Unlock()
+	if filterOwner != owner {
+		return
+	}
+	apivolroute.OnVolume = nil
+	filterOwner = nil
+}
+
+// applyLogLevel sets the log level of a reloaded config
+func applyLogLevel(config gofig.Config) error {
+	lvl, err := log.ParseLevel(config.GetString("polly.logLevel"))
+	if err != nil {
+		return err
+	}
+	log.SetLevel(lvl)
 	return nil
 }
 
-func updateVolume(ctx apitypes.Context, p *ctypes.Polly,
+func routeName(ctx apitypes.Context) string {
+	if rt, ok := context.Route(ctx); ok && rt != nil {
+		return rt.GetName()
+	}
+	return "unknown"
+}
+
+func filterResult(ok bool, err error) string {
+	if err != nil {
+		return "error"
+	} else if ok {
+		return "allowed"
+	}
+	return "filtered"
+}
+
+func updateVolume(ctx apitypes.Context, p *ctypes.Polly, vsc *volumes.Vsc,
 	volumeNew *catypes.Volume, volume *apitypes.Volume,
 	mustExist bool, rp string) (bool, error) {
 	if exists, err := p.Store.Exists(volumeNew); err != nil {
@@ -146,19 +470,37 @@ func updateVolume(ctx apitypes.Context, p *ctypes.Polly,
 		}
 	}
 
-	if !util.ContainsString(volumeNew.Schedulers, context.MustService(ctx).Name()) &&
-		rp != "admin" {
+	return offered(volumeNew, context.MustService(ctx).Name(), rp, vsc.Suspended)
+}
+
+// offered reports whether the libStorage service of a request sees a volume.
+// Admin requests see all volumes, the others those offered to the service,
+// unless the offers to it are suspended.
+func offered(vol *catypes.Volume, service, rp string,
+	suspended func(scheduler string) error) (bool, error) {
+
+	if rp == "admin" {
+		return true, nil
+	}
+	if !util.ContainsString(vol.Schedulers, service) {
 		return false, nil
 	}
-
+	if err := suspended(service); catypes.IsConflict(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
-// Run starts the Polly core services and blocks
+// Run starts the Polly core services and blocks until one of them fails
 func Run(p *ctypes.Polly) error {
-	if err := Start(p); err != nil {
-		goof.WithError("could not run polly core services", err)
+	s, err := Start(p)
+	if err != nil {
+		return goof.WithError("could not run polly core services", err)
 	}
 
-	select {}
+	err = <-s.Err()
+	s.Stop(gocontext.Background())
+	return err
 }
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	catypes "github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffered(t *testing.T) {
+	vol := &catypes.Volume{Schedulers: []string{"ebs", "marathon-1"}}
+	suspended := func(scheduler string) error {
+		if scheduler == "marathon-1" {
+			return catypes.NewErrorf(http.StatusConflict,
+				"offers to scheduler %q are suspended", scheduler)
+		}
+		return nil
+	}
+
+	for _, tc := range []struct {
+		service, rp string
+		ok          bool
+	}{
+		{"ebs", "", true},
+		{"k8s-1", "", false},
+		{"marathon-1", "", false},
+		{"marathon-1", "admin", true},
+		{"k8s-1", "admin", true},
+	} {
+		ok, err := offered(vol, tc.service, tc.rp, suspended)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ok, ok, tc.service+" "+tc.rp)
+	}
+
+	_, err := offered(vol, "ebs", "", func(string) error {
+		return errors.New("store down")
+	})
+	assert.Error(t, err)
+}
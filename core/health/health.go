@@ -0,0 +1,74 @@
+package health
+
+import (
+	"fmt"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/polly/api/types"
+	ctypes "github.com/emccode/polly/core/types"
+)
+
+// OK returns a passing check with an optional message
+func OK(message string) *types.HealthCheck {
+	return &types.HealthCheck{Status: types.HealthStatusOK, Message: message}
+}
+
+// Fail returns a failing check with the reason for the failure
+func Fail(message string) *types.HealthCheck {
+	return &types.HealthCheck{Status: types.HealthStatusFail, Message: message}
+}
+
+// Store checks that the store backend answers a read of the metadata version
+func Store(p *ctypes.Polly) *types.HealthCheck {
+	if p.Store == nil {
+		return Fail("store not initialized")
+	}
+
+	v, err := p.Store.Version()
+	if err != nil {
+		return Fail(fmt.Sprintf("%s: %v", p.Store.StoreType(), err))
+	}
+	return OK(fmt.Sprintf("%s %s", p.Store.StoreType(), v))
+}
+
+// LibStorage checks that the libStorage server lists its services
+func LibStorage(p *ctypes.Polly) *types.HealthCheck {
+	if p.LsClient == nil {
+		return Fail("libStorage client not initialized")
+	}
+
+	services, err := p.LsClient.API().Services(context.Background())
+	if err != nil {
+		return Fail(err.Error())
+	}
+	return OK(fmt.Sprintf("%d services", len(services)))
+}
+
+// Leader reports whether this daemon is the elected leader. Followers are
+// healthy and serve reads, so the check never fails.
+func Leader(p *ctypes.Polly) *types.HealthCheck {
+	if p.Elector == nil {
+		return OK("election not started")
+	}
+	if p.Elector.IsLeader() {
+		return OK(fmt.Sprintf("leader %s", p.Elector.ID()))
+	}
+	if l := p.Elector.Leader(); l != "" {
+		return OK(fmt.Sprintf("follower of %s", l))
+	}
+	return OK("follower")
+}
+
+// Response aggregates checks into a response that fails if any check fails
+func Response(checks map[string]*types.HealthCheck) *types.HealthResponse {
+	res := &types.HealthResponse{
+		Status: types.HealthStatusOK,
+		Checks: checks,
+	}
+	for _, c := range checks {
+		if c.Status != types.HealthStatusOK {
+			res.Status = types.HealthStatusFail
+		}
+	}
+	return res
+}
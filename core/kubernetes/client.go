@@ -0,0 +1,198 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+const (
+	// serviceAccountDir holds the credentials of the service account of a pod
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+	mergePatchType = "application/merge-patch+json"
+	pvPath         = "/api/v1/persistentvolumes"
+)
+
+// persistentVolume is the subset of a PersistentVolume the syncer manages
+type persistentVolume struct {
+	APIVersion string     `json:"apiVersion,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       pvSpec     `json:"spec"`
+	Status     *pvStatus  `json:"status,omitempty"`
+}
+
+type objectMeta struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type pvSpec struct {
+	Capacity         map[string]string `json:"capacity,omitempty"`
+	AccessModes      []string          `json:"accessModes,omitempty"`
+	ReclaimPolicy    string            `json:"persistentVolumeReclaimPolicy,omitempty"`
+	StorageClassName string            `json:"storageClassName,omitempty"`
+	CSI              *csiSource        `json:"csi,omitempty"`
+}
+
+type csiSource struct {
+	Driver       string `json:"driver"`
+	VolumeHandle string `json:"volumeHandle"`
+}
+
+type pvStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+type pvList struct {
+	Items []*persistentVolume `json:"items"`
+}
+
+// client calls the Kubernetes API server with the token of the service
+// account of the daemon
+type client struct {
+	server    string
+	token     string
+	tokenFile string
+	http      *http.Client
+}
+
+// newClient returns a client of the configured API server, the one of the
+// cluster the daemon runs in by default
+func newClient(config gofig.Config) (*client, error) {
+	c := &client{
+		server:    strings.TrimSuffix(config.GetString(apiServerKey), "/"),
+		token:     config.GetString(tokenKey),
+		tokenFile: config.GetString(tokenFileKey),
+	}
+	if c.server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, goof.New("no Kubernetes API server configured")
+		}
+		c.server = "https://" + net.JoinHostPort(host, port)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.GetBool(insecureKey)}
+	caFile := config.GetString(caFileKey)
+	if caFile == "" {
+		if _, err := os.Stat(serviceAccountDir + "ca.crt"); err == nil {
+			caFile = serviceAccountDir + "ca.crt"
+		}
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, goof.WithFieldE("caFile", caFile, "cannot read CA", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, goof.WithField("caFile", caFile, "no certificate in CA")
+		}
+	}
+
+	c.http = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return c, nil
+}
+
+// bearer returns the token of the requests, read again from the token file
+// on every request as projected tokens are rotated
+func (c *client) bearer() string {
+	if c.token != "" {
+		return c.token
+	}
+	if b, err := ioutil.ReadFile(c.tokenFile); err == nil {
+		return strings.TrimSpace(string(b))
+	}
+	return ""
+}
+
+// do sends a request to the API server and decodes the reply into v unless
+// it is nil
+func (c *client) do(method, path, contentType string, body, v interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.server+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if t := c.bearer(); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "Kubernetes API request failed", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		// errors are described by a Status object
+		status := &struct {
+			Message string `json:"message"`
+		}{}
+		json.NewDecoder(res.Body).Decode(status)
+		return goof.WithFields(goof.Fields{
+			"path":    path,
+			"status":  res.StatusCode,
+			"message": status.Message,
+		}, "Kubernetes API request rejected")
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// listPVs returns the PersistentVolumes matching a label selector
+func (c *client) listPVs(selector string) ([]*persistentVolume, error) {
+	l := &pvList{}
+	path := pvPath + "?labelSelector=" + url.QueryEscape(selector)
+	if err := c.do("GET", path, "", nil, l); err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *client) createPV(pv *persistentVolume) error {
+	return c.do("POST", pvPath, "application/json", pv, nil)
+}
+
+// patchPVLabels sets the labels of a PersistentVolume, those set to nil are
+// removed
+func (c *client) patchPVLabels(name string, labels map[string]*string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+	}
+	return c.do("PATCH", pvPath+"/"+name, mergePatchType, patch, nil)
+}
+
+func (c *client) deletePV(name string) error {
+	return c.do("DELETE", pvPath+"/"+name, "", nil, nil)
+}
@@ -0,0 +1,323 @@
+// Package kubernetes mirrors the volumes offered to a Kubernetes cluster
+// into PersistentVolume objects of the cluster
+package kubernetes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/volumes"
+)
+
+const (
+	enabledKey      = "polly.kubernetes.enabled"
+	apiServerKey    = "polly.kubernetes.apiServer"
+	tokenKey        = "polly.kubernetes.token"
+	tokenFileKey    = "polly.kubernetes.tokenFile"
+	caFileKey       = "polly.kubernetes.caFile"
+	insecureKey     = "polly.kubernetes.insecure"
+	clusterKey      = "polly.kubernetes.cluster"
+	storageClassKey = "polly.kubernetes.storageClass"
+	csiDriverKey    = "polly.kubernetes.csiDriver"
+	intervalKey     = "polly.kubernetes.interval"
+
+	// managedByLabel selects the PersistentVolumes mirroring Polly volumes
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "polly"
+
+	// volumeIDAnnotation holds the Polly volume ID of a PersistentVolume
+	volumeIDAnnotation = "polly.emccode.github.com/volume-id"
+
+	phaseReleased = "Released"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Volumes is the volume service the PersistentVolumes are mirrored from
+type Volumes interface {
+	Volumes(vals url.Values) ([]*types.Volume, error)
+	VolumeUpdate(
+		volumeID, ifMatch string,
+		change func(vol *types.Volume) error) (*types.Volume, error)
+	Suspended(scheduler string) error
+}
+
+// Syncer mirrors the volumes offered to the scheduler named after a cluster
+// into PersistentVolumes of the cluster. A PersistentVolume released by its
+// claim revokes the offer of its volume.
+type Syncer struct {
+	c            *client
+	vols         Volumes
+	cluster      string
+	storageClass string
+	csiDriver    string
+}
+
+// Enabled returns whether the PersistentVolume sync is configured
+func Enabled(config gofig.Config) bool {
+	return config.GetBool(enabledKey)
+}
+
+// Interval returns the configured time between two syncs
+func Interval(cfg gofig.Config) time.Duration {
+	return config.Duration(cfg, intervalKey, 30*time.Second)
+}
+
+// New returns a syncer of the configured cluster
+func New(config gofig.Config, vols Volumes) (*Syncer, error) {
+	c, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{
+		c:            c,
+		vols:         vols,
+		cluster:      config.GetString(clusterKey),
+		storageClass: config.GetString(storageClassKey),
+		csiDriver:    config.GetString(csiDriverKey),
+	}, nil
+}
+
+// Sync performs one pass: it revokes the offers of the released
+// PersistentVolumes, creates those of the newly offered volumes, updates
+// their labels and deletes those of the revoked offers. A failure on one
+// volume does not stop the pass, the last one is returned. No pass is made
+// while the offers to the cluster are suspended.
+func (s *Syncer) Sync() error {
+	if err := s.vols.Suspended(s.cluster); types.IsConflict(err) {
+		// the PersistentVolumes are left as they are until the cluster is
+		// seen again rather than deleted with the withheld offers
+		log.WithError(err).Debug("skipping sync of suspended cluster")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	vols, err := s.vols.Volumes(url.Values{volumes.SchedulersParam: {s.cluster}})
+	if err != nil {
+		return err
+	}
+	pvs, err := s.c.listPVs(managedByLabel + "=" + managedBy)
+	if err != nil {
+		return err
+	}
+
+	var last error
+	fail := func(err error, volumeID, mesg string) {
+		log.WithError(err).WithField("volumeID", volumeID).Warn(mesg)
+		last = err
+	}
+
+	offered := make(map[string]*types.Volume)
+	for _, vol := range vols {
+		offered[vol.VolumeID] = vol
+	}
+
+	// handled are the volumes with a PersistentVolume, including those
+	// released in this pass
+	handled := make(map[string]bool)
+	for _, pv := range pvs {
+		volumeID := pv.Metadata.Annotations[volumeIDAnnotation]
+		vol, ok := offered[volumeID]
+		handled[volumeID] = true
+
+		if pv.Status != nil && pv.Status.Phase == phaseReleased {
+			if ok {
+				if err := s.revoke(volumeID); err != nil {
+					fail(err, volumeID, "problem revoking released volume")
+					continue
+				}
+			}
+			if err := s.c.deletePV(pv.Metadata.Name); err != nil {
+				fail(err, volumeID, "problem deleting released PersistentVolume")
+				continue
+			}
+			log.WithField("volumeID", volumeID).Info(
+				"revoked offer of released PersistentVolume")
+			continue
+		}
+
+		if !ok {
+			if err := s.c.deletePV(pv.Metadata.Name); err != nil {
+				fail(err, volumeID, "problem deleting PersistentVolume")
+				continue
+			}
+			log.WithField("volumeID", volumeID).Info(
+				"deleted PersistentVolume of revoked offer")
+			continue
+		}
+
+		if patch := labelPatch(pv.Metadata.Labels, pvLabels(vol)); len(patch) > 0 {
+			if err := s.c.patchPVLabels(pv.Metadata.Name, patch); err != nil {
+				fail(err, volumeID, "problem updating PersistentVolume labels")
+			}
+		}
+	}
+
+	for _, vol := range vols {
+		if handled[vol.VolumeID] {
+			continue
+		}
+		if err := s.c.createPV(s.pv(vol)); err != nil {
+			fail(err, vol.VolumeID, "problem creating PersistentVolume")
+			continue
+		}
+		log.WithField("volumeID", vol.VolumeID).Info("created PersistentVolume")
+	}
+	return last
+}
+
+// revoke removes the cluster from the schedulers of a volume
+func (s *Syncer) revoke(volumeID string) error {
+	_, err := s.vols.VolumeUpdate(volumeID, "", func(vol *types.Volume) error {
+		var schedulers []string
+		for _, sched := range vol.Schedulers {
+			if sched != s.cluster {
+				schedulers = append(schedulers, sched)
+			}
+		}
+		vol.Schedulers = schedulers
+		return nil
+	})
+	if types.ErrorStatus(err) == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// pv returns the PersistentVolume of a volume, attached by the Polly CSI
+// driver
+func (s *Syncer) pv(vol *types.Volume) *persistentVolume {
+	return &persistentVolume{
+		APIVersion: "v1",
+		Kind:       "PersistentVolume",
+		Metadata: objectMeta{
+			Name:        pvName(vol.VolumeID),
+			Labels:      pvLabels(vol),
+			Annotations: map[string]string{volumeIDAnnotation: vol.VolumeID},
+		},
+		Spec: pvSpec{
+			Capacity:         map[string]string{"storage": fmt.Sprintf("%dGi", vol.Size)},
+			AccessModes:      []string{"ReadWriteOnce"},
+			ReclaimPolicy:    "Retain",
+			StorageClassName: s.storageClass,
+			CSI: &csiSource{
+				Driver:       s.csiDriver,
+				VolumeHandle: vol.VolumeID,
+			},
+		},
+	}
+}
+
+var (
+	invalidNameRx = regexp.MustCompile(`[^a-z0-9.-]+`)
+	labelNameRx   = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	labelPrefixRx = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// pvName returns the object name of the PersistentVolume of a volume. IDs
+// that are not valid names are made so and suffixed by a hash of the ID to
+// keep the names unique.
+func pvName(volumeID string) string {
+	name := "polly-" + strings.Trim(invalidNameRx.ReplaceAllString(
+		strings.ToLower(volumeID), "-"), "-.")
+	if name == "polly-"+volumeID && len(name) <= 253 {
+		return name
+	}
+	sum := sha1.Sum([]byte(volumeID))
+	if len(name) > 244 {
+		name = name[:244]
+	}
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
+
+// pvLabels returns the labels of the PersistentVolume of a volume, its admin
+// labels that are valid Kubernetes labels
+func pvLabels(vol *types.Volume) map[string]string {
+	labels := map[string]string{managedByLabel: managedBy}
+	for k, v := range vol.Labels {
+		if k == managedByLabel {
+			continue
+		}
+		if !validLabel(k, v) {
+			log.WithFields(log.Fields{
+				"volumeID": vol.VolumeID,
+				"label":    k,
+			}).Debug("skipping label invalid in Kubernetes")
+			continue
+		}
+		labels[k] = v
+	}
+	return labels
+}
+
+// validLabel returns whether a label is a valid Kubernetes label
+func validLabel(k, v string) bool {
+	name := k
+	if i := strings.LastIndex(k, "/"); i >= 0 {
+		prefix := k[:i]
+		if len(prefix) > 253 || !labelPrefixRx.MatchString(prefix) {
+			return false
+		}
+		name = k[i+1:]
+	}
+	if len(name) > 63 || !labelNameRx.MatchString(name) {
+		return false
+	}
+	return v == "" || len(v) <= 63 && labelNameRx.MatchString(v)
+}
+
+// labelPatch returns the merge patch of the labels turning have into want,
+// empty when they are equal
+func labelPatch(have, want map[string]string) map[string]*string {
+	patch := make(map[string]*string)
+	for k, v := range want {
+		if hv, ok := have[k]; !ok || hv != v {
+			v := v
+			patch[k] = &v
+		}
+	}
+	for k := range have {
+		if _, ok := want[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Kubernetes")
+	r.Key(gofig.Bool, "", false,
+		"Mirror the offered volumes into PersistentVolumes", enabledKey)
+	r.Key(gofig.String, "", "",
+		"The URL of the Kubernetes API server, the one of the cluster "+
+			"the daemon runs in when empty", apiServerKey)
+	r.Key(gofig.SecureString, "", "",
+		"The bearer token of the API server", tokenKey)
+	r.Key(gofig.String, "", serviceAccountDir+"token",
+		"The file holding the bearer token when no token is set", tokenFileKey)
+	r.Key(gofig.String, "", "",
+		"The CA of the API server certificate", caFileKey)
+	r.Key(gofig.Bool, "", false,
+		"Skip the verification of the API server certificate", insecureKey)
+	r.Key(gofig.String, "", "kubernetes",
+		"The scheduler named after the cluster", clusterKey)
+	r.Key(gofig.String, "", "",
+		"The storage class of the PersistentVolumes", storageClassKey)
+	r.Key(gofig.String, "", "polly.emccode.github.com",
+		"The CSI driver attaching the PersistentVolumes", csiDriverKey)
+	r.Key(gofig.String, "", "30s",
+		"The time between two syncs", intervalKey)
+	return r
+}
@@ -0,0 +1,158 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/volumes/volumestest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPIServer serves the PersistentVolume routes of the Kubernetes API
+// from memory
+type fakeAPIServer struct {
+	mu  sync.Mutex
+	pvs map[string]*persistentVolume
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, pvPath), "/")
+
+	switch {
+	case r.Method == "GET" && name == "":
+		sel := strings.SplitN(r.URL.Query().Get("labelSelector"), "=", 2)
+		l := &pvList{Items: []*persistentVolume{}}
+		for _, pv := range f.pvs {
+			if pv.Metadata.Labels[sel[0]] == sel[1] {
+				l.Items = append(l.Items, pv)
+			}
+		}
+		json.NewEncoder(w).Encode(l)
+	case r.Method == "POST":
+		pv := &persistentVolume{}
+		json.NewDecoder(r.Body).Decode(pv)
+		if _, ok := f.pvs[pv.Metadata.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		pv.Status = &pvStatus{Phase: "Available"}
+		f.pvs[pv.Metadata.Name] = pv
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PATCH":
+		patch := &struct {
+			Metadata struct {
+				Labels map[string]*string `json:"labels"`
+			} `json:"metadata"`
+		}{}
+		json.NewDecoder(r.Body).Decode(patch)
+		pv := f.pvs[name]
+		for k, v := range patch.Metadata.Labels {
+			if v == nil {
+				delete(pv.Metadata.Labels, k)
+			} else {
+				pv.Metadata.Labels[k] = *v
+			}
+		}
+	case r.Method == "DELETE":
+		delete(f.pvs, name)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSync(t *testing.T) {
+	api := &fakeAPIServer{pvs: map[string]*persistentVolume{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	vol := &types.Volume{
+		Volume:     &lstypes.Volume{ID: "vol-1", Size: 8},
+		VolumeID:   "ebs-vol-1",
+		Schedulers: []string{"ebs", "prod"},
+		Labels:     map[string]string{"tier": "gold", "bad label": "x"},
+	}
+	vols := volumestest.New(vol)
+	s := &Syncer{
+		c:         &client{server: srv.URL, token: "secret", http: srv.Client()},
+		vols:      vols,
+		cluster:   "prod",
+		csiDriver: "polly.emccode.github.com",
+	}
+
+	assert.NoError(t, s.Sync())
+	pv := api.pvs["polly-ebs-vol-1"]
+	if !assert.NotNil(t, pv) {
+		return
+	}
+	assert.Equal(t, "8Gi", pv.Spec.Capacity["storage"])
+	assert.Equal(t, "ebs-vol-1", pv.Spec.CSI.VolumeHandle)
+	assert.Equal(t, "ebs-vol-1", pv.Metadata.Annotations[volumeIDAnnotation])
+	assert.Equal(t, map[string]string{
+		managedByLabel: managedBy,
+		"tier":         "gold",
+	}, pv.Metadata.Labels)
+
+	// admin label changes are mirrored
+	vol.Labels = map[string]string{"zone": "a"}
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, map[string]string{
+		managedByLabel: managedBy,
+		"zone":         "a",
+	}, pv.Metadata.Labels)
+
+	// the PersistentVolumes of a suspended cluster are left as they are
+	vols.Stale = map[string]bool{"prod": true}
+	vol.Schedulers = []string{"ebs"}
+	assert.NoError(t, s.Sync())
+	assert.Len(t, api.pvs, 1)
+	vols.Stale = nil
+	vol.Schedulers = []string{"ebs", "prod"}
+
+	// a released PersistentVolume revokes the offer
+	pv.Status.Phase = phaseReleased
+	assert.NoError(t, s.Sync())
+	assert.Empty(t, api.pvs)
+	assert.Equal(t, []string{"ebs"}, vol.Schedulers)
+	assert.NoError(t, s.Sync())
+	assert.Empty(t, api.pvs)
+
+	// a revoked offer deletes the PersistentVolume
+	vol.Schedulers = []string{"ebs", "prod"}
+	assert.NoError(t, s.Sync())
+	assert.Len(t, api.pvs, 1)
+	vol.Schedulers = []string{"ebs"}
+	assert.NoError(t, s.Sync())
+	assert.Empty(t, api.pvs)
+
+	s.c.token = "wrong"
+	assert.Error(t, s.Sync())
+}
+
+func TestPVName(t *testing.T) {
+	assert.Equal(t, "polly-ebs-vol-1", pvName("ebs-vol-1"))
+	name := pvName("EBS-vol_1")
+	assert.True(t, strings.HasPrefix(name, "polly-ebs-vol-1-"), name)
+	assert.NotEqual(t, name, pvName("ebs-vol-1"))
+	assert.True(t, len(pvName(strings.Repeat("a", 300))) <= 253)
+}
+
+func TestValidLabel(t *testing.T) {
+	assert.True(t, validLabel("tier", "gold"))
+	assert.True(t, validLabel("example.com/tier", ""))
+	assert.False(t, validLabel("bad label", "x"))
+	assert.False(t, validLabel("tier", "not valid"))
+	assert.False(t, validLabel("Example.com/tier", "gold"))
+	assert.False(t, validLabel(strings.Repeat("a", 64), "gold"))
+}
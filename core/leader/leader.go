@@ -0,0 +1,224 @@
+package leader
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	kvstore "github.com/docker/libkv/store"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/store"
+)
+
+const (
+	ttlKey   = "polly.leader.ttl"
+	retryKey = "polly.leader.retry"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Job is a singleton task that only the elected daemon runs
+type Job struct {
+	// Name identifies the job in logs
+	Name string
+
+	// Interval is the time between two runs of the job
+	Interval time.Duration
+
+	// Run performs one pass of the job
+	Run func() error
+}
+
+// Elector campaigns for leadership among the Polly daemons sharing a store
+// and runs the registered jobs while it is the leader
+type Elector struct {
+	ps    *store.PollyStore
+	id    string
+	ttl   time.Duration
+	retry time.Duration
+	jobs  []*Job
+
+	leader int32
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// New returns an elector for the daemons sharing the store
+func New(cfg gofig.Config, ps *store.PollyStore) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{
+		ps:    ps,
+		id:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+		ttl:   config.Duration(cfg, ttlKey, 15*time.Second),
+		retry: config.Duration(cfg, retryKey, 5*time.Second),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Register adds a job run by the leader. Jobs must be registered before
+// Start is called.
+func (e *Elector) Register(job *Job) {
+	e.jobs = append(e.jobs, job)
+}
+
+// ID is the identity this daemon campaigns with
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader returns whether this daemon currently holds the leadership
+func (e *Elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// Leader returns the identity of the current leader as recorded in the store
+func (e *Elector) Leader() string {
+	if e.IsLeader() {
+		return e.id
+	}
+	v, err := e.ps.Get(e.ps.LeaderKey())
+	if err != nil {
+		return ""
+	}
+	return string(v)
+}
+
+// Start begins campaigning for leadership in the background
+func (e *Elector) Start() {
+	go e.campaign()
+}
+
+// Stop stops the jobs, releases the leadership and waits for the campaign to
+// end. Stop is safe to call more than once.
+func (e *Elector) Stop() {
+	e.once.Do(func() {
+		close(e.stop)
+		<-e.done
+	})
+}
+
+func (e *Elector) campaign() {
+	defer close(e.done)
+
+	fields := log.Fields{
+		"id":  e.id,
+		"key": e.ps.LeaderKey(),
+	}
+
+	for {
+		lock, err := e.ps.NewLock(e.ps.LeaderKey(), &kvstore.LockOptions{
+			Value: []byte(e.id),
+			TTL:   e.ttl,
+		})
+		if err == kvstore.ErrCallNotSupported {
+			log.WithFields(fields).Info(
+				"store does not support locking, running as the only leader")
+			e.lead(nil)
+			return
+		}
+		if err != nil {
+			log.WithFields(fields).WithError(err).Warn("cannot create leader lock")
+			if !e.wait() {
+				return
+			}
+			continue
+		}
+
+		lost, err := lock.Lock(e.stop)
+		if err != nil || lost == nil {
+			if e.stopped() {
+				return
+			}
+			log.WithFields(fields).WithError(err).Warn("cannot acquire leader lock")
+			if !e.wait() {
+				return
+			}
+			continue
+		}
+
+		log.WithFields(fields).Info("acquired leadership")
+		e.lead(lost)
+
+		if e.stopped() {
+			if err := lock.Unlock(); err != nil {
+				log.WithFields(fields).WithError(err).Warn("cannot release leader lock")
+			}
+			return
+		}
+		log.WithFields(fields).Warn("lost leadership")
+	}
+}
+
+// lead runs the jobs until the leadership is lost or the elector is stopped
+func (e *Elector) lead(lost <-chan struct{}) {
+	atomic.StoreInt32(&e.leader, 1)
+	defer atomic.StoreInt32(&e.leader, 0)
+
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, job := range e.jobs {
+		wg.Add(1)
+		go func(job *Job) {
+			defer wg.Done()
+			runJob(job, quit)
+		}(job)
+	}
+
+	select {
+	case <-lost:
+	case <-e.stop:
+	}
+	close(quit)
+	wg.Wait()
+}
+
+func runJob(job *Job, quit <-chan struct{}) {
+	t := time.NewTicker(job.Interval)
+	defer t.Stop()
+	for {
+		if err := job.Run(); err != nil {
+			log.WithField("job", job.Name).WithError(err).Error("leader job failed")
+		}
+		select {
+		case <-t.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// wait sleeps for the retry interval and returns false if stopped meanwhile
+func (e *Elector) wait() bool {
+	select {
+	case <-time.After(e.retry):
+		return true
+	case <-e.stop:
+		return false
+	}
+}
+
+func (e *Elector) stopped() bool {
+	select {
+	case <-e.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Leader")
+	r.Key(gofig.String, "", "15s",
+		"The TTL of the leader lock", ttlKey)
+	r.Key(gofig.String, "", "5s",
+		"The delay between two attempts to acquire the leader lock", retryKey)
+	return r
+}
@@ -1,14 +1,20 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gofig"
 	"github.com/akutz/goof"
 	"github.com/emccode/libstorage"
+	lscontext "github.com/emccode/libstorage/api/context"
 	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
 	pcontext "github.com/emccode/polly/api/context"
 	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"strings"
 )
 
@@ -17,20 +23,25 @@ type Client struct {
 	apitypes.Client
 	ctx            apitypes.Context
 	config         gofig.Config
+	server         apitypes.Server
+	errs           <-chan error
 	Services       apitypes.ServicesMap
 	ServiceDrivers map[string]string
 	DriverService  map[string]string
+
+	// parent is the context holding the span of the admin request
+	parent context.Context
 }
 
 // NewWithConfig creates a new client with specified configuration object
 func NewWithConfig(ctx apitypes.Context, config gofig.Config) (*Client, error) {
 	config = config.Scope("polly")
-	lsc, _, errs, err := libstorage.New(nil, config)
+	lsc, server, errs, err := libstorage.New(nil, config)
 	if err != nil {
 		for k, v := range config.AllSettings() {
 			ctx.Errorf("%s=%v", k, v)
 		}
-		ctx.Fatal(err)
+		return nil, goof.WithError("error starting libstorage client", err)
 	}
 
 	// _, err, errs := libstorage.Serve(config)
@@ -38,12 +49,6 @@ func NewWithConfig(ctx apitypes.Context, config gofig.Config) (*Client, error) {
 	// 	return nil, goof.WithError(
 	// 		"error starting libstorage server and client", err)
 	// }
-	go func() {
-		err := <-errs
-		if err != nil {
-			log.Error(err)
-		}
-	}()
 
 	// c, err := libstorage.Dial(config)
 	// if err != nil {
@@ -52,12 +57,16 @@ func NewWithConfig(ctx apitypes.Context, config gofig.Config) (*Client, error) {
 	// 		"error dialing libStorage service", err)
 	// }
 
+	c := &Client{Client: lsc, ctx: ctx, config: config, server: server, errs: errs}
+
 	services, err := lsc.API().Services(ctx)
 	if err != nil {
+		c.Close()
 		return nil, goof.WithError("cannot instantiate client services", err)
 	}
 	for _, service := range services {
 		if strings.Contains(service.Name, "-") {
+			c.Close()
 			return nil, goof.New("illegal character in serviceName '-'")
 		}
 	}
@@ -72,7 +81,51 @@ func NewWithConfig(ctx apitypes.Context, config gofig.Config) (*Client, error) {
 		driverService[s.Driver.Name] = s.Name
 	}
 
-	return &Client{lsc, ctx, config, services, serviceDrivers, driverService}, nil
+	c.Services = services
+	c.ServiceDrivers = serviceDrivers
+	c.DriverService = driverService
+	return c, nil
+}
+
+// WithContext returns a copy of the client bound to the context of an admin
+// request. Its libStorage calls carry the request ID, sent as a header and
+// logged as a field, and are traced as children of the span of the context.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		return c
+	}
+	rc := *c
+	rc.parent = ctx
+	if id := pcontext.RequestID(ctx); id != "" {
+		rc.ctx = c.ctx.WithValue(pcontext.RequestIDHeaderKey, id)
+	}
+	return &rc
+}
+
+// span starts the span of a libStorage call and returns a copy of the client
+// sending its trace context to the libStorage server
+func (c *Client) span(call string, attrs ...attribute.KeyValue) (*Client, trace.Span) {
+	ctx, span := tracing.StartSpan(c.parent, "libstorage."+call, attrs...)
+	rc := *c
+	rc.parent = ctx
+	if tp := tracing.TraceParent(ctx); tp != "" {
+		rc.ctx = c.ctx.WithValue(pcontext.TraceParentHeaderKey, tp)
+	}
+	return &rc, span
+}
+
+// Err returns a channel that receives errors from the embedded libStorage
+// server. The channel is nil when no server is embedded.
+func (c *Client) Err() <-chan error {
+	return c.errs
+}
+
+// Close shuts down the embedded libStorage server, if any
+func (c *Client) Close() error {
+	if c.server == nil {
+		return nil
+	}
+	return c.server.Close()
 }
 
 func getDriver(c *Client, s string) (string, error) {
@@ -109,8 +162,29 @@ func NewVolume(c *Client, vol *apitypes.Volume, service string) (*types.Volume,
 	return newVol, nil
 }
 
+// NewSnapshot creates a Polly snapshot from a libStorage snapshot
+func NewSnapshot(c *Client, snap *apitypes.Snapshot, service string) (*types.Snapshot, error) {
+	d, err := getDriver(c, service)
+	if err != nil {
+		return nil, err
+	}
+
+	newSnap := &types.Snapshot{
+		Snapshot:    snap,
+		ServiceName: service,
+		SnapshotID:  fmt.Sprintf("%s-%s", d, snap.ID),
+	}
+	if snap.VolumeID != "" {
+		newSnap.SourceVolumeID = fmt.Sprintf("%s-%s", d, snap.VolumeID)
+	}
+	return newSnap, nil
+}
+
 // VolumesByService returns a list of Polly volumes from libstorage
-func (c *Client) VolumesByService(serviceName string) ([]*types.Volume, error) {
+func (c *Client) VolumesByService(serviceName string) (vols []*types.Volume, err error) {
+	c, span := c.span("VolumesByService", attribute.String("service", serviceName))
+	defer func() { tracing.End(span, err) }()
+
 	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
 		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
 	}
@@ -120,7 +194,6 @@ func (c *Client) VolumesByService(serviceName string) ([]*types.Volume, error) {
 		return nil, err
 	}
 
-	var vols []*types.Volume
 	for _, vol := range volumeMap {
 		nv, err := NewVolume(c, vol, serviceName)
 		if err != nil {
@@ -132,7 +205,10 @@ func (c *Client) VolumesByService(serviceName string) ([]*types.Volume, error) {
 }
 
 // Volumes returns a list of Polly volumes from libstorage
-func (c *Client) Volumes() ([]*types.Volume, error) {
+func (c *Client) Volumes() (vols []*types.Volume, err error) {
+	c, span := c.span("Volumes")
+	defer func() { tracing.End(span, err) }()
+
 	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
 		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
 	}
@@ -142,7 +218,6 @@ func (c *Client) Volumes() ([]*types.Volume, error) {
 		return nil, err
 	}
 
-	var vols []*types.Volume
 	for serviceName, volumeMap := range serviceVolumeMap {
 		for _, vol := range volumeMap {
 			nv, err := NewVolume(c, vol, serviceName)
@@ -156,7 +231,11 @@ func (c *Client) Volumes() ([]*types.Volume, error) {
 }
 
 // VolumeInspect returns a Polly volume
-func (c *Client) VolumeInspect(serviceName, volumeID string, attachments bool) (*types.Volume, error) {
+func (c *Client) VolumeInspect(serviceName, volumeID string, attachments bool) (nv *types.Volume, err error) {
+	c, span := c.span("VolumeInspect", attribute.String("service", serviceName),
+		attribute.String("volume.id", volumeID))
+	defer func() { tracing.End(span, err) }()
+
 	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
 		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
 	}
@@ -166,7 +245,7 @@ func (c *Client) VolumeInspect(serviceName, volumeID string, attachments bool) (
 		return nil, err
 	}
 
-	nv, err := NewVolume(c, vol, serviceName)
+	nv, err = NewVolume(c, vol, serviceName)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +254,11 @@ func (c *Client) VolumeInspect(serviceName, volumeID string, attachments bool) (
 }
 
 // VolumeCreate creates a Polly Volume
-func (c *Client) VolumeCreate(serviceName string, request *apitypes.VolumeCreateRequest) (*types.Volume, error) {
+func (c *Client) VolumeCreate(serviceName string, request *apitypes.VolumeCreateRequest) (nv *types.Volume, err error) {
+	c, span := c.span("VolumeCreate", attribute.String("service", serviceName),
+		attribute.String("volume.name", request.Name))
+	defer func() { tracing.End(span, err) }()
+
 	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
 		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
 	}
@@ -184,7 +267,7 @@ func (c *Client) VolumeCreate(serviceName string, request *apitypes.VolumeCreate
 		return nil, err
 	}
 
-	nv, err := NewVolume(c, vol, serviceName)
+	nv, err = NewVolume(c, vol, serviceName)
 	if err != nil {
 		return nil, err
 	}
@@ -192,11 +275,15 @@ func (c *Client) VolumeCreate(serviceName string, request *apitypes.VolumeCreate
 }
 
 // VolumeRemove removes a Polly Volume
-func (c *Client) VolumeRemove(serviceName string, volumeID string) error {
+func (c *Client) VolumeRemove(serviceName string, volumeID string) (err error) {
+	c, span := c.span("VolumeRemove", attribute.String("service", serviceName),
+		attribute.String("volume.id", volumeID))
+	defer func() { tracing.End(span, err) }()
+
 	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
 		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
 	}
-	err := c.Client.API().VolumeRemove(c.ctx, serviceName, volumeID)
+	err = c.Client.API().VolumeRemove(c.ctx, serviceName, volumeID)
 	if err != nil {
 		return err
 	}
@@ -204,6 +291,86 @@ func (c *Client) VolumeRemove(serviceName string, volumeID string) error {
 	return nil
 }
 
+// VolumeSnapshot snapshots a volume
+func (c *Client) VolumeSnapshot(serviceName, volumeID, name string) (ns *types.Snapshot, err error) {
+	c, span := c.span("VolumeSnapshot", attribute.String("service", serviceName),
+		attribute.String("volume.id", volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
+		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
+	}
+	snap, err := c.Client.API().VolumeSnapshot(c.ctx, serviceName, volumeID,
+		&apitypes.VolumeSnapshotRequest{SnapshotName: name})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSnapshot(c, snap, serviceName)
+}
+
+// SnapshotInspect returns a snapshot
+func (c *Client) SnapshotInspect(serviceName, snapshotID string) (ns *types.Snapshot, err error) {
+	c, span := c.span("SnapshotInspect", attribute.String("service", serviceName),
+		attribute.String("snapshot.id", snapshotID))
+	defer func() { tracing.End(span, err) }()
+
+	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
+		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
+	}
+	snap, err := c.Client.API().SnapshotInspect(c.ctx, serviceName, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSnapshot(c, snap, serviceName)
+}
+
+// SnapshotRemove removes a snapshot
+func (c *Client) SnapshotRemove(serviceName, snapshotID string) (err error) {
+	c, span := c.span("SnapshotRemove", attribute.String("service", serviceName),
+		attribute.String("snapshot.id", snapshotID))
+	defer func() { tracing.End(span, err) }()
+
+	if c.ctx.Value(pcontext.RequestPathHeaderKey) == nil {
+		c.ctx = c.ctx.WithValue(pcontext.RequestPathHeaderKey, "admin")
+	}
+	return c.Client.API().SnapshotRemove(c.ctx, serviceName, snapshotID)
+}
+
+// VolumeMount attaches a volume to the local host through the libStorage
+// integration driver and mounts it, returning the mount point
+func (c *Client) VolumeMount(serviceName, volumeID, volumeName string) (mp string, err error) {
+	c, span := c.span("VolumeMount", attribute.String("service", serviceName),
+		attribute.String("volume.id", volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	mp, _, err = c.Client.Integration().Mount(c.serviceCtx(serviceName),
+		volumeID, volumeName, &apitypes.VolumeMountOpts{Opts: utils.NewStore()})
+	return mp, err
+}
+
+// VolumeUnmount unmounts a volume and detaches it from the local host
+func (c *Client) VolumeUnmount(serviceName, volumeID, volumeName string) (err error) {
+	c, span := c.span("VolumeUnmount", attribute.String("service", serviceName),
+		attribute.String("volume.id", volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	return c.Client.Integration().Unmount(c.serviceCtx(serviceName),
+		volumeID, volumeName, utils.NewStore())
+}
+
+// VolumePath returns the mount point of a volume on the local host
+func (c *Client) VolumePath(serviceName, volumeID, volumeName string) (string, error) {
+	return c.Client.Integration().Path(c.serviceCtx(serviceName),
+		volumeID, volumeName, utils.NewStore())
+}
+
+// serviceCtx returns the context of an integration driver call on a service
+func (c *Client) serviceCtx(serviceName string) apitypes.Context {
+	return c.ctx.WithValue(lscontext.ServiceKey, serviceName)
+}
+
 func (c Client) requestPath() string {
 	return c.config.GetString("libstorage.client.requestPath")
 }
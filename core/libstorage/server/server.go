@@ -4,23 +4,16 @@ import (
 	"github.com/akutz/gofig"
 
 	"github.com/emccode/libstorage/api/server"
+	apitypes "github.com/emccode/libstorage/api/types"
 )
 
 // New starts a server with default configuration
-func New(config gofig.Config) (gofig.Config, error) {
-	return config, NewWithConfig(config.Scope("polly"))
+func New(config gofig.Config) (apitypes.Server, <-chan error, error) {
+	return NewWithConfig(config.Scope("polly"))
 }
 
-// NewWithConfig starts a server with a configuration
-func NewWithConfig(config gofig.Config) error {
-	_, errs, err := server.Serve(nil, config)
-	if err != nil {
-		return err
-	}
-	go func() {
-		err := <-errs
-		panic(err)
-	}()
-
-	return nil
+// NewWithConfig starts a server with a configuration. Listener errors are
+// sent on the returned channel and the server is stopped with Close.
+func NewWithConfig(config gofig.Config) (apitypes.Server, <-chan error, error) {
+	return server.Serve(nil, config)
 }
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "polly"
+
+var (
+	// AdminRequests counts admin API requests by route, method and status
+	AdminRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "admin",
+			Name:      "requests_total",
+			Help:      "Admin API requests by route, method and status code.",
+		},
+		[]string{"route", "method", "code"})
+
+	// AdminRequestDuration observes admin API request latencies by route
+	AdminRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "admin",
+			Name:      "request_duration_seconds",
+			Help:      "Admin API request latencies by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method"})
+
+	// LibStorageFilterVolumes counts volumes seen by the libStorage filter
+	LibStorageFilterVolumes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "libstorage",
+			Name:      "filter_volumes_total",
+			Help:      "Volumes seen by the libStorage filter by route and result.",
+		},
+		[]string{"route", "result"})
+
+	// LibStorageFilterDuration observes the time spent filtering a volume
+	LibStorageFilterDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "libstorage",
+			Name:      "filter_duration_seconds",
+			Help:      "Time spent in the libStorage filter per volume by route.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route"})
+
+	// StoreOperations counts store operations by backend and operation
+	StoreOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operations_total",
+			Help:      "Store operations by backend and operation.",
+		},
+		[]string{"backend", "op"})
+
+	// StoreOperationErrors counts failed store operations
+	StoreOperationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operation_errors_total",
+			Help:      "Failed store operations by backend and operation.",
+		},
+		[]string{"backend", "op"})
+
+	// StoreOperationDuration observes store operation latencies
+	StoreOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Store operation latencies by backend and operation.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"backend", "op"})
+
+	// Volumes is the number of volumes per service split by management state
+	Volumes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "volumes",
+			Help:      "Volumes per service by state (managed, unmanaged) as of the last full listing.",
+		},
+		[]string{"service", "state"})
+
+	// OfferedVolumes is the number of volumes offered to each scheduler
+	OfferedVolumes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "offered_volumes",
+			Help:      "Managed volumes offered per scheduler as of the last full listing.",
+		},
+		[]string{"scheduler"})
+
+	// ReconcilerResults counts reconciler passes by result
+	ReconcilerResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "reconciler",
+			Name:      "results_total",
+			Help:      "Reconciler passes by result.",
+		},
+		[]string{"result"})
+
+	// SchedulerUnseen is the time each registered scheduler was not seen
+	SchedulerUnseen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "unseen_seconds",
+			Help:      "Time since the last heartbeat of each registered scheduler by state as of the last liveness check.",
+		},
+		[]string{"scheduler", "state"})
+
+	// StaleSchedulerActions counts the offers revoked from and the leases
+	// released for stale schedulers
+	StaleSchedulerActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "stale_actions_total",
+			Help:      "Offers revoked and leases released for stale schedulers by action.",
+		},
+		[]string{"action"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		AdminRequests,
+		AdminRequestDuration,
+		LibStorageFilterVolumes,
+		LibStorageFilterDuration,
+		StoreOperations,
+		StoreOperationErrors,
+		StoreOperationDuration,
+		Volumes,
+		OfferedVolumes,
+		ReconcilerResults,
+		SchedulerUnseen,
+		StaleSchedulerActions,
+	)
+}
+
+// Handler returns the HTTP handler that serves the registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveStore records the outcome of a store operation
+func ObserveStore(backend, op string, start time.Time, err error) {
+	StoreOperations.WithLabelValues(backend, op).Inc()
+	StoreOperationDuration.WithLabelValues(backend, op).Observe(
+		time.Since(start).Seconds())
+	if err != nil {
+		StoreOperationErrors.WithLabelValues(backend, op).Inc()
+	}
+}
+
+// ObserveAdminRequest records the outcome of an admin API request
+func ObserveAdminRequest(route, method string, code int, start time.Time) {
+	AdminRequests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	AdminRequestDuration.WithLabelValues(route, method).Observe(
+		time.Since(start).Seconds())
+}
+
+// ObserveFilter records the outcome of the libStorage filter for a volume
+func ObserveFilter(route, result string, start time.Time) {
+	LibStorageFilterVolumes.WithLabelValues(route, result).Inc()
+	LibStorageFilterDuration.WithLabelValues(route).Observe(
+		time.Since(start).Seconds())
+}
+
+// SetInventory replaces the volume gauges with the counts of a full listing.
+// managed and unmanaged are keyed by service name, offered by scheduler.
+func SetInventory(managed, unmanaged, offered map[string]int) {
+	services := make(map[string]bool, len(managed)+len(unmanaged))
+	for s := range managed {
+		services[s] = true
+	}
+	for s := range unmanaged {
+		services[s] = true
+	}
+
+	Volumes.Reset()
+	for s := range services {
+		Volumes.WithLabelValues(s, "managed").Set(float64(managed[s]))
+		Volumes.WithLabelValues(s, "unmanaged").Set(float64(unmanaged[s]))
+	}
+
+	OfferedVolumes.Reset()
+	for s, n := range offered {
+		OfferedVolumes.WithLabelValues(s).Set(float64(n))
+	}
+}
@@ -0,0 +1,449 @@
+// Package query parses and evaluates the filter expressions used to select
+// volumes.
+//
+// A filter is a comma separated list of requirements that must all match:
+//
+//	size>=100                   numeric comparison with > >= < <=
+//	availabilityZone=us-east-1a equality with = or ==, negated with !=
+//	name=~^db-                  regular expression, negated with !~
+//	serviceName in (aws,gce)    set membership, negated with notin
+//	labels.tier                 existence of a key, negated with !labels.tier
+//
+// Keys are name, id, volumeID, serviceName, availabilityZone, type, status,
+// size, iops, schedulers, tenant, labels.<key> for admin labels and
+// fields.<key> for libStorage fields. Sizes are in GiB and accept the MiB,
+// GiB, TiB and PiB units with every operator, for example size>1TiB or
+// size=2TiB.
+//
+// Requirements are not split on the commas within parentheses, braces,
+// brackets or quoted values, so name=~^a{1,3}$ and name in ('a,b',c) are
+// single requirements. A quote opens a quoted value at the start of a value
+// only.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emccode/polly/api/types"
+)
+
+// Operator is the comparison of a requirement
+type Operator string
+
+const (
+	// Equals matches when a value of the key equals the value
+	Equals Operator = "="
+	// NotEquals matches when no value of the key equals the value
+	NotEquals Operator = "!="
+	// Matches matches when a value of the key matches the expression
+	Matches Operator = "=~"
+	// NotMatches matches when no value of the key matches the expression
+	NotMatches Operator = "!~"
+	// GreaterThan matches when a value of the key is greater than the value
+	GreaterThan Operator = ">"
+	// GreaterThanOrEquals matches when a value of the key is at least the value
+	GreaterThanOrEquals Operator = ">="
+	// LessThan matches when a value of the key is less than the value
+	LessThan Operator = "<"
+	// LessThanOrEquals matches when a value of the key is at most the value
+	LessThanOrEquals Operator = "<="
+	// In matches when a value of the key is one of the values
+	In Operator = "in"
+	// NotIn matches when no value of the key is one of the values
+	NotIn Operator = "notin"
+	// Exists matches when the key is set
+	Exists Operator = "exists"
+	// DoesNotExist matches when the key is not set
+	DoesNotExist Operator = "!"
+)
+
+// Error is returned for a filter or listing parameter that cannot be parsed
+type Error struct {
+	Expr   string
+	Reason string
+
+	// Param is the invalid query parameter, filter when empty
+	Param string
+}
+
+func (e *Error) Error() string {
+	param := e.Param
+	if param == "" {
+		param = "filter"
+	}
+	return fmt.Sprintf("invalid %s %q: %s", param, e.Expr, e.Reason)
+}
+
+// IsError returns whether err is a filter or listing parameter error
+func IsError(err error) bool {
+	_, ok := err.(*Error)
+	return ok
+}
+
+// Requirement is a single condition on a volume key
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+
+	re   *regexp.Regexp
+	num  float64
+	nums []float64
+}
+
+// Selector is a set of requirements that must all match
+type Selector []*Requirement
+
+var (
+	keyRx = regexp.MustCompile(`^[A-Za-z0-9_./\-]+$`)
+	setRx = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+	opRx  = regexp.MustCompile(`^([^=!<>~\s]+)\s*(==|=~|!~|!=|>=|<=|=|>|<)\s*(.*)$`)
+)
+
+// Parse parses a filter expression. An empty expression selects everything.
+func Parse(expr string) (Selector, error) {
+	var sel Selector
+	for _, part := range split(expr) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// split splits an expression on the commas that are not within parentheses,
+// braces, brackets or quoted values. A backslash escapes the next character.
+func split(expr string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	escaped := false
+	prev := ','
+	for i, c := range expr {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && strings.ContainsRune("=~!<>(,", prev):
+			quote = c
+		case c == '(' || c == '{' || c == '[':
+			depth++
+		case c == ')' || c == '}' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+		if c != ' ' && c != '\t' {
+			prev = c
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+func parseRequirement(expr string) (*Requirement, error) {
+	if m := setRx.FindStringSubmatch(expr); m != nil {
+		var values []string
+		for _, v := range split(m[3]) {
+			if v = unquote(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return nil, &Error{Expr: expr, Reason: "empty set"}
+		}
+		return NewRequirement(m[1], Operator(m[2]), values...)
+	}
+
+	if m := opRx.FindStringSubmatch(expr); m != nil {
+		op := Operator(m[2])
+		if op == "==" {
+			op = Equals
+		}
+		return NewRequirement(m[1], op, unquote(m[3]))
+	}
+
+	if strings.HasPrefix(expr, "!") {
+		return NewRequirement(strings.TrimSpace(expr[1:]), DoesNotExist)
+	}
+	return NewRequirement(expr, Exists)
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// NewRequirement validates and returns a requirement
+func NewRequirement(key string, op Operator, values ...string) (*Requirement, error) {
+	expr := strings.TrimSpace(fmt.Sprintf("%s %s %s", key, op, strings.Join(values, ",")))
+	if !keyRx.MatchString(key) {
+		return nil, &Error{Expr: expr, Reason: "invalid key"}
+	}
+
+	r := &Requirement{Key: key, Operator: op, Values: values}
+	switch op {
+	case Exists, DoesNotExist:
+		if len(values) != 0 {
+			return nil, &Error{Expr: expr, Reason: "unexpected value"}
+		}
+	case In, NotIn:
+		if len(values) == 0 {
+			return nil, &Error{Expr: expr, Reason: "empty set"}
+		}
+		if err := r.parseNumbers(); err != nil {
+			return nil, &Error{Expr: expr, Reason: err.Error()}
+		}
+	case Equals, NotEquals:
+		if len(values) != 1 {
+			return nil, &Error{Expr: expr, Reason: "expected a single value"}
+		}
+		if err := r.parseNumbers(); err != nil {
+			return nil, &Error{Expr: expr, Reason: err.Error()}
+		}
+	case Matches, NotMatches:
+		if len(values) != 1 {
+			return nil, &Error{Expr: expr, Reason: "expected a single value"}
+		}
+		re, err := regexp.Compile(values[0])
+		if err != nil {
+			return nil, &Error{Expr: expr, Reason: err.Error()}
+		}
+		r.re = re
+	case GreaterThan, GreaterThanOrEquals, LessThan, LessThanOrEquals:
+		if len(values) != 1 {
+			return nil, &Error{Expr: expr, Reason: "expected a single value"}
+		}
+		n, err := parseNumber(key, values[0])
+		if err != nil {
+			return nil, &Error{Expr: expr, Reason: "expected a number"}
+		}
+		r.num = n
+	default:
+		return nil, &Error{Expr: expr, Reason: "unknown operator"}
+	}
+	return r, nil
+}
+
+// parseNumbers parses the values compared for equality with the numeric
+// keys, so their units apply as with the other comparisons
+func (r *Requirement) parseNumbers() error {
+	if !numeric(r.Key) {
+		return nil
+	}
+	r.nums = make([]float64, len(r.Values))
+	for i, v := range r.Values {
+		n, err := parseNumber(r.Key, v)
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		r.nums[i] = n
+	}
+	return nil
+}
+
+// numeric returns whether the values of a key are numbers
+func numeric(key string) bool {
+	return strings.EqualFold(key, "size") || strings.EqualFold(key, "iops")
+}
+
+// sizeUnits are the size suffixes in GiB, the unit of libStorage sizes
+var sizeUnits = []struct {
+	suffix string
+	gib    float64
+}{
+	{"PiB", 1 << 20}, {"TiB", 1 << 10}, {"GiB", 1}, {"MiB", 1.0 / (1 << 10)},
+	{"PB", 1 << 20}, {"TB", 1 << 10}, {"GB", 1}, {"MB", 1.0 / (1 << 10)},
+	{"P", 1 << 20}, {"T", 1 << 10}, {"G", 1}, {"M", 1.0 / (1 << 10)},
+}
+
+func parseNumber(key, v string) (float64, error) {
+	if strings.EqualFold(key, "size") {
+		for _, u := range sizeUnits {
+			if strings.HasSuffix(v, u.suffix) {
+				n, err := strconv.ParseFloat(
+					strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), 64)
+				return n * u.gib, err
+			}
+		}
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// Matches returns whether the volume satisfies every requirement
+func (s Selector) Matches(v *types.Volume) bool {
+	for _, r := range s {
+		if !r.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter expression of the selector
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// String returns the filter expression of the requirement
+func (r *Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+	return fmt.Sprintf("%s%s%s", r.Key, r.Operator, r.Values[0])
+}
+
+// Matches returns whether the volume satisfies the requirement
+func (r *Requirement) Matches(v *types.Volume) bool {
+	values, ok := lookup(v, r.Key)
+
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && r.anyOf(values)
+	case NotEquals, NotIn:
+		return !ok || !r.anyOf(values)
+	case Matches:
+		return ok && anyMatch(values, r.re)
+	case NotMatches:
+		return !ok || !anyMatch(values, r.re)
+	}
+
+	if !ok {
+		return false
+	}
+	for _, value := range values {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch r.Operator {
+		case GreaterThan:
+			if n > r.num {
+				return true
+			}
+		case GreaterThanOrEquals:
+			if n >= r.num {
+				return true
+			}
+		case LessThan:
+			if n < r.num {
+				return true
+			}
+		case LessThanOrEquals:
+			if n <= r.num {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyOf returns whether one of the values equals one of the values of the
+// requirement, as numbers for the numeric keys
+func (r *Requirement) anyOf(values []string) bool {
+	for _, v := range values {
+		n, err := strconv.ParseFloat(v, 64)
+		for i, s := range r.Values {
+			if r.nums != nil {
+				if err == nil && n == r.nums[i] {
+					return true
+				}
+			} else if v == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func anyMatch(values []string, re *regexp.Regexp) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the values of a key on a volume and whether the key is set
+func lookup(v *types.Volume, key string) ([]string, bool) {
+	if strings.HasPrefix(key, "labels.") {
+		val, ok := v.Labels[strings.TrimPrefix(key, "labels.")]
+		return []string{val}, ok
+	}
+
+	if strings.HasPrefix(key, "fields.") {
+		if v.Volume == nil {
+			return nil, false
+		}
+		val, ok := v.Fields[strings.TrimPrefix(key, "fields.")]
+		return []string{val}, ok
+	}
+
+	switch strings.ToLower(key) {
+	case "volumeid":
+		return single(v.VolumeID)
+	case "servicename", "service":
+		return single(v.ServiceName)
+	case "schedulers", "scheduler":
+		return v.Schedulers, len(v.Schedulers) > 0
+	case "tenant":
+		return single(v.Tenant)
+	}
+
+	if v.Volume == nil {
+		return nil, false
+	}
+
+	switch strings.ToLower(key) {
+	case "name":
+		return single(v.Name)
+	case "id":
+		return single(v.ID)
+	case "availabilityzone":
+		return single(v.AvailabilityZone)
+	case "type", "volumetype":
+		return single(v.Type)
+	case "status":
+		return single(v.Status)
+	case "size":
+		return []string{strconv.FormatInt(v.Size, 10)}, true
+	case "iops":
+		return []string{strconv.FormatInt(v.IOPS, 10)}, true
+	}
+	return nil, false
+}
+
+func single(v string) ([]string, bool) {
+	return []string{v}, v != ""
+}
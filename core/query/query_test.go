@@ -0,0 +1,104 @@
+package query
+
+import (
+	"testing"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newVolume() *types.Volume {
+	return &types.Volume{
+		Volume: &lstypes.Volume{
+			Name:             "db-01",
+			ID:               "vol-01",
+			AvailabilityZone: "us-east-1a",
+			Size:             2048,
+			IOPS:             300,
+			Type:             "gold",
+			Fields:           map[string]string{"encrypted": "true"},
+		},
+		VolumeID:    "ebs-vol-01",
+		ServiceName: "ebs",
+		Schedulers:  []string{"ebs", "mesos-1"},
+		Labels:      map[string]string{"tier": "gold"},
+	}
+}
+
+func TestParseMatches(t *testing.T) {
+	v := newVolume()
+	tests := map[string]bool{
+		"":                                true,
+		"name=db-01":                      true,
+		"name==db-01":                     true,
+		"name!=db-01":                     false,
+		"name=~^db-":                      true,
+		"name!~^db-":                      false,
+		"size>=2048":                      true,
+		"size>1TiB":                       true,
+		"size>2TiB":                       false,
+		"size<=2T,iops<1000":              true,
+		"serviceName in (ebs,gce)":        true,
+		"serviceName notin (ebs, gce)":    false,
+		"labels.tier":                     true,
+		"!labels.tier":                    false,
+		"labels.tier in (gold,silver)":    true,
+		"labels.owner":                    false,
+		"fields.encrypted=true":           true,
+		"scheduler=mesos-1":               true,
+		"scheduler!=kubernetes-1":         true,
+		"!schedulers":                     false,
+		"!tenant":                         true,
+		"availabilityZone=~^us-east,!foo": true,
+		"availabilityZone='us-east-1a'":   true,
+		"volumeID=ebs-vol-01,type=silver": false,
+		"name=~^db-[0-9]{1,3}$":           true,
+		"name=~^db-[0-9]{3,4}$":           false,
+		"name=~^(db|web)-0,size>1":        true,
+		"name in ('db,01',db-01)":         true,
+		"name='db,01'":                    false,
+		"size=2TiB":                       true,
+		"size==2048":                      true,
+		"size!=2TiB":                      false,
+		"size in (1TiB,2TiB)":             true,
+		"size notin (2048GiB)":            false,
+		"iops=300":                        true,
+	}
+	for expr, want := range tests {
+		sel, err := Parse(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		assert.Equal(t, want, sel.Matches(v), expr)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"size>big",
+		"name=~(",
+		"serviceName in ()",
+		"name in",
+		"a b",
+		"size=big",
+		"iops in (1,many)",
+	} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+		assert.True(t, IsError(err), expr)
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	sel, err := Parse("size>=100, serviceName in (a,b),!labels.x")
+	assert.NoError(t, err)
+	assert.Equal(t, "size>=100,serviceName in (a,b),!labels.x", sel.String())
+}
+
+func TestSplit(t *testing.T) {
+	assert.Equal(t, []string{"name=~^a{1,3}$", "size>1"}, split("name=~^a{1,3}$,size>1"))
+	assert.Equal(t, []string{"name=~^a\\,b", "c"}, split("name=~^a\\,b,c"))
+	assert.Equal(t, []string{"labels.owner=\"x,y\"", "!tier"}, split("labels.owner=\"x,y\",!tier"))
+	assert.Equal(t, []string{"labels.owner=it's", "tier"}, split("labels.owner=it's,tier"))
+}
@@ -0,0 +1,331 @@
+package schedulers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
+	kvstore "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/tenants"
+)
+
+// Registry registers the schedulers and the tenants owning them. The owner
+// of a scheduler is only stored with its tenant, and the changes are
+// serialized so a scheduler is not registered to two tenants, across the
+// daemons when the store supports locking. Its copies share the lock.
+type Registry struct {
+	ps *store.PollyStore
+	mu *sync.Mutex
+}
+
+// NewRegistry returns the registry of the schedulers of a store
+func NewRegistry(ps *store.PollyStore) *Registry {
+	return &Registry{ps: ps, mu: &sync.Mutex{}}
+}
+
+// WithContext returns a copy of the registry whose store calls are bound to
+// a context
+func (reg *Registry) WithContext(ctx context.Context) *Registry {
+	rr := *reg
+	rr.ps = reg.ps.WithContext(ctx)
+	return &rr
+}
+
+// Register registers a scheduler, to its tenant too when set. A registered
+// scheduler is a 409 and an unknown tenant a 422.
+func (reg *Registry) Register(s *types.Scheduler) error {
+	if err := Validate(s); err != nil {
+		return err
+	}
+	unlock, err := reg.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := reg.ps.GetScheduler(s.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return types.NewErrorf(http.StatusConflict,
+			"scheduler %q is registered", s.Name).WithDetail("scheduler", s.Name)
+	}
+	s.Created = time.Now().UTC()
+	s.LastSeen = nil
+	return reg.save(s, s.Tenant)
+}
+
+// Update replaces the type, contact and tenant of a scheduler
+func (reg *Registry) Update(name string, req *types.Scheduler) (*types.Scheduler, error) {
+	unlock, err := reg.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := reg.scheduler(name)
+	if err != nil {
+		return nil, err
+	}
+	s.Type = req.Type
+	s.Contact = req.Contact
+	if err := Validate(s); err != nil {
+		return nil, err
+	}
+	if err := reg.save(s, req.Tenant); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Remove unregisters a scheduler, from its tenant too. The volumes offered
+// to it stay offered.
+func (reg *Registry) Remove(name string) error {
+	unlock, err := reg.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := reg.scheduler(name); err != nil {
+		return err
+	}
+	undo, err := reg.assign(name, "")
+	if err != nil {
+		return err
+	}
+	if err := reg.ps.RemoveScheduler(name); err != nil {
+		undo()
+		return goof.WithFieldE("scheduler", name, "problem removing scheduler", err)
+	}
+	return nil
+}
+
+// CreateTenant creates a tenant, a tenant of the same name or another
+// tenant owning one of its schedulers being a 409
+func (reg *Registry) CreateTenant(t *types.Tenant) error {
+	if err := tenants.Validate(t, nil); err != nil {
+		return err
+	}
+	unlock, err := reg.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := reg.ps.GetTenant(t.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return types.NewErrorf(http.StatusConflict,
+			"tenant %q exists", t.Name).WithDetail("tenant", t.Name)
+	}
+	t.Created = time.Now().UTC()
+	return reg.saveTenant(t)
+}
+
+// UpdateTenant replaces the description and the schedulers of a tenant
+func (reg *Registry) UpdateTenant(name string, req *types.Tenant) (*types.Tenant, error) {
+	unlock, err := reg.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	t, err := reg.tenant(name)
+	if err != nil {
+		return nil, err
+	}
+	t.Description = req.Description
+	t.Schedulers = req.Schedulers
+	if err := reg.saveTenant(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RemoveTenant removes a tenant along with its tokens, it is a 409 while
+// volumes belong to it
+func (reg *Registry) RemoveTenant(name string) error {
+	unlock, err := reg.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := reg.tenant(name); err != nil {
+		return err
+	}
+	owned, err := reg.ps.VolumeTenants()
+	if err != nil {
+		return goof.WithError("problem getting volume tenants", err)
+	}
+	for id, tenant := range owned {
+		if tenant == name {
+			return types.NewErrorf(http.StatusConflict,
+				"tenant %q owns volumes", name).
+				WithDetail("tenant", name).
+				WithDetail("volumeID", id)
+		}
+	}
+	return reg.ps.RemoveTenant(name)
+}
+
+// save moves a scheduler to a tenant and saves it, the tenants being
+// restored when the scheduler cannot be saved
+func (reg *Registry) save(s *types.Scheduler, tenant string) error {
+	undo, err := reg.assign(s.Name, tenant)
+	if err != nil {
+		return err
+	}
+	if err := reg.ps.SaveScheduler(s); err != nil {
+		undo()
+		return goof.WithFieldE("scheduler", s.Name, "problem saving scheduler", err)
+	}
+	s.Tenant = tenant
+	return nil
+}
+
+// assign moves a scheduler to the schedulers of a tenant, or out of those of
+// any tenant when empty. It is removed from the other tenants first, so a
+// failure leaves it to no tenant rather than two. The tenants already saved
+// are restored when a save fails, and by the returned function.
+func (reg *Registry) assign(name, tenant string) (func(), error) {
+	all, err := reg.ps.Tenants()
+	if err != nil {
+		return nil, goof.WithError("problem getting tenants", err)
+	}
+
+	var to *types.Tenant
+	for _, t := range all {
+		if t.Name == tenant {
+			to = t
+		}
+	}
+	if tenant != "" && to == nil {
+		return nil, types.NewErrorf(422,
+			"unknown tenant %q", tenant).WithDetail("tenant", tenant)
+	}
+
+	var saved []types.Tenant
+	undo := func() {
+		for i := len(saved) - 1; i >= 0; i-- {
+			if err := reg.ps.SaveTenant(&saved[i]); err != nil {
+				log.WithFields(log.Fields{
+					"scheduler": name,
+					"tenant":    saved[i].Name,
+				}).WithError(err).Error("cannot restore tenant")
+			}
+		}
+	}
+	set := func(t *types.Tenant, schedulers []string) error {
+		prev := *t
+		t.Schedulers = schedulers
+		if err := reg.ps.SaveTenant(t); err != nil {
+			undo()
+			return goof.WithFieldE("tenant", t.Name, "problem saving tenant", err)
+		}
+		saved = append(saved, prev)
+		return nil
+	}
+
+	for _, t := range all {
+		if t.Name == tenant || !contains(t.Schedulers, name) {
+			continue
+		}
+		if err := set(t, remove(t.Schedulers, name)); err != nil {
+			return nil, err
+		}
+	}
+	if to != nil && !contains(to.Schedulers, name) {
+		schedulers := append(append([]string{}, to.Schedulers...), name)
+		if err := set(to, schedulers); err != nil {
+			return nil, err
+		}
+	}
+	return undo, nil
+}
+
+// saveTenant validates a tenant against the other tenants and saves it
+func (reg *Registry) saveTenant(t *types.Tenant) error {
+	others, err := reg.ps.Tenants()
+	if err != nil {
+		return goof.WithError("problem getting tenants", err)
+	}
+	if err := tenants.Validate(t, others); err != nil {
+		return err
+	}
+	if err := reg.ps.SaveTenant(t); err != nil {
+		return goof.WithFieldE("tenant", t.Name, "problem saving tenant", err)
+	}
+	return nil
+}
+
+// scheduler returns a registered scheduler, a 404 when it is not
+func (reg *Registry) scheduler(name string) (*types.Scheduler, error) {
+	s, err := reg.ps.GetScheduler(name)
+	if err == nil && s == nil {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no scheduler %q", name).WithDetail("scheduler", name)
+	}
+	return s, err
+}
+
+// tenant returns a tenant, a 404 when it does not exist
+func (reg *Registry) tenant(name string) (*types.Tenant, error) {
+	t, err := reg.ps.GetTenant(name)
+	if err == nil && t == nil {
+		err = types.NewErrorf(http.StatusNotFound,
+			"no tenant %q", name).WithDetail("tenant", name)
+	}
+	return t, err
+}
+
+// lock serializes the registry changes and returns the function releasing
+// the lock
+func (reg *Registry) lock() (func(), error) {
+	reg.mu.Lock()
+	l, err := reg.ps.NewLock(reg.ps.RegistryKey(), nil)
+	if err == kvstore.ErrCallNotSupported {
+		return reg.mu.Unlock, nil
+	}
+	if err == nil {
+		_, err = l.Lock(nil)
+	}
+	if err != nil {
+		reg.mu.Unlock()
+		return nil, goof.WithError("cannot lock the scheduler registry", err)
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			log.WithError(err).Warn("cannot unlock the scheduler registry")
+		}
+		reg.mu.Unlock()
+	}, nil
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(s []string, e string) []string {
+	var r []string
+	for _, a := range s {
+		if a != e {
+			r = append(r, a)
+		}
+	}
+	return r
+}
@@ -0,0 +1,89 @@
+package schedulers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	cfg, err := config.NewWithConfig(`
+polly:
+  store:
+    type: boltdb
+    endpoints: ` + filepath.Join(t.TempDir(), "polly.db") + `
+    bucket: polly_test
+`)
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	ps, err := store.NewWithConfig(cfg.Scope("polly.store"))
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	t.Cleanup(ps.Close)
+	return NewRegistry(ps)
+}
+
+func owners(t *testing.T, reg *Registry) map[string][]string {
+	all, err := reg.ps.Tenants()
+	assert.NoError(t, err)
+	m := make(map[string][]string)
+	for _, tenant := range all {
+		m[tenant.Name] = tenant.Schedulers
+	}
+	return m
+}
+
+func TestRegistry(t *testing.T) {
+	reg := newTestRegistry(t)
+	assert.NoError(t, reg.CreateTenant(&types.Tenant{Name: "blue"}))
+	assert.NoError(t, reg.CreateTenant(&types.Tenant{Name: "red"}))
+	err := reg.CreateTenant(&types.Tenant{Name: "red"})
+	assert.True(t, types.IsConflict(err))
+
+	s := &types.Scheduler{
+		Name: "marathon-1", Type: types.SchedulerTypeMesos, Tenant: "blue"}
+	assert.NoError(t, reg.Register(s))
+	assert.Equal(t, "blue", s.Tenant)
+	assert.Equal(t, map[string][]string{
+		"blue": {"marathon-1"}, "red": nil}, owners(t, reg))
+
+	err = reg.Register(&types.Scheduler{
+		Name: "marathon-1", Type: types.SchedulerTypeMesos})
+	assert.True(t, types.IsConflict(err))
+	err = reg.Register(&types.Scheduler{
+		Name: "marathon-2", Type: types.SchedulerTypeMesos, Tenant: "green"})
+	assert.Equal(t, 422, types.ErrorStatus(err))
+	none, err := reg.ps.GetScheduler("marathon-2")
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	// moving a scheduler removes it from its previous tenant
+	s, err = reg.Update("marathon-1", &types.Scheduler{
+		Type: types.SchedulerTypeMesos, Contact: "ops", Tenant: "red"})
+	assert.NoError(t, err)
+	assert.Equal(t, "red", s.Tenant)
+	assert.Equal(t, "ops", s.Contact)
+	assert.Equal(t, map[string][]string{
+		"blue": nil, "red": {"marathon-1"}}, owners(t, reg))
+
+	_, err = reg.UpdateTenant("blue", &types.Tenant{Schedulers: []string{"marathon-1"}})
+	assert.True(t, types.IsConflict(err))
+	_, err = reg.Update("k8s-1", &types.Scheduler{Type: types.SchedulerTypeMesos})
+	assert.True(t, types.IsNotFound(err))
+
+	assert.NoError(t, reg.Remove("marathon-1"))
+	assert.Equal(t, map[string][]string{
+		"blue": nil, "red": nil}, owners(t, reg))
+	assert.True(t, types.IsNotFound(reg.Remove("marathon-1")))
+
+	assert.NoError(t, reg.RemoveTenant("red"))
+	assert.True(t, types.IsNotFound(reg.RemoveTenant("red")))
+}
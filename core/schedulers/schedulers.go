@@ -0,0 +1,73 @@
+// Package schedulers validates and registers the schedulers receiving volume
+// offers
+package schedulers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tenants"
+)
+
+const validateKey = "polly.schedulers.validate"
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// validName matches the scheduler names, which are store keys
+var validName = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9._:]{0,127}$`)
+
+// Validating returns whether the volumes may only be offered to registered
+// schedulers
+func Validating(config gofig.Config) bool {
+	return config.GetBool(validateKey)
+}
+
+// Validate checks the name and the type of a scheduler
+func Validate(s *types.Scheduler) error {
+	if !validName.MatchString(s.Name) {
+		return types.NewErrorf(http.StatusBadRequest,
+			"invalid scheduler name %q", s.Name).WithDetail("scheduler", s.Name)
+	}
+	for _, t := range types.SchedulerTypes {
+		if s.Type == t {
+			return nil
+		}
+	}
+	return types.NewErrorf(http.StatusBadRequest,
+		"invalid scheduler type %q, expected one of %s",
+		s.Type, strings.Join(types.SchedulerTypes, ", ")).
+		WithDetail("scheduler", s.Name)
+}
+
+// SetTenants sets the tenant of each scheduler from the schedulers of the
+// tenants
+func SetTenants(ss []*types.Scheduler, all []*types.Tenant) {
+	owners := tenants.Owners(all)
+	for _, s := range ss {
+		s.Tenant = owners[s.Name]
+	}
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Schedulers")
+	r.Key(gofig.Bool, "", false,
+		"Reject the offers of volumes to unregistered schedulers", validateKey)
+	r.Key(gofig.String, "", "",
+		"How long a registered scheduler may go unseen before a warning "+
+			"event, no warning when empty", warnAfterKey)
+	r.Key(gofig.String, "", "",
+		"How long a registered scheduler may go unseen before it is stale, "+
+			"never when empty", staleAfterKey)
+	r.Key(gofig.String, "", ActionSuspend,
+		"What happens to the offers of a stale scheduler, suspend or revoke",
+		staleActionKey)
+	r.Key(gofig.String, "", "1m",
+		"The time between two liveness checks of the registered schedulers",
+		checkIntervalKey)
+	return r
+}
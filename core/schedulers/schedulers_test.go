@@ -0,0 +1,40 @@
+package schedulers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{"mesos-99", "k8s.prod:east", "Marathon"} {
+		assert.NoError(t, Validate(&types.Scheduler{
+			Name: name, Type: types.SchedulerTypeMesos}), name)
+	}
+	for _, name := range []string{"", "-mesos", "a/b", "a b"} {
+		err := Validate(&types.Scheduler{Name: name, Type: types.SchedulerTypeMesos})
+		assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err), name)
+	}
+
+	err := Validate(&types.Scheduler{Name: "nomad-1", Type: "nomad"})
+	assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err))
+	err = Validate(&types.Scheduler{Name: "nomad-1"})
+	assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err))
+}
+
+func TestSetTenants(t *testing.T) {
+	ss := []*types.Scheduler{
+		{Name: "marathon-blue"},
+		{Name: "k8s-red"},
+		{Name: "swarm-shared", Tenant: "stale"},
+	}
+	SetTenants(ss, []*types.Tenant{
+		{Name: "blue", Schedulers: []string{"marathon-blue"}},
+		{Name: "red", Schedulers: []string{"k8s-red"}},
+	})
+	assert.Equal(t, "blue", ss[0].Tenant)
+	assert.Equal(t, "red", ss[1].Tenant)
+	assert.Equal(t, "", ss[2].Tenant)
+}
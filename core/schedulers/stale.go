@@ -0,0 +1,91 @@
+package schedulers
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+)
+
+const (
+	warnAfterKey     = "polly.schedulers.warnAfter"
+	staleAfterKey    = "polly.schedulers.staleAfter"
+	staleActionKey   = "polly.schedulers.staleAction"
+	checkIntervalKey = "polly.schedulers.checkInterval"
+)
+
+const (
+	// ActionSuspend withholds the offers of the volumes from a stale
+	// scheduler until it is seen again
+	ActionSuspend = "suspend"
+	// ActionRevoke revokes the offers of the volumes to a stale scheduler
+	ActionRevoke = "revoke"
+)
+
+// Policy is how long a registered scheduler may go unseen before it is
+// warned about and then acted upon as stale
+type Policy struct {
+	// WarnAfter is the time unseen before the warning, none when 0
+	WarnAfter time.Duration
+
+	// StaleAfter is the time unseen before the scheduler is stale, never
+	// when 0
+	StaleAfter time.Duration
+
+	// Action is ActionSuspend or ActionRevoke
+	Action string
+}
+
+// NewPolicy returns the configured staleness policy. An unknown action is
+// taken as ActionSuspend, which can be undone.
+func NewPolicy(cfg gofig.Config) *Policy {
+	p := &Policy{
+		WarnAfter:  config.Duration(cfg, warnAfterKey, 0),
+		StaleAfter: config.Duration(cfg, staleAfterKey, 0),
+		Action:     cfg.GetString(staleActionKey),
+	}
+	if p.Action != ActionRevoke {
+		p.Action = ActionSuspend
+	}
+	return p
+}
+
+// CheckInterval returns the configured time between two liveness checks of
+// the registered schedulers
+func CheckInterval(cfg gofig.Config) time.Duration {
+	return config.Duration(cfg, checkIntervalKey, time.Minute)
+}
+
+// Enabled returns whether the policy warns about or acts upon any scheduler
+func (p *Policy) Enabled() bool {
+	return p.WarnAfter > 0 || p.StaleAfter > 0
+}
+
+// Unseen returns how long a scheduler was not seen, since its registration
+// before its first heartbeat
+func Unseen(s *types.Scheduler, now time.Time) time.Duration {
+	seen := s.Created
+	if s.LastSeen != nil {
+		seen = *s.LastSeen
+	}
+	return now.Sub(seen)
+}
+
+// State returns the liveness of a scheduler
+func (p *Policy) State(s *types.Scheduler, now time.Time) string {
+	unseen := Unseen(s, now)
+	switch {
+	case p.StaleAfter > 0 && unseen >= p.StaleAfter:
+		return types.SchedulerStateStale
+	case p.WarnAfter > 0 && unseen >= p.WarnAfter:
+		return types.SchedulerStateWarning
+	}
+	return types.SchedulerStateLive
+}
+
+// Suspended returns whether the offers to a scheduler are withheld
+func (p *Policy) Suspended(s *types.Scheduler, now time.Time) bool {
+	return p.Action == ActionSuspend &&
+		p.State(s, now) == types.SchedulerStateStale
+}
@@ -0,0 +1,44 @@
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyState(t *testing.T) {
+	now := time.Now()
+	seen := now.Add(-10 * time.Minute)
+	s := &types.Scheduler{Name: "mesos-99", Created: now.Add(-time.Hour)}
+
+	p := &Policy{
+		WarnAfter:  5 * time.Minute,
+		StaleAfter: 15 * time.Minute,
+		Action:     ActionSuspend,
+	}
+	assert.True(t, p.Enabled())
+
+	// before its first heartbeat a scheduler is unseen since registered
+	assert.Equal(t, time.Hour, Unseen(s, now))
+	assert.Equal(t, types.SchedulerStateStale, p.State(s, now))
+	assert.True(t, p.Suspended(s, now))
+
+	s.LastSeen = &seen
+	assert.Equal(t, types.SchedulerStateWarning, p.State(s, now))
+	assert.False(t, p.Suspended(s, now))
+	assert.Equal(t, types.SchedulerStateLive, p.State(s, seen.Add(time.Minute)))
+
+	p.Action = ActionRevoke
+	assert.False(t, p.Suspended(s, now.Add(time.Hour)))
+
+	// without a stale period schedulers are only warned about
+	p = &Policy{WarnAfter: 5 * time.Minute, Action: ActionSuspend}
+	assert.Equal(t, types.SchedulerStateWarning, p.State(s, now.Add(time.Hour)))
+	assert.False(t, p.Suspended(s, now.Add(time.Hour)))
+
+	p = &Policy{Action: ActionSuspend}
+	assert.False(t, p.Enabled())
+	assert.Equal(t, types.SchedulerStateLive, p.State(s, now.Add(time.Hour)))
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/akutz/goof"
+	store "github.com/docker/libkv/store"
+)
+
+// DockerMounts returns the IDs of the containers using the volumes mounted
+// by the Docker volume plugin of a host, keyed by volume ID
+func (ps *PollyStore) DockerMounts(host string) (map[string][]string, error) {
+	tree, err := ps.listTree(DockerMountsType)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string][]string)
+	for volumeID, pairs := range tree {
+		for _, pair := range pairs {
+			if !strings.HasSuffix(pair.Key, "/"+host) {
+				continue
+			}
+			var ids []string
+			if err := json.Unmarshal(pair.Value, &ids); err != nil {
+				return nil, goof.WithFieldE(
+					"volumeID", volumeID, "invalid docker mounts", err)
+			}
+			mounts[volumeID] = ids
+		}
+	}
+	return mounts, nil
+}
+
+// SaveDockerMounts saves the IDs of the containers using a volume mounted by
+// the Docker volume plugin of a host, removing them when there are none
+func (ps *PollyStore) SaveDockerMounts(host, volumeID string, ids []string) error {
+	key, err := ps.GenerateObjectKey(DockerMountsType, volumeID)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		if err := ps.Delete(key + host); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+host, b)
+}
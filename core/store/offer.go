@@ -0,0 +1,81 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/akutz/goof"
+	store "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+)
+
+// offerStateKey is the key of the offer state below the key of a volume
+const offerStateKey = "state"
+
+// GetVolumeOfferState returns the lease and declines of the offers of a
+// volume, an empty state when there are none
+func (ps *PollyStore) GetVolumeOfferState(volumeID string) (*types.OfferState, error) {
+	key, err := ps.GenerateObjectKey(VolumeOffersType, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ps.Get(key + offerStateKey)
+	if err == store.ErrKeyNotFound {
+		return &types.OfferState{}, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("volumeID", volumeID, "problem getting offer state", err)
+	}
+
+	state := &types.OfferState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, goof.WithFieldE("volumeID", volumeID, "invalid offer state", err)
+	}
+	return state, nil
+}
+
+// SaveVolumeOfferState saves the lease and declines of the offers of a
+// volume, removing the state when it is empty
+func (ps *PollyStore) SaveVolumeOfferState(volumeID string, state *types.OfferState) error {
+	key, err := ps.GenerateObjectKey(VolumeOffersType, volumeID)
+	if err != nil {
+		return err
+	}
+
+	if state.Lease == nil && len(state.Declines) == 0 {
+		if err := ps.deleteTree(key); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+offerStateKey, b)
+}
+
+// VolumeOfferStates returns the offer states of the volumes keyed by volume
+// ID, volumes without leases or declines are left out
+func (ps *PollyStore) VolumeOfferStates() (map[string]*types.OfferState, error) {
+	tree, err := ps.listTree(VolumeOffersType)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]*types.OfferState)
+	for volumeID, pairs := range tree {
+		for _, pair := range pairs {
+			if !strings.HasSuffix(pair.Key, "/"+offerStateKey) {
+				continue
+			}
+			state := &types.OfferState{}
+			if err := json.Unmarshal(pair.Value, state); err != nil {
+				return nil, goof.WithFieldE(
+					"volumeID", volumeID, "invalid offer state", err)
+			}
+			states[volumeID] = state
+		}
+	}
+	return states, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+	store "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+)
+
+const (
+	// schedulerKey is the key of a scheduler below its name
+	schedulerKey = "scheduler"
+	// seenKey is the key of the last heartbeat of a scheduler below its name,
+	// apart from the scheduler so heartbeats do not rewrite it
+	seenKey = "seen"
+)
+
+// GetScheduler returns a registered scheduler, nil when it does not exist
+func (ps *PollyStore) GetScheduler(name string) (*types.Scheduler, error) {
+	key, err := ps.GenerateObjectKey(SchedulersType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ps.Get(key + schedulerKey)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("scheduler", name, "problem getting scheduler", err)
+	}
+
+	s := &types.Scheduler{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, goof.WithFieldE("scheduler", name, "invalid scheduler", err)
+	}
+
+	b, err = ps.Get(key + seenKey)
+	if err == store.ErrKeyNotFound {
+		return s, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("scheduler", name, "problem getting heartbeat", err)
+	}
+	if s.LastSeen, err = parseSeen(b); err != nil {
+		return nil, goof.WithFieldE("scheduler", name, "invalid heartbeat", err)
+	}
+	return s, nil
+}
+
+// SaveScheduler saves a scheduler, its tenant, last heartbeat and state are
+// not stored with it
+func (ps *PollyStore) SaveScheduler(s *types.Scheduler) error {
+	key, err := ps.GenerateObjectKey(SchedulersType, s.Name)
+	if err != nil {
+		return err
+	}
+
+	ss := *s
+	ss.Tenant = ""
+	ss.LastSeen = nil
+	ss.State = ""
+	b, err := json.Marshal(&ss)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+schedulerKey, b)
+}
+
+// SaveSchedulerHeartbeat records the time a scheduler was last seen
+func (ps *PollyStore) SaveSchedulerHeartbeat(name string, seen time.Time) error {
+	key, err := ps.GenerateObjectKey(SchedulersType, name)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+seenKey, []byte(seen.UTC().Format(time.RFC3339Nano)))
+}
+
+// Schedulers returns the registered schedulers ordered by name
+func (ps *PollyStore) Schedulers() ([]*types.Scheduler, error) {
+	tree, err := ps.listTree(SchedulersType)
+	if err != nil {
+		return nil, err
+	}
+
+	schedulers := []*types.Scheduler{}
+	for name, pairs := range tree {
+		var (
+			s    *types.Scheduler
+			seen *time.Time
+		)
+		for _, pair := range pairs {
+			switch {
+			case strings.HasSuffix(pair.Key, "/"+schedulerKey):
+				s = &types.Scheduler{}
+				if err := json.Unmarshal(pair.Value, s); err != nil {
+					return nil, goof.WithFieldE(
+						"scheduler", name, "invalid scheduler", err)
+				}
+			case strings.HasSuffix(pair.Key, "/"+seenKey):
+				if seen, err = parseSeen(pair.Value); err != nil {
+					return nil, goof.WithFieldE(
+						"scheduler", name, "invalid heartbeat", err)
+				}
+			}
+		}
+		if s == nil {
+			continue
+		}
+		s.LastSeen = seen
+		schedulers = append(schedulers, s)
+	}
+	sort.Slice(schedulers, func(i, j int) bool {
+		return schedulers[i].Name < schedulers[j].Name
+	})
+	return schedulers, nil
+}
+
+// RemoveScheduler removes a scheduler along with its last heartbeat
+func (ps *PollyStore) RemoveScheduler(name string) error {
+	key, err := ps.GenerateObjectKey(SchedulersType, name)
+	if err != nil {
+		return err
+	}
+	return ps.deleteTree(key)
+}
+
+func parseSeen(b []byte) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, string(b))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strings"
@@ -16,7 +17,10 @@ import (
 	"github.com/akutz/gofig"
 	"github.com/akutz/goof"
 	"github.com/docker/libkv"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/tracing"
 	version "github.com/emccode/polly/core/version"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -26,12 +30,31 @@ const (
 	VolumeInternalLabelsType = 2
 	//VolumeAdminLabelsType is used to identify labels for the Polly admin layer
 	VolumeAdminLabelsType = 3
+	//VolumeOffersType is used to identify the leases and declines of volume offers
+	VolumeOffersType = 4
+	//WebhooksType is used to identify the webhooks and their delivery logs
+	WebhooksType = 5
+	//TenantsType is used to identify the tenants
+	TenantsType = 6
+	//TokensType is used to identify the admin tokens of the tenants by hash
+	TokensType = 7
+	//SchedulersType is used to identify the registered schedulers
+	SchedulersType = 8
+	//DockerMountsType is used to identify the containers using the volumes
+	//mounted by the Docker volume plugins
+	DockerMountsType = 9
 )
 
 const (
 	storeVolumeLibStorage         = "volumelibstorage"
 	storeVolumeInternalLabelsType = "volumeinternallabels"
 	storeVolumeAdminLabelsType    = "volumeadminlabels"
+	storeVolumeOffersType         = "volumeoffers"
+	storeWebhooksType             = "webhooks"
+	storeTenantsType              = "tenants"
+	storeTokensType               = "tokens"
+	storeSchedulersType           = "schedulers"
+	storeDockerMountsType         = "dockermounts"
 	rootKey                       = "polly"
 )
 
@@ -49,6 +72,9 @@ type PollyStore struct {
 	config gofig.Config
 	store  store.Store
 	root   string
+
+	// ctx holds the span of the request the backend calls are traced under
+	ctx context.Context
 }
 
 func init() {
@@ -113,7 +139,9 @@ func NewWithConfig(config gofig.Config) (pollystore *PollyStore, err error) {
 	}
 
 	if err := ps.initKeys([]int{VolumeType,
-		VolumeInternalLabelsType, VolumeAdminLabelsType}); err != nil {
+		VolumeInternalLabelsType, VolumeAdminLabelsType, VolumeOffersType,
+		WebhooksType, TenantsType, TokensType, SchedulersType,
+		DockerMountsType}); err != nil {
 		return nil, err
 	}
 
@@ -136,6 +164,42 @@ func (ps *PollyStore) versionKey() string {
 	return ps.root + "version"
 }
 
+// LeaderKey is the key holding the lock of the elected Polly daemon
+func (ps *PollyStore) LeaderKey() string {
+	return strings.TrimSuffix(ps.root, "/") + "/leader"
+}
+
+// RegistryKey is the key holding the lock of the scheduler registry changes
+func (ps *PollyStore) RegistryKey() string {
+	return strings.TrimSuffix(ps.root, "/") + "/registry"
+}
+
+// OffersKey is the key holding the lock of the offer state changes
+func (ps *PollyStore) OffersKey() string {
+	return strings.TrimSuffix(ps.root, "/") + "/offers"
+}
+
+// NewLock creates a distributed lock on a key. Backends without locking
+// support, such as boltdb, return store.ErrCallNotSupported.
+func (ps *PollyStore) NewLock(
+	key string, options *store.LockOptions) (store.Locker, error) {
+	start := time.Now()
+	lock, err := ps.store.NewLock(key, options)
+	ps.observe("newlock", start, err)
+	return lock, err
+}
+
+// Get returns the value of a key
+func (ps *PollyStore) Get(key string) ([]byte, error) {
+	start := time.Now()
+	pair, err := ps.store.Get(key)
+	ps.observe("get", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return pair.Value, nil
+}
+
 // Put saves key value pairs
 func (ps *PollyStore) Put(key string, bytes []byte) error {
 	log.WithFields(log.Fields{
@@ -143,7 +207,10 @@ func (ps *PollyStore) Put(key string, bytes []byte) error {
 		"bytes":       bytes,
 		"bytesString": string(bytes),
 	}).Debug("putting key value")
-	return ps.store.Put(key, bytes, nil)
+	start := time.Now()
+	err := ps.store.Put(key, bytes, nil)
+	ps.observe("put", start, err)
+	return err
 }
 
 // Delete removes a key value pair
@@ -151,7 +218,10 @@ func (ps *PollyStore) Delete(key string) error {
 	log.WithFields(log.Fields{
 		"key": key,
 	}).Debug("deleting key value")
-	return ps.store.Delete(key)
+	start := time.Now()
+	err := ps.store.Delete(key)
+	ps.observe("delete", start, err)
+	return err
 }
 
 //GenerateObjectKey generates the internal path (=key) for an object
@@ -183,6 +253,18 @@ func (ps *PollyStore) generateKey(mytype int, guid string) (path string, err err
 		parts = append(parts, storeVolumeInternalLabelsType)
 	case VolumeAdminLabelsType:
 		parts = append(parts, storeVolumeAdminLabelsType)
+	case VolumeOffersType:
+		parts = append(parts, storeVolumeOffersType)
+	case WebhooksType:
+		parts = append(parts, storeWebhooksType)
+	case TenantsType:
+		parts = append(parts, storeTenantsType)
+	case TokensType:
+		parts = append(parts, storeTokensType)
+	case SchedulersType:
+		parts = append(parts, storeSchedulersType)
+	case DockerMountsType:
+		parts = append(parts, storeDockerMountsType)
 	default:
 		return "", ErrObjectInvalid
 	}
@@ -210,7 +292,9 @@ func (ps *PollyStore) GetKeyFromFQKN(fqkn string) (mykey string, err error) {
 
 // List lists the key values pairs for a key
 func (ps *PollyStore) List(key string) ([]*store.KVPair, error) {
+	start := time.Now()
 	list, err := ps.store.List(key)
+	ps.observe("list", start, err)
 	if err != nil {
 		return nil, goof.WithError("problem listing key values", err)
 	}
@@ -249,13 +333,36 @@ func (ps *PollyStore) EraseType(mytype int) error {
 	if rkey, err = ps.GenerateRootKey(mytype); err != nil {
 		return err
 	}
-	if err := ps.store.DeleteTree(rkey); err != nil {
+	if err := ps.deleteTree(rkey); err != nil {
 		return err
 	}
 	ps.Put(rkey, []byte(""))
 	return nil
 }
 
+func (ps *PollyStore) deleteTree(key string) error {
+	start := time.Now()
+	err := ps.store.DeleteTree(key)
+	ps.observe("deletetree", start, err)
+	return err
+}
+
+// WithContext returns a copy of the store whose backend calls are traced as
+// children of the span of ctx
+func (ps *PollyStore) WithContext(ctx context.Context) *PollyStore {
+	rps := *ps
+	rps.ctx = ctx
+	return &rps
+}
+
+func (ps *PollyStore) observe(op string, start time.Time, err error) {
+	metrics.ObserveStore(ps.StoreType(), op, start, err)
+	if ps.ctx != nil {
+		tracing.Record(ps.ctx, "store."+op, start, err,
+			attribute.String("store.type", ps.StoreType()))
+	}
+}
+
 //StoreType this generates the type of backing store to use
 func (ps *PollyStore) StoreType() string {
 	return ps.config.GetString("polly.store.type")
@@ -276,10 +383,18 @@ func (ps *PollyStore) Bucket() string {
 	return ps.config.GetString("polly.store.bucket")
 }
 
+// Close releases the connection to the store backend
+func (ps *PollyStore) Close() {
+	log.WithField("type", ps.StoreType()).Debug("closing store")
+	ps.store.Close()
+}
+
 // Version of the metadata in the store
 func (ps *PollyStore) Version() (string, error) {
 	versionKey := ps.root + "version"
+	start := time.Now()
 	pair, err := ps.store.Get(versionKey)
+	ps.observe("get", start, err)
 	if err != nil {
 		return "", err
 	}
@@ -238,6 +238,32 @@ func TestRemoveVolumeMetadata(t *testing.T) {
 	assert.Len(t, volume.Schedulers, 0)
 }
 
+func TestSetVolumesMetadata(t *testing.T) {
+	volume := newVolume("pollytestpkg3", "testid1")
+	volume.Schedulers = []string{"testScheduler"}
+	volume.Labels["testkey1"] = "testval1"
+	err := ps.SaveVolumeMetadata(volume)
+	assert.NoError(t, err)
+
+	volume = newVolume("pollytestpkg3", "testid10")
+	err = ps.SaveVolumeMetadata(volume)
+	assert.NoError(t, err)
+
+	volumes := []*types.Volume{
+		newVolume("pollytestpkg3", "testid1"),
+		newVolume("pollytestpkg3", "testiddoesntexist"),
+		newVolume("pollytestpkg3", "testid10"),
+	}
+	exists, err := ps.SetVolumesMetadata(volumes)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true}, exists)
+	assert.Equal(t, []string{"testScheduler"}, volumes[0].Schedulers)
+	assert.Equal(t, "testval1", volumes[0].Labels["testkey1"])
+	assert.NotZero(t, volumes[0].Created)
+	assert.Len(t, volumes[2].Schedulers, 0)
+	assert.Len(t, volumes[2].Labels, 0)
+}
+
 func TestEraseStore(t *testing.T) {
 	myConfig := gofig.New()
 
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+	store "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+)
+
+const (
+	// tenantKey is the key of a tenant below its name
+	tenantKey = "tenant"
+	// tokenKey is the key of a token below its hash
+	tokenKey = "token"
+)
+
+// GetTenant returns a tenant, nil when it does not exist
+func (ps *PollyStore) GetTenant(name string) (*types.Tenant, error) {
+	key, err := ps.GenerateObjectKey(TenantsType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ps.Get(key + tenantKey)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("tenant", name, "problem getting tenant", err)
+	}
+
+	t := &types.Tenant{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, goof.WithFieldE("tenant", name, "invalid tenant", err)
+	}
+	return t, nil
+}
+
+// SaveTenant saves a tenant
+func (ps *PollyStore) SaveTenant(t *types.Tenant) error {
+	key, err := ps.GenerateObjectKey(TenantsType, t.Name)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+tenantKey, b)
+}
+
+// Tenants returns the tenants ordered by name
+func (ps *PollyStore) Tenants() ([]*types.Tenant, error) {
+	tree, err := ps.listTree(TenantsType)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := []*types.Tenant{}
+	for name, pairs := range tree {
+		for _, pair := range pairs {
+			if !strings.HasSuffix(pair.Key, "/"+tenantKey) {
+				continue
+			}
+			t := &types.Tenant{}
+			if err := json.Unmarshal(pair.Value, t); err != nil {
+				return nil, goof.WithFieldE("tenant", name, "invalid tenant", err)
+			}
+			tenants = append(tenants, t)
+		}
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].Name < tenants[j].Name
+	})
+	return tenants, nil
+}
+
+// RemoveTenant removes a tenant along with its tokens
+func (ps *PollyStore) RemoveTenant(name string) error {
+	tokens, err := ps.TenantTokens(name)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err := ps.RemoveTenantToken(t.Tenant, t.ID); err != nil {
+			return err
+		}
+	}
+
+	key, err := ps.GenerateObjectKey(TenantsType, name)
+	if err != nil {
+		return err
+	}
+	return ps.deleteTree(key)
+}
+
+// tenantToken is a token as stored, keyed by the hash of its value
+type tenantToken struct {
+	types.TenantToken
+	Hash string `json:"hash"`
+}
+
+// GetTenantToken returns the token of a hash, nil when it does not exist
+func (ps *PollyStore) GetTenantToken(hash string) (*types.TenantToken, error) {
+	key, err := ps.GenerateObjectKey(TokensType, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ps.Get(key + tokenKey)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, goof.WithError("problem getting token", err)
+	}
+
+	t := &tenantToken{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, goof.WithError("invalid token", err)
+	}
+	return &t.TenantToken, nil
+}
+
+// SaveTenantToken saves a token under the hash of its value, which is not
+// stored
+func (ps *PollyStore) SaveTenantToken(t *types.TenantToken, hash string) error {
+	key, err := ps.GenerateObjectKey(TokensType, hash)
+	if err != nil {
+		return err
+	}
+
+	st := &tenantToken{TenantToken: *t, Hash: hash}
+	st.Token = ""
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+tokenKey, b)
+}
+
+// TenantTokens returns the tokens of a tenant ordered by creation time
+func (ps *PollyStore) TenantTokens(tenant string) ([]*types.TenantToken, error) {
+	stored, err := ps.tenantTokens(tenant)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]*types.TenantToken, len(stored))
+	for i, t := range stored {
+		tokens[i] = &t.TenantToken
+	}
+	return tokens, nil
+}
+
+func (ps *PollyStore) tenantTokens(tenant string) ([]*tenantToken, error) {
+	tree, err := ps.listTree(TokensType)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []*tenantToken{}
+	for _, pairs := range tree {
+		for _, pair := range pairs {
+			if !strings.HasSuffix(pair.Key, "/"+tokenKey) {
+				continue
+			}
+			t := &tenantToken{}
+			if err := json.Unmarshal(pair.Value, t); err != nil {
+				return nil, goof.WithError("invalid token", err)
+			}
+			if t.Tenant == tenant {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Created.Before(tokens[j].Created)
+	})
+	return tokens, nil
+}
+
+// RemoveTenantToken removes a token of a tenant, it returns
+// store.ErrKeyNotFound when the tenant has no such token
+func (ps *PollyStore) RemoveTenantToken(tenant, id string) error {
+	tokens, err := ps.tenantTokens(tenant)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID != id {
+			continue
+		}
+		key, err := ps.GenerateObjectKey(TokensType, t.Hash)
+		if err != nil {
+			return err
+		}
+		return ps.deleteTree(key)
+	}
+	return store.ErrKeyNotFound
+}
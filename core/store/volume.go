@@ -3,7 +3,9 @@ package store
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/goof"
@@ -19,7 +21,9 @@ func (ps *PollyStore) Exists(volume *types.Volume) (bool, error) {
 	}
 
 	log.WithField("key", key).Debug("checking for the existence of key")
+	start := time.Now()
 	exists, err := ps.store.Exists(key)
+	ps.observe("exists", start, err)
 	if err != nil {
 		return exists, goof.WithError("problem checking key", err)
 	}
@@ -72,7 +76,7 @@ func (ps *PollyStore) SaveVolumeFields(volume *types.Volume) error {
 	// 				found = true
 	// 			}
 	// 			if !found {
-	// 				ps.store.Delete(pair.Key)
+	// 				ps.Delete(pair.Key)
 	// 			}
 	// 		}
 	// 	}
@@ -117,7 +121,7 @@ func (ps *PollyStore) SaveVolumeAdminLabels(volume *types.Volume) error {
 			}
 			if !found {
 				log.WithField("key", pair.Key).Debug("delete key")
-				ps.store.Delete(pair.Key)
+				ps.Delete(pair.Key)
 			}
 		}
 	}
@@ -175,6 +179,26 @@ func (ps *PollyStore) SaveVolumeMetadata(volume *types.Volume) error {
 		return err
 	}
 
+	if volume.Tenant == "" {
+		err = ps.Delete(key + "Tenant")
+		if err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	} else {
+		err = ps.Put(key+"Tenant", []byte(volume.Tenant))
+		if err != nil {
+			return err
+		}
+	}
+
+	if volume.Created == 0 {
+		volume.Created = time.Now().Unix()
+	}
+	err = ps.Put(key+"Created", []byte(strconv.FormatInt(volume.Created, 10)))
+	if err != nil {
+		return err
+	}
+
 	err = ps.SaveVolumeFields(volume)
 	if err != nil {
 		return goof.New("failed to save fields")
@@ -266,6 +290,10 @@ func (ps *PollyStore) SetVolumeMetadata(volume *types.Volume) (bool, error) {
 			}
 		case "ServiceName":
 			volume.ServiceName = string(pair.Value)
+		case "Tenant":
+			volume.Tenant = string(pair.Value)
+		case "Created":
+			volume.Created, _ = strconv.ParseInt(string(pair.Value), 10, 64)
 		}
 	}
 
@@ -276,16 +304,125 @@ func (ps *PollyStore) SetVolumeMetadata(volume *types.Volume) (bool, error) {
 	return exists, nil
 }
 
+// SetVolumesMetadata sets the metadata of many volumes from a single scan of
+// the internal and admin label trees, and returns whether each volume is
+// known to the store. It is the bulk equivalent of SetVolumeMetadata.
+func (ps *PollyStore) SetVolumesMetadata(volumes []*types.Volume) ([]bool, error) {
+	internal, err := ps.listTree(VolumeInternalLabelsType)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := ps.listTree(VolumeAdminLabelsType)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make([]bool, len(volumes))
+	for i, volume := range volumes {
+		pairs, ok := internal[volume.VolumeID]
+		if !ok {
+			continue
+		}
+		exists[i] = true
+
+		for _, pair := range pairs {
+			key, err := ps.GetKeyFromFQKN(pair.Key)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "Schedulers":
+				if len(pair.Value) == 0 {
+					break
+				}
+				if err := json.Unmarshal(pair.Value, &volume.Schedulers); err != nil {
+					return nil, err
+				}
+			case "ServiceName":
+				volume.ServiceName = string(pair.Value)
+			case "Tenant":
+				volume.Tenant = string(pair.Value)
+			case "Created":
+				volume.Created, _ = strconv.ParseInt(string(pair.Value), 10, 64)
+			}
+		}
+
+		volume.Labels = make(map[string]string)
+		for _, pair := range admin[volume.VolumeID] {
+			key, err := ps.GetKeyFromFQKN(pair.Key)
+			if err != nil {
+				continue
+			}
+			if key != "" {
+				volume.Labels[key] = string(pair.Value)
+			}
+		}
+	}
+
+	return exists, nil
+}
+
+// VolumeTenants returns the tenant of each volume of the store owned by one
+func (ps *PollyStore) VolumeTenants() (map[string]string, error) {
+	internal, err := ps.listTree(VolumeInternalLabelsType)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[string]string)
+	for id, pairs := range internal {
+		for _, pair := range pairs {
+			if strings.HasSuffix(pair.Key, "/Tenant") && len(pair.Value) > 0 {
+				tenants[id] = string(pair.Value)
+			}
+		}
+	}
+	return tenants, nil
+}
+
+// listTree lists every key of a type and groups them by volume ID
+func (ps *PollyStore) listTree(mytype int) (map[string][]*store.KVPair, error) {
+	root, err := ps.GenerateRootKey(mytype)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	pairs, err := ps.store.List(root)
+	if err == store.ErrKeyNotFound {
+		err = nil
+	}
+	ps.observe("list", start, err)
+	if err != nil {
+		return nil, goof.WithError("problem listing key values", err)
+	}
+
+	tree := make(map[string][]*store.KVPair)
+	for _, pair := range pairs {
+		rel := strings.TrimPrefix(pair.Key, root)
+		if rel == pair.Key {
+			continue
+		}
+		parts := strings.SplitN(rel, "/", 2)
+		if parts[0] == "" {
+			continue
+		}
+		tree[parts[0]] = append(tree[parts[0]], pair)
+	}
+	return tree, nil
+}
+
 //RemoveVolumeMetadata This function will save all metadata associated with a volume
 func (ps *PollyStore) RemoveVolumeMetadata(volume *types.Volume) error {
-	deletelist := []int{VolumeType, VolumeInternalLabelsType, VolumeAdminLabelsType}
+	deletelist := []int{VolumeType, VolumeInternalLabelsType,
+		VolumeAdminLabelsType, VolumeOffersType}
 	for _, deleteme := range deletelist {
 		key, err := ps.GenerateObjectKey(deleteme, volume.VolumeID)
 		if err != nil {
 			return err
 		}
 
-		err = ps.store.DeleteTree(key)
+		err = ps.deleteTree(key)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+	store "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+)
+
+const (
+	// webhookKey is the key of a webhook below its ID
+	webhookKey = "hook"
+	// deliveriesKey is the key of the delivery log of a webhook below its ID
+	deliveriesKey = "deliveries"
+)
+
+// GetWebhook returns a webhook, nil when it does not exist
+func (ps *PollyStore) GetWebhook(id string) (*types.Webhook, error) {
+	key, err := ps.GenerateObjectKey(WebhooksType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ps.Get(key + webhookKey)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("webhookID", id, "problem getting webhook", err)
+	}
+
+	hook := &types.Webhook{}
+	if err := json.Unmarshal(b, hook); err != nil {
+		return nil, goof.WithFieldE("webhookID", id, "invalid webhook", err)
+	}
+	return hook, nil
+}
+
+// SaveWebhook saves a webhook
+func (ps *PollyStore) SaveWebhook(hook *types.Webhook) error {
+	key, err := ps.GenerateObjectKey(WebhooksType, hook.ID)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(hook)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+webhookKey, b)
+}
+
+// Webhooks returns the webhooks ordered by creation time
+func (ps *PollyStore) Webhooks() ([]*types.Webhook, error) {
+	tree, err := ps.listTree(WebhooksType)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := []*types.Webhook{}
+	for id, pairs := range tree {
+		for _, pair := range pairs {
+			if !strings.HasSuffix(pair.Key, "/"+webhookKey) {
+				continue
+			}
+			hook := &types.Webhook{}
+			if err := json.Unmarshal(pair.Value, hook); err != nil {
+				return nil, goof.WithFieldE("webhookID", id, "invalid webhook", err)
+			}
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].Created.Equal(hooks[j].Created) {
+			return hooks[i].ID < hooks[j].ID
+		}
+		return hooks[i].Created.Before(hooks[j].Created)
+	})
+	return hooks, nil
+}
+
+// RemoveWebhook removes a webhook along with its delivery log
+func (ps *PollyStore) RemoveWebhook(id string) error {
+	key, err := ps.GenerateObjectKey(WebhooksType, id)
+	if err != nil {
+		return err
+	}
+	return ps.deleteTree(key)
+}
+
+// WebhookDeliveries returns the delivery log of a webhook, oldest first
+func (ps *PollyStore) WebhookDeliveries(id string) ([]*types.WebhookDelivery, error) {
+	key, err := ps.GenerateObjectKey(WebhooksType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := []*types.WebhookDelivery{}
+	b, err := ps.Get(key + deliveriesKey)
+	if err == store.ErrKeyNotFound {
+		return ds, nil
+	} else if err != nil {
+		return nil, goof.WithFieldE("webhookID", id, "problem getting deliveries", err)
+	}
+	if err := json.Unmarshal(b, &ds); err != nil {
+		return nil, goof.WithFieldE("webhookID", id, "invalid deliveries", err)
+	}
+	return ds, nil
+}
+
+// AppendWebhookDelivery adds a delivery to the log of a webhook, keeping the
+// last max deliveries. Deliveries to a removed webhook are not logged.
+func (ps *PollyStore) AppendWebhookDelivery(
+	id string, d *types.WebhookDelivery, max int) error {
+
+	if hook, err := ps.GetWebhook(id); err != nil || hook == nil {
+		return err
+	}
+	ds, err := ps.WebhookDeliveries(id)
+	if err != nil {
+		return err
+	}
+	ds = append(ds, d)
+	if len(ds) > max {
+		ds = ds[len(ds)-max:]
+	}
+
+	key, err := ps.GenerateObjectKey(WebhooksType, id)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(ds)
+	if err != nil {
+		return err
+	}
+	return ps.Put(key+deliveriesKey, b)
+}
@@ -0,0 +1,208 @@
+// Package tenants validates the tenants and authenticates the admin requests
+// by their bearer tokens
+package tenants
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
+)
+
+const adminTokenKey = "polly.admin.token"
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// validName matches the tenant names, DNS labels
+var validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// Store keeps the tokens of the tenants by hash
+type Store interface {
+	GetTenantToken(hash string) (*types.TenantToken, error)
+}
+
+// TokenStore lists the tenants and their tokens
+type TokenStore interface {
+	Tenants() ([]*types.Tenant, error)
+	TenantTokens(tenant string) ([]*types.TenantToken, error)
+}
+
+// Authenticator resolves the tenant of the bearer token of an admin request
+type Authenticator struct {
+	st    Store
+	token string
+}
+
+// New returns an authenticator of the tokens of a store and of the admin
+// token of the config
+func New(config gofig.Config, st Store) *Authenticator {
+	return &Authenticator{
+		st:    st,
+		token: config.GetString(adminTokenKey),
+	}
+}
+
+// Authenticate returns the tenant the credentials of a request are
+// restricted to, empty for the admin token. A request without a token is
+// rejected with a 401 when an admin token is set, it is not restricted
+// otherwise, and tenant tokens are then rejected since leaving them out
+// would lift their restriction.
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	token := bearer(r)
+	if token == "" {
+		if a.token != "" {
+			return "", unauthorized("missing bearer token")
+		}
+		return "", nil
+	}
+	if a.token == "" {
+		return "", unauthorized(
+			"tenant tokens are not accepted unless " + adminTokenKey + " is set")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+		return "", nil
+	}
+
+	t, err := a.st.GetTenantToken(Hash(token))
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", unauthorized("invalid bearer token")
+	}
+	return t.Tenant, nil
+}
+
+// CanIssue returns a 409 when no admin token is set, the tokens of the
+// tenants restricting nothing without one
+func (a *Authenticator) CanIssue() error {
+	if a == nil || a.token == "" {
+		return types.NewErrorf(http.StatusConflict,
+			"tenant tokens require %s to be set", adminTokenKey)
+	}
+	return nil
+}
+
+// Check returns an error when tenant tokens are stored but no admin token is
+// set, so the server does not start with tokens that restrict nothing
+func Check(config gofig.Config, st TokenStore) error {
+	if config.GetString(adminTokenKey) != "" {
+		return nil
+	}
+	tenants, err := st.Tenants()
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		tokens, err := st.TenantTokens(t.Name)
+		if err != nil {
+			return err
+		}
+		if len(tokens) > 0 {
+			return goof.WithFields(goof.Fields{
+				"tenant": t.Name,
+				"tokens": len(tokens),
+			}, "tenant tokens are stored but "+adminTokenKey+" is not set")
+		}
+	}
+	return nil
+}
+
+func bearer(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+func unauthorized(msg string) error {
+	return types.NewError(http.StatusUnauthorized, msg)
+}
+
+// Hash returns the hash a token is stored under
+func Hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// NewToken returns a token of a tenant along with the hash it is stored
+// under
+func NewToken(tenant string) (*types.TenantToken, string) {
+	t := &types.TenantToken{
+		ID:      random(8),
+		Tenant:  tenant,
+		Token:   random(24),
+		Created: time.Now().UTC(),
+	}
+	return t, Hash(t.Token)
+}
+
+func random(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Validate checks the name and the schedulers of a tenant, which must not be
+// registered to any of the other tenants
+func Validate(t *types.Tenant, others []*types.Tenant) error {
+	if !validName.MatchString(t.Name) {
+		return types.NewErrorf(http.StatusBadRequest,
+			"invalid tenant name %q, expected a DNS label", t.Name).
+			WithDetail("tenant", t.Name)
+	}
+	for _, s := range t.Schedulers {
+		if s == "" {
+			return types.NewError(http.StatusBadRequest,
+				"scheduler names must not be empty")
+		}
+		for _, o := range others {
+			if o.Name != t.Name && contains(o.Schedulers, s) {
+				return types.NewErrorf(http.StatusConflict,
+					"scheduler %q is registered to tenant %q", s, o.Name).
+					WithDetail("scheduler", s).
+					WithDetail("tenant", o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Owners returns the tenant each registered scheduler belongs to
+func Owners(tenants []*types.Tenant) map[string]string {
+	owners := make(map[string]string)
+	for _, t := range tenants {
+		for _, s := range t.Schedulers {
+			owners[s] = t.Name
+		}
+	}
+	return owners
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Tenants")
+	r.Key(gofig.SecureString, "", "",
+		"The bearer token of the unrestricted admin requests, required by "+
+			"the admin API when set", adminTokenKey)
+	return r
+}
@@ -0,0 +1,123 @@
+package tenants
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStore map[string]*types.TenantToken
+
+func (s fakeStore) GetTenantToken(hash string) (*types.TenantToken, error) {
+	return s[hash], nil
+}
+
+func newRequest(auth string) *http.Request {
+	r, _ := http.NewRequest("GET", "/admin/volumes", nil)
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	token, hash := NewToken("blue")
+	assert.Equal(t, Hash(token.Token), hash)
+	assert.NotEqual(t, token.Token, hash)
+
+	st := fakeStore{hash: token}
+	a := &Authenticator{st: st, token: "secret"}
+
+	tenant, err := a.Authenticate(newRequest("Bearer secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", tenant)
+
+	tenant, err = a.Authenticate(newRequest("bearer " + token.Token))
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", tenant)
+
+	for _, auth := range []string{"", "Bearer other", "Basic c2VjcmV0"} {
+		_, err = a.Authenticate(newRequest(auth))
+		assert.True(t, types.IsUnauthorized(err), auth)
+	}
+
+	// without an admin token the requests without a token are not restricted
+	open := &Authenticator{st: st}
+	tenant, err = open.Authenticate(newRequest(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "", tenant)
+
+	// and the tenant tokens are rejected, leaving them out would lift
+	// their restriction
+	for _, auth := range []string{"Bearer " + token.Token, "Bearer secret"} {
+		_, err = open.Authenticate(newRequest(auth))
+		assert.True(t, types.IsUnauthorized(err), auth)
+	}
+	assert.True(t, types.IsConflict(open.CanIssue()))
+	assert.NoError(t, a.CanIssue())
+}
+
+type fakeTokenStore map[string][]*types.TenantToken
+
+func (s fakeTokenStore) Tenants() ([]*types.Tenant, error) {
+	tenants := []*types.Tenant{}
+	for name := range s {
+		tenants = append(tenants, &types.Tenant{Name: name})
+	}
+	return tenants, nil
+}
+
+func (s fakeTokenStore) TenantTokens(tenant string) ([]*types.TenantToken, error) {
+	return s[tenant], nil
+}
+
+func TestCheck(t *testing.T) {
+	token, _ := NewToken("blue")
+	st := fakeTokenStore{"blue": {token}, "green": nil}
+	empty := fakeTokenStore{"green": nil}
+
+	config := gofig.New()
+	assert.Error(t, Check(config, st))
+	assert.NoError(t, Check(config, empty))
+
+	config.Set(adminTokenKey, "secret")
+	assert.NoError(t, Check(config, st))
+}
+
+func TestValidate(t *testing.T) {
+	others := []*types.Tenant{
+		{Name: "blue", Schedulers: []string{"marathon-blue"}},
+		{Name: "red", Schedulers: []string{"marathon-red", "k8s-red"}},
+	}
+
+	assert.NoError(t, Validate(&types.Tenant{Name: "green"}, others))
+	assert.NoError(t, Validate(&types.Tenant{
+		Name: "red", Schedulers: []string{"k8s-red"}}, others))
+
+	for _, name := range []string{"", "Blue", "-blue", "blue_1", "a.b"} {
+		err := Validate(&types.Tenant{Name: name}, nil)
+		assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err), name)
+	}
+
+	err := Validate(&types.Tenant{Name: "green", Schedulers: []string{""}}, nil)
+	assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err))
+
+	err = Validate(&types.Tenant{
+		Name: "green", Schedulers: []string{"k8s-red"}}, others)
+	assert.Equal(t, http.StatusConflict, types.ErrorStatus(err))
+	assert.Equal(t, "red", types.AsError(err).Details["tenant"])
+}
+
+func TestOwners(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"marathon-blue": "blue",
+		"marathon-red":  "red",
+		"k8s-red":       "red",
+	}, Owners([]*types.Tenant{
+		{Name: "blue", Schedulers: []string{"marathon-blue"}},
+		{Name: "red", Schedulers: []string{"marathon-red", "k8s-red"}},
+	}))
+}
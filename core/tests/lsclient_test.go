@@ -60,7 +60,7 @@ func TestMain(m *testing.M) {
 	}
 
 	p = core.NewWithConfig(config)
-	if err := core.Start(p); err != nil {
+	if _, err := core.Start(p); err != nil {
 		log.Error(goof.WithError("problem starting polly", err))
 		os.Exit(1)
 	}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/polly/core/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	exporterKey = "polly.tracing.exporter"
+	endpointKey = "polly.tracing.endpoint"
+	insecureKey = "polly.tracing.insecure"
+
+	// instrumentation is the name of the tracer of the Polly spans
+	instrumentation = "github.com/emccode/polly"
+)
+
+func init() {
+	gofig.Register(configRegistration())
+
+	// the trace context is propagated even when the daemon does not export
+	// spans, so a traced caller is not cut off from libStorage
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Start installs the tracer provider of the configured exporter. No spans are
+// recorded when no exporter is configured. The returned function flushes the
+// recorded spans and stops the exporter.
+func Start(config gofig.Config) (func(context.Context) error, error) {
+	var opt sdktrace.TracerProviderOption
+	switch name := config.GetString(exporterKey); name {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(config.GetString(endpointKey)),
+		}
+		if config.GetBool(insecureKey) {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, goof.WithFieldE("endpoint", config.GetString(endpointKey),
+				"cannot create otlp trace exporter", err)
+		}
+		opt = sdktrace.WithBatcher(exp)
+	case "stdout":
+		exp, err := stdouttrace.New()
+		if err != nil {
+			return nil, goof.WithError("cannot create stdout trace exporter", err)
+		}
+		opt = sdktrace.WithSyncer(exp)
+	default:
+		return nil, goof.WithField("exporter", name, "unknown trace exporter")
+	}
+
+	tp := sdktrace.NewTracerProvider(opt, sdktrace.WithResource(
+		resource.NewSchemaless(
+			attribute.String("service.name", "polly"),
+			attribute.String("service.version", version.VersionStr))))
+	otel.SetTracerProvider(tp)
+
+	log.WithFields(log.Fields{
+		"exporter": config.GetString(exporterKey),
+		"endpoint": config.GetString(endpointKey),
+	}).Info("tracing enabled")
+	return tp.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// StartSpan starts a span, a child of the span of ctx if any
+func StartSpan(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, marking it as failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// EndStatus ends the span of an HTTP request, marking it as failed on a
+// server error
+func EndStatus(span trace.Span, status int) {
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// Record records the span of an operation that started at start and just
+// completed
+func Record(
+	ctx context.Context,
+	name string,
+	start time.Time,
+	err error,
+	attrs ...attribute.KeyValue) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracer().Start(ctx, name,
+		trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	End(span, err)
+}
+
+// Extract returns a context carrying the trace context sent by the caller of
+// a request
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// TraceParent returns the traceparent header value of the span of ctx, empty
+// when the span is not recorded
+func TraceParent(ctx context.Context) string {
+	c := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, c)
+	return c.Get("traceparent")
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Tracing")
+	r.Key(gofig.String, "", "",
+		"The exporter of the trace spans, otlp or stdout, none disables tracing",
+		exporterKey)
+	r.Key(gofig.String, "", "localhost:4318",
+		"The host:port of the OTLP/HTTP collector", endpointKey)
+	r.Key(gofig.Bool, "", false,
+		"Export to the collector over plain HTTP", insecureKey)
+	return r
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func TestSpans(t *testing.T) {
+	sr := record(t)
+
+	ctx, span := StartSpan(nil, "vsc.VolumeCreate")
+	start := time.Now().Add(-time.Second)
+	Record(ctx, "store.put", start, errors.New("store unavailable"))
+	End(span, nil)
+
+	spans := sr.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	put, create := spans[0], spans[1]
+	assert.Equal(t, "store.put", put.Name())
+	assert.Equal(t, codes.Error, put.Status().Code)
+	assert.Equal(t, start.UnixNano(), put.StartTime().UnixNano())
+	assert.Equal(t, create.SpanContext().SpanID(), put.Parent().SpanID())
+	assert.Equal(t, codes.Unset, create.Status().Code)
+}
+
+func TestPropagation(t *testing.T) {
+	assert.Empty(t, TraceParent(context.Background()))
+
+	sr := record(t)
+	ctx, span := StartSpan(context.Background(), "libstorage.VolumeCreate")
+	tp := TraceParent(ctx)
+	assert.NotEmpty(t, tp)
+
+	h := http.Header{}
+	h.Set("Traceparent", tp)
+	Record(Extract(context.Background(), h), "libstorage.filter", time.Now(), nil)
+	EndStatus(span, http.StatusInternalServerError)
+
+	spans := sr.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t,
+		trace.SpanContextFromContext(ctx).TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -2,8 +2,11 @@ package types
 
 import (
 	"github.com/akutz/gofig"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/leader"
 	lsclient "github.com/emccode/polly/core/libstorage/client"
 	store "github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/webhooks"
 )
 
 // Polly this represents the "core" functionality for Polly
@@ -12,4 +15,7 @@ type Polly struct {
 	LsClient *lsclient.Client
 	Config   gofig.Config
 	LsConfig gofig.Config
+	Elector  *leader.Elector
+	Reloader *config.Reloader
+	Webhooks *webhooks.Dispatcher
 }
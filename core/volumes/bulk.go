@@ -0,0 +1,189 @@
+package volumes
+
+import (
+	"net/http"
+	"net/url"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// bulkChanges are the metadata changes of the bulk operations, remove has
+// none as it deletes the volume
+var bulkChanges = map[string]func(req *types.VolumeBulkRequest) func(vol *types.Volume){
+	types.BulkOffer: func(req *types.VolumeBulkRequest) func(vol *types.Volume) {
+		return func(vol *types.Volume) { offer(vol, req.Schedulers) }
+	},
+	types.BulkRevoke: func(req *types.VolumeBulkRequest) func(vol *types.Volume) {
+		return func(vol *types.Volume) { revoke(vol, req.Schedulers) }
+	},
+	types.BulkLabel: func(req *types.VolumeBulkRequest) func(vol *types.Volume) {
+		return func(vol *types.Volume) { label(vol, req.Labels) }
+	},
+	types.BulkUnlabel: func(req *types.VolumeBulkRequest) func(vol *types.Volume) {
+		return func(vol *types.Volume) { unlabel(vol, req.LabelKeys) }
+	},
+	types.BulkRemove: nil,
+}
+
+// Bulk applies an operation to the volumes listed by ID or matching the
+// filter of the request. Every selected volume is attempted and its outcome
+// reported, a failure does not stop the operation on the others.
+func (v *Vsc) Bulk(
+	req *types.VolumeBulkRequest) (res *types.VolumeBulkResponse, err error) {
+
+	v, span := v.span("Bulk", attribute.String("operation", req.Operation),
+		attribute.Bool("dryRun", req.DryRun))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateBulk(req); err != nil {
+		return nil, err
+	}
+
+	targets, err := v.bulkTargets(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var change func(vol *types.Volume)
+	if c := bulkChanges[req.Operation]; c != nil {
+		change = c(req)
+	}
+
+	res = &types.VolumeBulkResponse{
+		Operation: req.Operation,
+		DryRun:    req.DryRun,
+		Results:   []*types.VolumeBulkResult{},
+	}
+	for _, t := range targets {
+		r := &types.VolumeBulkResult{VolumeID: t.VolumeID}
+		vol, err := v.bulkApply(req, t, change)
+		if err != nil {
+			r.Error = types.AsError(err)
+			res.Failed++
+		} else {
+			r.Volume = vol
+			res.Succeeded++
+		}
+		res.Results = append(res.Results, r)
+	}
+
+	v.logger().WithFields(log.Fields{
+		"operation": req.Operation,
+		"dryRun":    req.DryRun,
+		"succeeded": res.Succeeded,
+		"failed":    res.Failed,
+	}).Info("bulk volume operation")
+	return res, nil
+}
+
+// validateBulk checks the operation and the mandatory elements of a bulk
+// request, dropping empty schedulers and label keys
+func validateBulk(req *types.VolumeBulkRequest) error {
+	if _, ok := bulkChanges[req.Operation]; !ok {
+		return types.NewErrorf(http.StatusBadRequest,
+			"unknown bulk operation %q", req.Operation).
+			WithDetail("operation", req.Operation)
+	}
+
+	req.VolumeIDs = NonEmpty(req.VolumeIDs)
+	req.Schedulers = NonEmpty(req.Schedulers)
+	req.LabelKeys = NonEmpty(req.LabelKeys)
+
+	switch {
+	case len(req.VolumeIDs) == 0 && req.Filter == "":
+		return types.NewError(422, "mandatory volumeIDs or filter missing or empty")
+	case len(req.VolumeIDs) > 0 && req.Filter != "":
+		return types.NewError(http.StatusBadRequest,
+			"volumeIDs and filter are mutually exclusive")
+	}
+
+	switch req.Operation {
+	case types.BulkOffer, types.BulkRevoke:
+		if len(req.Schedulers) == 0 {
+			return types.NewError(422, "mandatory schedulers missing or empty")
+		}
+	case types.BulkLabel:
+		if len(req.Labels) == 0 {
+			return types.NewError(422, "mandatory labels missing or empty")
+		}
+	case types.BulkUnlabel:
+		if len(req.LabelKeys) == 0 {
+			return types.NewError(422, "mandatory labelKeys missing or empty")
+		}
+	}
+	return nil
+}
+
+// bulkTargets returns the volumes selected by a bulk request. Volumes listed
+// by ID are only looked up when the operation is applied, those matching a
+// filter are selected among all the volumes reported by libStorage.
+func (v *Vsc) bulkTargets(req *types.VolumeBulkRequest) ([]*types.Volume, error) {
+	if req.Filter != "" {
+		vols, err := v.VolumesAll(url.Values{
+			filterParam: {req.Filter},
+			freshParam:  {"true"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return vols, nil
+	}
+
+	var targets []*types.Volume
+	seen := make(map[string]bool, len(req.VolumeIDs))
+	for _, id := range req.VolumeIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		targets = append(targets, &types.Volume{VolumeID: id})
+	}
+	return targets, nil
+}
+
+// bulkApply applies the operation to a single volume. A dry run returns the
+// volume as the operation would leave it, or as it is when it would be
+// removed, and is rejected like the operation would be.
+func (v *Vsc) bulkApply(
+	req *types.VolumeBulkRequest,
+	t *types.Volume,
+	change func(vol *types.Volume)) (*types.Volume, error) {
+
+	if !req.DryRun {
+		if change == nil {
+			return nil, v.VolumeRemove(t.VolumeID)
+		}
+		return v.update(t.VolumeID, change)
+	}
+
+	vol := t
+	if vol.Volume == nil {
+		var err error
+		if vol, err = v.VolumeInspect(t.VolumeID); err != nil {
+			return nil, err
+		}
+	}
+	if change != nil {
+		before := snapshot(vol)
+		change(vol)
+		if err := v.validate(vol, before); err != nil {
+			return nil, err
+		}
+	}
+	return vol, nil
+}
+
+// NonEmpty drops the empty names of a list, such as those of a list flag
+// left unset
+func NonEmpty(s []string) []string {
+	var r []string
+	for _, e := range s {
+		if e != "" {
+			r = append(r, e)
+		}
+	}
+	return r
+}
@@ -0,0 +1,105 @@
+package volumes
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/store"
+	ptypes "github.com/emccode/polly/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestVsc returns a volume service backed by a scratch boltdb store
+func newTestVsc(t *testing.T) *Vsc {
+	cfg, err := config.NewWithConfig(`
+polly:
+  store:
+    type: boltdb
+    endpoints: ` + filepath.Join(t.TempDir(), "polly.db") + `
+    bucket: polly_test
+`)
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	ps, err := store.NewWithConfig(cfg.Scope("polly.store"))
+	assert.NoError(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	t.Cleanup(ps.Close)
+	return New(&ptypes.Polly{Config: cfg, Store: ps})
+}
+
+func TestValidateBulk(t *testing.T) {
+	for _, req := range []*types.VolumeBulkRequest{
+		{Operation: "snapshot", VolumeIDs: []string{"mock-vol-1"}},
+		{Operation: types.BulkOffer, Schedulers: []string{"mesos"}},
+		{Operation: types.BulkOffer, VolumeIDs: []string{""}, Schedulers: []string{"mesos"}},
+		{Operation: types.BulkOffer, VolumeIDs: []string{"mock-vol-1"}, Filter: "size>1"},
+		{Operation: types.BulkRevoke, VolumeIDs: []string{"mock-vol-1"}, Schedulers: []string{""}},
+		{Operation: types.BulkLabel, Filter: "size>1"},
+		{Operation: types.BulkUnlabel, Filter: "size>1"},
+	} {
+		assert.Error(t, validateBulk(req), req.Operation)
+	}
+
+	assert.NoError(t, validateBulk(&types.VolumeBulkRequest{
+		Operation: types.BulkRemove, Filter: "labels.tier=scratch"}))
+}
+
+func TestBulkApplyDryRun(t *testing.T) {
+	v := newTestVsc(t)
+	req := &types.VolumeBulkRequest{
+		Operation:  types.BulkOffer,
+		Schedulers: []string{"mesos1"},
+		DryRun:     true,
+	}
+	vol := newPageVolume("mock-vol-1", 10)
+	vol.Schedulers = []string{"mesos0"}
+
+	res, err := v.bulkApply(req, vol, bulkChanges[req.Operation](req))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mesos1"}, res.Schedulers)
+
+	req = &types.VolumeBulkRequest{
+		Operation: types.BulkLabel,
+		Labels:    map[string]string{"tier": "gold"},
+		DryRun:    true,
+	}
+	res, err = v.bulkApply(req, vol, bulkChanges[req.Operation](req))
+	assert.NoError(t, err)
+	assert.Equal(t, "gold", res.Labels["tier"])
+
+	res, err = v.bulkApply(
+		&types.VolumeBulkRequest{Operation: types.BulkRemove, DryRun: true}, vol, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-vol-1", res.VolumeID)
+}
+
+func TestBulkApplyDryRunChecks(t *testing.T) {
+	v := newTestVsc(t)
+	assert.NoError(t, v.store().SaveTenant(
+		&types.Tenant{Name: "blue", Schedulers: []string{"mesos1"}}))
+	vol := newPageVolume("mock-vol-1", 10)
+
+	// a dry run is rejected like the operation would be
+	req := &types.VolumeBulkRequest{
+		Operation:  types.BulkOffer,
+		Schedulers: []string{"mesos1"},
+		DryRun:     true,
+	}
+	_, err := v.bulkApply(req, vol, bulkChanges[req.Operation](req))
+	assert.Equal(t, http.StatusForbidden, types.ErrorStatus(err))
+
+	req = &types.VolumeBulkRequest{
+		Operation: types.BulkLabel,
+		Labels:    map[string]string{"osb.planID": "gold"},
+		DryRun:    true,
+	}
+	_, err = v.bulkApply(req, vol, bulkChanges[req.Operation](req))
+	assert.Equal(t, http.StatusForbidden, types.ErrorStatus(err))
+}
@@ -0,0 +1,228 @@
+package volumes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+)
+
+const (
+	cacheIntervalKey = "polly.volumes.cache.interval"
+
+	// freshParam forces a listing to query libStorage
+	freshParam = "fresh"
+)
+
+func init() {
+	gofig.Register(cacheRegistration())
+}
+
+// inventory caches the volumes reported by libStorage so listings do not
+// query every backend. The cache is refreshed in the background on an
+// interval and invalidated by the mutations Polly performs.
+type inventory struct {
+	list     func(ctx context.Context) ([]*types.Volume, error)
+	interval time.Duration
+
+	// mu guards the snapshot and the fetch in flight, it is not held while
+	// libStorage is queried
+	mu      sync.Mutex
+	vols    []*types.Volume
+	fetched time.Time
+	valid   bool
+
+	// gen counts the invalidations, snapGen is the generation of the
+	// snapshot and call the fetch in flight, if any
+	gen     uint64
+	snapGen uint64
+	call    *fetchCall
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// fetchCall is a libStorage listing in flight, shared by the listings that
+// wait for it
+type fetchCall struct {
+	gen     uint64
+	done    chan struct{}
+	vols    []*types.Volume
+	fetched time.Time
+	err     error
+}
+
+func newInventory(
+	cfg gofig.Config, list func(ctx context.Context) ([]*types.Volume, error)) *inventory {
+
+	i := &inventory{
+		list: list,
+		stop: make(chan struct{}),
+	}
+	if cfg != nil {
+		i.interval = config.Duration(cfg, cacheIntervalKey, 0)
+	}
+	return i
+}
+
+// enabled returns whether the inventory is cached
+func (i *inventory) enabled() bool {
+	return i.interval > 0
+}
+
+// volumes returns copies of the inventory and the time it was fetched from
+// libStorage. The inventory is fetched when fresh is set, when the cache is
+// disabled or when it has been invalidated, the listings joining the fetch
+// in flight if any. A listing stops waiting for the fetch when its context
+// is done.
+func (i *inventory) volumes(
+	ctx context.Context, fresh bool) ([]*types.Volume, time.Time, error) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	i.mu.Lock()
+	if !fresh && i.enabled() && i.valid {
+		vols, fetched := copies(i.vols), i.fetched
+		i.mu.Unlock()
+		return vols, fetched, nil
+	}
+	c := i.begin(ctx)
+	i.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+	if c.err != nil {
+		return nil, time.Time{}, c.err
+	}
+	return copies(c.vols), c.fetched, nil
+}
+
+// begin returns the fetch in flight since the last invalidation, starting
+// one when there is none. The fetch carries the values of the context of
+// the listing starting it but is not cancelled along with it, as other
+// listings may wait for it. The lock must be held.
+func (i *inventory) begin(ctx context.Context) *fetchCall {
+	if i.call != nil && i.call.gen == i.gen {
+		return i.call
+	}
+	c := &fetchCall{gen: i.gen, done: make(chan struct{})}
+	i.call = c
+	go i.fetch(context.WithoutCancel(ctx), c)
+	return c
+}
+
+// fetch lists the volumes from libStorage and swaps the snapshot unless a
+// fetch started later already did
+func (i *inventory) fetch(ctx context.Context, c *fetchCall) {
+	vols, err := i.list(ctx)
+
+	i.mu.Lock()
+	c.vols, c.err, c.fetched = vols, err, time.Now().UTC()
+	if err == nil && c.gen >= i.snapGen {
+		i.vols, i.fetched, i.snapGen = vols, c.fetched, c.gen
+		i.valid = c.gen == i.gen
+	}
+	if i.call == c {
+		i.call = nil
+	}
+	i.mu.Unlock()
+	close(c.done)
+}
+
+// invalidate makes the next listing query libStorage
+func (i *inventory) invalidate() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.valid = false
+	i.gen++
+}
+
+// start refreshes the inventory on the interval until stopped
+func (i *inventory) start() {
+	if !i.enabled() {
+		return
+	}
+	go func() {
+		t := time.NewTicker(i.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				i.mu.Lock()
+				c := i.begin(context.Background())
+				i.mu.Unlock()
+				select {
+				case <-c.done:
+					if c.err != nil {
+						log.WithError(c.err).Warn("problem refreshing volume inventory")
+					}
+				case <-i.stop:
+					return
+				}
+			case <-i.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (i *inventory) close() {
+	i.once.Do(func() { close(i.stop) })
+}
+
+// copies clones volumes
+func copies(vols []*types.Volume) []*types.Volume {
+	c := make([]*types.Volume, len(vols))
+	for n, v := range vols {
+		c[n] = clone(v)
+	}
+	return c
+}
+
+// clone copies a volume so its metadata can be set without altering the
+// cached inventory
+func clone(v *types.Volume) *types.Volume {
+	c := *v
+	if v.Volume != nil {
+		lv := *v.Volume
+		if v.Fields != nil {
+			lv.Fields = make(map[string]string, len(v.Fields))
+			for k, f := range v.Fields {
+				lv.Fields[k] = f
+			}
+		}
+		if v.Attachments != nil {
+			lv.Attachments = append(
+				[]*lstypes.VolumeAttachment(nil), v.Attachments...)
+		}
+		c.Volume = &lv
+	}
+	if v.Schedulers != nil {
+		c.Schedulers = append([]string(nil), v.Schedulers...)
+	}
+	if v.Labels != nil {
+		c.Labels = make(map[string]string, len(v.Labels))
+		for k, l := range v.Labels {
+			c.Labels[k] = l
+		}
+	}
+	return &c
+}
+
+func cacheRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Volume Cache")
+	r.Key(gofig.String, "", "30s",
+		"The time between two refreshes of the volume inventory, 0 disables the cache",
+		cacheIntervalKey)
+	return r
+}
@@ -0,0 +1,108 @@
+package volumes
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	var calls int
+	inv := &inventory{
+		interval: time.Minute,
+		list: func(context.Context) ([]*types.Volume, error) {
+			calls++
+			return []*types.Volume{newPageVolume("mock-vol-1", 10)}, nil
+		},
+		stop: make(chan struct{}),
+	}
+
+	vols, fetched, err := inv.volumes(nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.False(t, fetched.IsZero())
+
+	// listings get copies of the cached volumes
+	vols[0].Labels = map[string]string{"tier": "gold"}
+	vols[0].Size = 20
+
+	vols, _, err = inv.volumes(nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, vols[0].Labels)
+	assert.Equal(t, int64(10), vols[0].Size)
+
+	_, _, err = inv.volumes(nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	inv.invalidate()
+	_, _, err = inv.volumes(nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	inv.interval = 0
+	_, _, err = inv.volumes(nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestInventoryFetchOnce(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	inv := &inventory{
+		interval: time.Minute,
+		list: func(context.Context) ([]*types.Volume, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			<-release
+			return []*types.Volume{newPageVolume("mock-vol-1", 10)}, nil
+		},
+		stop: make(chan struct{}),
+	}
+
+	// a listing whose context is done stops waiting for the fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := inv.volumes(ctx, false)
+	assert.Equal(t, context.Canceled, err)
+
+	// the listings waiting meanwhile share the fetch in flight
+	var wg sync.WaitGroup
+	for n := 0; n < 3; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vols, _, err := inv.volumes(context.Background(), false)
+			assert.NoError(t, err)
+			assert.Len(t, vols, 1)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+
+	// an invalidation during a fetch makes the next listing fetch again
+	release = make(chan struct{})
+	inv.invalidate()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inv.volumes(context.Background(), false)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	inv.invalidate()
+	close(release)
+	<-done
+	assert.False(t, inv.valid)
+	_, _, err = inv.volumes(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
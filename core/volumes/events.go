@@ -0,0 +1,103 @@
+package volumes
+
+import (
+	"reflect"
+
+	"github.com/emccode/polly/api/types"
+)
+
+// notify sends a volume lifecycle event to the webhooks
+func (v *Vsc) notify(typ, volumeID string, vol *types.Volume) {
+	e := &types.Event{
+		Type:      typ,
+		VolumeID:  volumeID,
+		RequestID: v.requestID,
+	}
+	if vol != nil {
+		e.Volume = snapshot(vol)
+	}
+	v.p.Webhooks.Notify(e)
+}
+
+// Created sends the create event of a volume created by a libStorage client
+func (v *Vsc) Created(vol *types.Volume) {
+	v.notify(types.EventVolumeCreate, vol.VolumeID, vol)
+}
+
+// notifyLease sends a lease or release event to the webhooks
+func (v *Vsc) notifyLease(typ string, lease *types.Lease) {
+	l := *lease
+	v.p.Webhooks.Notify(&types.Event{
+		Type:      typ,
+		VolumeID:  lease.VolumeID,
+		Lease:     &l,
+		RequestID: v.requestID,
+	})
+}
+
+// notifyUpdate sends the offer, revoke and label events of a metadata
+// update given the schedulers and labels of the volume before the update
+func (v *Vsc) notifyUpdate(
+	vol *types.Volume, schedulers []string, labels map[string]string) {
+
+	for _, c := range []struct {
+		typ        string
+		schedulers []string
+	}{
+		{types.EventVolumeOffer, missing(vol.Schedulers, schedulers)},
+		{types.EventVolumeRevoke, missing(schedulers, vol.Schedulers)},
+	} {
+		if len(c.schedulers) == 0 {
+			continue
+		}
+		v.p.Webhooks.Notify(&types.Event{
+			Type:       c.typ,
+			VolumeID:   vol.VolumeID,
+			Volume:     snapshot(vol),
+			Schedulers: c.schedulers,
+			RequestID:  v.requestID,
+		})
+	}
+
+	if !sameLabels(labels, vol.Labels) {
+		v.notify(types.EventVolumeLabel, vol.VolumeID, vol)
+	}
+}
+
+// sameLabels returns whether two label sets are equal, empty and missing
+// labels being the same once stored
+func sameLabels(a, b map[string]string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// missing returns the elements of s missing from from
+func missing(s, from []string) []string {
+	var r []string
+	for _, e := range s {
+		if !contains(from, e) {
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+// snapshot returns a copy of the metadata of a volume, as the volume may
+// change before the event is delivered
+func snapshot(vol *types.Volume) *types.Volume {
+	s := *vol
+	s.Schedulers = append([]string(nil), vol.Schedulers...)
+	s.Labels = copyMap(vol.Labels)
+	s.Fields = copyMap(vol.Fields)
+	return &s
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
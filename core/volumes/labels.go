@@ -0,0 +1,277 @@
+package volumes
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+)
+
+const (
+	labelsStrictKey = "polly.labels.strict"
+	labelsSchemaKey = "polly.labels.schema"
+)
+
+// ReservedLabelPrefix prefixes the labels the service broker keeps the state
+// of its instances and bindings in. They are exempt from the schema and only
+// the copy of the service returned by WithReservedLabels may change them.
+const ReservedLabelPrefix = "osb."
+
+func init() {
+	gofig.Register(labelsRegistration())
+}
+
+// LabelRule constrains an admin label, on the volumes of the listed services
+// or on every volume when none is listed
+type LabelRule struct {
+	Key      string   `json:"key"`
+	Services []string `json:"services,omitempty"`
+
+	// Required labels must be set on create and kept by updates
+	Required bool `json:"required,omitempty"`
+
+	// Pattern is a regular expression the whole value must match
+	Pattern string `json:"pattern,omitempty"`
+
+	// Values enumerates the allowed values
+	Values []string `json:"values,omitempty"`
+
+	// Immutable labels cannot be changed or removed once set
+	Immutable bool `json:"immutable,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// applies returns whether the rule constrains the volumes of a service
+func (r *LabelRule) applies(service string) bool {
+	return len(r.Services) == 0 || contains(r.Services, service)
+}
+
+// labelSchema holds the rules of the admin labels. Labels without a rule are
+// rejected when the schema is strict.
+type labelSchema struct {
+	strict bool
+	rules  []*LabelRule
+}
+
+// labelSchemas holds the current schema, it is shared by the copies of the
+// service and replaced when the config is reloaded
+type labelSchemas struct {
+	mu     sync.RWMutex
+	schema *labelSchema
+}
+
+func (l *labelSchemas) get() *labelSchema {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.schema
+}
+
+func (l *labelSchemas) set(s *labelSchema) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schema = s
+}
+
+// LoadLabelSchema reads the label schema from the config, leaving the
+// current schema in place when it is invalid
+func (v *Vsc) LoadLabelSchema(config gofig.Config) error {
+	s, err := newLabelSchema(config)
+	if err != nil {
+		return err
+	}
+	v.labels.set(s)
+	log.WithFields(log.Fields{
+		"rules":  len(s.rules),
+		"strict": s.strict,
+	}).Debug("loaded label schema")
+	return nil
+}
+
+func newLabelSchema(cfg gofig.Config) (*labelSchema, error) {
+	s := &labelSchema{strict: cfg.GetBool(labelsStrictKey)}
+	if err := config.Decode(cfg, labelsSchemaKey, &s.rules); err != nil {
+		return nil, err
+	}
+	for _, r := range s.rules {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// compile checks a rule and compiles its pattern
+func (r *LabelRule) compile() error {
+	if r.Key == "" {
+		return goof.New("label rule without key")
+	}
+	if reservedLabel(r.Key) {
+		return goof.WithField("key", r.Key, "label rule on a reserved label")
+	}
+	if r.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+	if err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"key":     r.Key,
+			"pattern": r.Pattern,
+		}, "invalid label pattern", err)
+	}
+	r.re = re
+	return nil
+}
+
+// checkLabels validates the labels of a volume of a service against the
+// schema, given the labels before the change, nil for a new volume. Every
+// violation is reported in a single 422.
+func (v *Vsc) checkLabels(service string, before, labels map[string]string) error {
+	if err := v.checkReserved(before, labels); err != nil {
+		return err
+	}
+	s := v.labels.get()
+	if s == nil {
+		return nil
+	}
+
+	violations := s.check(service, before, labels)
+	if len(violations) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(violations))
+	for k := range violations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = fmt.Sprintf("label %q %s", k, violations[k])
+	}
+	return types.NewError(422, strings.Join(msgs, ", ")).
+		WithDetail("service", service).
+		WithDetail("labels", violations)
+}
+
+// WithReservedLabels returns a copy of the service allowed to change the labels
+// prefixed by ReservedLabelPrefix
+func (v *Vsc) WithReservedLabels() *Vsc {
+	rv := *v
+	rv.reserved = true
+	return &rv
+}
+
+func reservedLabel(key string) bool {
+	return strings.HasPrefix(key, ReservedLabelPrefix)
+}
+
+// checkReserved rejects with a 403 a change of the reserved labels, unless
+// the service is allowed to change them
+func (v *Vsc) checkReserved(before, labels map[string]string) error {
+	if v.reserved {
+		return nil
+	}
+	var keys []string
+	for k, val := range labels {
+		if old, ok := before[k]; reservedLabel(k) && (!ok || old != val) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range before {
+		if _, ok := labels[k]; reservedLabel(k) && !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return types.NewErrorf(http.StatusForbidden,
+		"labels %s are reserved for the service broker", strings.Join(keys, ", ")).
+		WithDetail("labels", keys)
+}
+
+// check returns the reason each violating label is rejected for, keyed by
+// label. Only the changed values are checked against the patterns and
+// enumerations, so labels set before a rule was added can be kept.
+func (s *labelSchema) check(service string, before, labels map[string]string) map[string]string {
+	violations := make(map[string]string)
+
+	for k, val := range labels {
+		if old, ok := before[k]; ok && old == val || reservedLabel(k) {
+			continue
+		}
+		rules := s.rulesFor(service, k)
+		if len(rules) == 0 && s.strict {
+			violations[k] = "is not allowed"
+			continue
+		}
+		for _, r := range rules {
+			if reason := r.violation(before, k, val); reason != "" {
+				violations[k] = reason
+				break
+			}
+		}
+	}
+
+	for _, r := range s.rules {
+		if !r.applies(service) || violations[r.Key] != "" {
+			continue
+		}
+		if _, ok := labels[r.Key]; ok {
+			continue
+		}
+		if _, ok := before[r.Key]; ok && r.Immutable {
+			violations[r.Key] = "is immutable"
+		} else if r.Required {
+			violations[r.Key] = "is required"
+		}
+	}
+	return violations
+}
+
+// violation returns why a rule rejects a changed value, empty when it does
+// not
+func (r *LabelRule) violation(before map[string]string, k, val string) string {
+	if _, ok := before[k]; ok && r.Immutable {
+		return "is immutable"
+	}
+	if r.re != nil && !r.re.MatchString(val) {
+		return fmt.Sprintf("value %q does not match %q", val, r.Pattern)
+	}
+	if len(r.Values) > 0 && !contains(r.Values, val) {
+		return fmt.Sprintf("value %q is not one of %s", val,
+			strings.Join(r.Values, ", "))
+	}
+	return ""
+}
+
+// rulesFor returns the rules of a label on the volumes of a service
+func (s *labelSchema) rulesFor(service, key string) []*LabelRule {
+	var rules []*LabelRule
+	for _, r := range s.rules {
+		if r.Key == key && r.applies(service) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func labelsRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Labels")
+	r.Key(gofig.Bool, "", false,
+		"Reject the admin labels without a rule in the label schema",
+		labelsStrictKey)
+	return r
+}
@@ -0,0 +1,104 @@
+package volumes
+
+import (
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLabelSchema(t *testing.T, strict bool, rules ...*LabelRule) *Vsc {
+	for _, r := range rules {
+		assert.NoError(t, r.compile())
+	}
+	v := &Vsc{labels: &labelSchemas{}}
+	v.labels.set(&labelSchema{strict: strict, rules: rules})
+	return v
+}
+
+func TestCheckLabels(t *testing.T) {
+	v := newTestLabelSchema(t, false,
+		&LabelRule{Key: "cost-center", Required: true, Pattern: "cc-[0-9]{4}",
+			Immutable: true},
+		&LabelRule{Key: "tier", Values: []string{"gold", "silver"}},
+		&LabelRule{Key: "backup", Services: []string{"ebs"}, Required: true},
+	)
+
+	assert.NoError(t, v.checkLabels("mock", nil,
+		map[string]string{"cost-center": "cc-1234", "tier": "gold", "owner": "db"}))
+
+	err := v.checkLabels("ebs", nil,
+		map[string]string{"cost-center": "cc-12345", "tier": "bronze"})
+	assert.Equal(t, 422, types.ErrorStatus(err))
+	assert.Equal(t, map[string]string{
+		"backup":      "is required",
+		"cost-center": `value "cc-12345" does not match "cc-[0-9]{4}"`,
+		"tier":        `value "bronze" is not one of gold, silver`,
+	}, types.AsError(err).Details["labels"])
+
+	before := map[string]string{"cost-center": "cc-1234", "tier": "bronze"}
+
+	// unchanged values are not checked again
+	assert.NoError(t, v.checkLabels("mock", before,
+		map[string]string{"cost-center": "cc-1234", "tier": "bronze", "zone": "a"}))
+
+	err = v.checkLabels("mock", before, map[string]string{"cost-center": "cc-4321"})
+	assert.Equal(t, map[string]string{"cost-center": "is immutable"},
+		types.AsError(err).Details["labels"])
+	err = v.checkLabels("mock", before, map[string]string{"tier": "gold"})
+	assert.Equal(t, map[string]string{"cost-center": "is immutable"},
+		types.AsError(err).Details["labels"])
+
+	// a volume missing a required label has to be labeled on its next change
+	err = v.checkLabels("mock", map[string]string{}, map[string]string{"tier": "gold"})
+	assert.Equal(t, map[string]string{"cost-center": "is required"},
+		types.AsError(err).Details["labels"])
+}
+
+func TestCheckLabelsStrict(t *testing.T) {
+	v := newTestLabelSchema(t, true,
+		&LabelRule{Key: "tier"},
+		&LabelRule{Key: "backup", Services: []string{"ebs"}},
+	)
+
+	assert.NoError(t, v.checkLabels("ebs", nil,
+		map[string]string{"tier": "gold", "backup": "daily"}))
+	err := v.checkLabels("mock", nil,
+		map[string]string{"tier": "gold", "backup": "daily"})
+	assert.Equal(t, `label "backup" is not allowed`, types.AsError(err).Message)
+
+	none := &Vsc{}
+	assert.NoError(t, none.checkLabels("mock", nil, map[string]string{"any": "value"}))
+}
+
+func TestLabelRuleCompile(t *testing.T) {
+	assert.Error(t, (&LabelRule{Pattern: "a"}).compile())
+	assert.Error(t, (&LabelRule{Key: "a", Pattern: "("}).compile())
+
+	r := &LabelRule{Key: "a", Pattern: "x|y"}
+	assert.NoError(t, r.compile())
+	assert.True(t, r.re.MatchString("y"))
+	assert.False(t, r.re.MatchString("xy"))
+}
+
+func TestCheckLabelsReserved(t *testing.T) {
+	v := newTestLabelSchema(t, true, &LabelRule{Key: "tier"})
+	before := map[string]string{"tier": "gold", "osb.instanceID": "i-1"}
+
+	err := v.checkLabels("mock", before, map[string]string{"tier": "silver"})
+	assert.Equal(t, 403, types.ErrorStatus(err))
+	assert.Equal(t, []string{"osb.instanceID"}, types.AsError(err).Details["labels"])
+
+	err = v.checkLabels("mock", nil, map[string]string{"osb.planID": "gold"})
+	assert.Equal(t, 403, types.ErrorStatus(err))
+
+	assert.NoError(t, v.checkLabels("mock", before,
+		map[string]string{"tier": "silver", "osb.instanceID": "i-1"}))
+
+	// the broker is exempt from the schema, strict as it is
+	broker := v.WithReservedLabels()
+	assert.NoError(t, broker.checkLabels("mock", before,
+		map[string]string{"tier": "silver", "osb.binding.b-1": "app"}))
+
+	assert.Error(t, (&LabelRule{Key: "osb.planID"}).compile())
+}
@@ -0,0 +1,434 @@
+package volumes
+
+import (
+	"encoding/base64"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	kvstore "github.com/docker/libkv/store"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	leaseTTLKey      = "polly.mesos.leaseTTL"
+	refuseSecondsKey = "polly.mesos.refuseSeconds"
+	reapIntervalKey  = "polly.mesos.reapInterval"
+	offerIntervalKey = "polly.mesos.offerInterval"
+)
+
+func init() {
+	gofig.Register(leasesRegistration())
+}
+
+// Offer is a volume offered to a Mesos framework
+type Offer struct {
+	ID     string
+	Volume *types.Volume
+
+	// Lease is the lease of the framework on the volume, nil when the
+	// framework does not hold it
+	Lease *types.Lease
+}
+
+// OfferID returns the ID of the offer of a volume. It changes with the
+// metadata of the volume so an offer made before a change cannot be
+// accepted.
+func OfferID(vol *types.Volume) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(vol.VolumeID + "|" + strings.Trim(ETag(vol), `"`)))
+}
+
+// parseOfferID returns the volume ID and the entity tag of an offer ID
+func parseOfferID(offerID string) (string, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(offerID)
+	i := strings.LastIndex(string(b), "|")
+	if err != nil || i <= 0 {
+		return "", "", types.NewErrorf(http.StatusBadRequest,
+			"invalid offer ID %q", offerID).WithDetail("offerID", offerID)
+	}
+	return string(b[:i]), `"` + string(b[i+1:]) + `"`, nil
+}
+
+// OfferInterval returns the configured time between two updates of an offer
+// feed
+func OfferInterval(cfg gofig.Config) time.Duration {
+	return config.Duration(cfg, offerIntervalKey, 5*time.Second)
+}
+
+// ReapInterval returns the configured time between two passes of the lease
+// reaper
+func ReapInterval(cfg gofig.Config) time.Duration {
+	return config.Duration(cfg, reapIntervalKey, time.Minute)
+}
+
+// Offers returns the offers of the volumes offered to a scheduler that a
+// framework may accept, those neither leased to another framework nor
+// recently declined by it. A suspended scheduler gets no offers.
+func (v *Vsc) Offers(scheduler, frameworkID string) (offers []*Offer, err error) {
+	v, span := v.span("Offers", attribute.String("scheduler", scheduler))
+	defer func() { tracing.End(span, err) }()
+
+	if err := v.checkScheduler(scheduler); err != nil {
+		return nil, err
+	}
+	if err := v.Suspended(scheduler); err != nil {
+		// the feeds of a suspended scheduler rescind its offers
+		v.logger().WithError(err).Debug("withholding offers")
+		return nil, nil
+	}
+	vols, err := v.Volumes(url.Values{SchedulersParam: {scheduler}})
+	if err != nil {
+		return nil, err
+	}
+	states, err := v.store().VolumeOfferStates()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for _, vol := range vols {
+		o := &Offer{ID: OfferID(vol), Volume: vol}
+		if state, ok := states[vol.VolumeID]; ok {
+			if leasedToOther(state, frameworkID, now) || declined(state, frameworkID, now) {
+				continue
+			}
+			if state.Lease != nil && state.Lease.FrameworkID == frameworkID {
+				o.Lease = state.Lease
+			}
+		}
+		offers = append(offers, o)
+	}
+	return offers, nil
+}
+
+// OfferAccept leases the volume of an offer to a framework, or renews the
+// lease it holds. An offer made before the metadata of the volume changed
+// is rescinded and cannot be accepted.
+func (v *Vsc) OfferAccept(
+	offerID string, req *types.OfferAcceptRequest) (lease *types.Lease, err error) {
+
+	v, span := v.span("OfferAccept", attribute.String("offer.id", offerID))
+	defer func() { tracing.End(span, err) }()
+
+	if req.Scheduler == "" || req.FrameworkID == "" {
+		return nil, types.NewError(http.StatusBadRequest,
+			"scheduler and frameworkID are required")
+	}
+	volumeID, etag, err := parseOfferID(offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.checkScheduler(req.Scheduler); err != nil {
+		return nil, err
+	}
+	if err := v.Suspended(req.Scheduler); err != nil {
+		return nil, err
+	}
+
+	// the check of the lease and its save are not interleaved with those of
+	// another daemon, which would lease the volume twice
+	unlock, err := v.lockOffers()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	vol, err := v.VolumeInspect(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(vol.Schedulers, req.Scheduler) {
+		return nil, types.NewErrorf(http.StatusForbidden,
+			"volume %q is not offered to %s", volumeID, req.Scheduler).
+			WithDetail("volumeID", volumeID)
+	}
+	if ETag(vol) != etag {
+		return nil, types.NewErrorf(http.StatusConflict,
+			"offer %q is rescinded, the volume changed", offerID).
+			WithDetail("volumeID", volumeID)
+	}
+
+	state, err := v.store().GetVolumeOfferState(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if leasedToOther(state, req.FrameworkID, now) {
+		return nil, types.NewErrorf(http.StatusConflict,
+			"volume %q is leased to framework %s", volumeID, state.Lease.FrameworkID).
+			WithDetail("volumeID", volumeID)
+	}
+
+	lease = &types.Lease{
+		VolumeID:    volumeID,
+		Scheduler:   req.Scheduler,
+		FrameworkID: req.FrameworkID,
+	}
+	if ttl := config.Duration(v.p.Config, leaseTTLKey, 0); ttl > 0 {
+		lease.Expires = now + int64(ttl/time.Second)
+	}
+	state.Lease = lease
+	delete(state.Declines, req.FrameworkID)
+	if err := v.store().SaveVolumeOfferState(volumeID, state); err != nil {
+		return nil, err
+	}
+
+	v.logger().WithFields(log.Fields{
+		"volumeID":    volumeID,
+		"frameworkID": req.FrameworkID,
+		"expires":     lease.Expires,
+	}).Info("leased volume")
+	v.notifyLease(types.EventVolumeLease, lease)
+	return lease, nil
+}
+
+// OfferDecline stops offering the volume of an offer to a framework for the
+// refuse time of the request, or the configured one
+func (v *Vsc) OfferDecline(offerID string, req *types.OfferDeclineRequest) (err error) {
+	v, span := v.span("OfferDecline", attribute.String("offer.id", offerID))
+	defer func() { tracing.End(span, err) }()
+
+	if req.FrameworkID == "" {
+		return types.NewError(http.StatusBadRequest, "frameworkID is required")
+	}
+	volumeID, _, err := parseOfferID(offerID)
+	if err != nil {
+		return err
+	}
+	refuse := req.RefuseSeconds
+	if refuse <= 0 {
+		refuse = float64(v.p.Config.GetInt(refuseSecondsKey))
+	}
+
+	unlock, err := v.lockOffers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := v.checkVisible(volumeID); err != nil {
+		return err
+	}
+	state, err := v.store().GetVolumeOfferState(volumeID)
+	if err != nil {
+		return err
+	}
+	if state.Declines == nil {
+		state.Declines = make(map[string]int64)
+	}
+	state.Declines[req.FrameworkID] = time.Now().Unix() + int64(math.Ceil(refuse))
+	return v.store().SaveVolumeOfferState(volumeID, state)
+}
+
+// Leases returns the leases that have not expired ordered by volume ID, only
+// those of the schedulers of the tenant the service is restricted to if any
+func (v *Vsc) Leases() ([]*types.Lease, error) {
+	states, err := v.store().VolumeOfferStates()
+	if err != nil {
+		return nil, err
+	}
+	var schedulers []string
+	if v.tenant != "" {
+		if schedulers, err = v.tenantSchedulers(); err != nil {
+			return nil, err
+		}
+	}
+
+	leases := []*types.Lease{}
+	now := time.Now().Unix()
+	for _, state := range states {
+		if state.Lease != nil && !expired(state.Lease, now) &&
+			(v.tenant == "" || contains(schedulers, state.Lease.Scheduler)) {
+			leases = append(leases, state.Lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].VolumeID < leases[j].VolumeID
+	})
+	return leases, nil
+}
+
+// LeaseRelease ends the lease of a volume, held by frameworkID when it is
+// not empty
+func (v *Vsc) LeaseRelease(volumeID, frameworkID string) (err error) {
+	v, span := v.span("LeaseRelease", volumeAttr(volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	unlock, err := v.lockOffers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := v.checkVisible(volumeID); err != nil {
+		return err
+	}
+	state, err := v.store().GetVolumeOfferState(volumeID)
+	if err != nil {
+		return err
+	}
+	if state.Lease == nil || expired(state.Lease, time.Now().Unix()) {
+		return types.NewErrorf(http.StatusNotFound,
+			"volume %q is not leased", volumeID).WithDetail("volumeID", volumeID)
+	}
+	if frameworkID != "" && state.Lease.FrameworkID != frameworkID {
+		return types.NewErrorf(http.StatusConflict,
+			"volume %q is leased to framework %s", volumeID, state.Lease.FrameworkID).
+			WithDetail("volumeID", volumeID)
+	}
+
+	lease := state.Lease
+	state.Lease = nil
+	if err := v.store().SaveVolumeOfferState(volumeID, state); err != nil {
+		return err
+	}
+	v.logger().WithField("volumeID", volumeID).Info("released volume lease")
+	v.notifyLease(types.EventVolumeRelease, lease)
+	return nil
+}
+
+// releaseRevoked ends the lease of a volume held through a scheduler the
+// volume is no longer offered to, so the offer can be accepted through
+// another scheduler
+func (v *Vsc) releaseRevoked(vol *types.Volume) error {
+	state, err := v.store().GetVolumeOfferState(vol.VolumeID)
+	if err != nil {
+		return err
+	}
+	lease := state.Lease
+	if lease == nil || contains(vol.Schedulers, lease.Scheduler) {
+		return nil
+	}
+	state.Lease = nil
+	if err := v.store().SaveVolumeOfferState(vol.VolumeID, state); err != nil {
+		return err
+	}
+	v.logger().WithFields(log.Fields{
+		"volumeID":    vol.VolumeID,
+		"scheduler":   lease.Scheduler,
+		"frameworkID": lease.FrameworkID,
+	}).Info("released lease of revoked offer")
+	v.notifyLease(types.EventVolumeRelease, lease)
+	return nil
+}
+
+// ReapLeases removes the expired leases and declines from the store
+func (v *Vsc) ReapLeases() (err error) {
+	v, span := v.span("ReapLeases")
+	defer func() { tracing.End(span, err) }()
+
+	unlock, err := v.lockOffers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	states, err := v.store().VolumeOfferStates()
+	if err != nil {
+		return err
+	}
+
+	reaped := 0
+	now := time.Now().Unix()
+	for volumeID, state := range states {
+		lease := state.Lease
+		if !prune(state, now) {
+			continue
+		}
+		if err := v.store().SaveVolumeOfferState(volumeID, state); err != nil {
+			return err
+		}
+		if lease != nil && state.Lease == nil {
+			v.notifyLease(types.EventVolumeRelease, lease)
+		}
+		reaped++
+	}
+	if reaped > 0 {
+		v.logger().WithField("volumes", reaped).Info("reaped expired leases and declines")
+	}
+	return nil
+}
+
+// prune removes the expired lease and declines of a state and returns
+// whether it changed
+func prune(state *types.OfferState, now int64) bool {
+	changed := false
+	if state.Lease != nil && expired(state.Lease, now) {
+		state.Lease = nil
+		changed = true
+	}
+	for fw, until := range state.Declines {
+		if until <= now {
+			delete(state.Declines, fw)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func expired(lease *types.Lease, now int64) bool {
+	return lease.Expires != 0 && lease.Expires <= now
+}
+
+// leasedToOther returns whether a framework other than frameworkID holds a
+// lease on the volume
+func leasedToOther(state *types.OfferState, frameworkID string, now int64) bool {
+	return state.Lease != nil && state.Lease.FrameworkID != frameworkID &&
+		!expired(state.Lease, now)
+}
+
+// declined returns whether the framework declined the offer of the volume
+// and refuses it still
+func declined(state *types.OfferState, frameworkID string, now int64) bool {
+	until, ok := state.Declines[frameworkID]
+	return ok && until > now
+}
+
+func leasesRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Mesos Offers")
+	r.Key(gofig.String, "", "",
+		"How long a lease lasts unless the offer is accepted again, "+
+			"leases do not expire when empty", leaseTTLKey)
+	r.Key(gofig.Int, "", 5,
+		"How long a declined offer is not made again to the framework, "+
+			"in seconds", refuseSecondsKey)
+	r.Key(gofig.String, "", "1m",
+		"The time between two passes of the lease reaper", reapIntervalKey)
+	r.Key(gofig.String, "", "5s",
+		"The time between two updates of an offer feed", offerIntervalKey)
+	return r
+}
+
+// lockOffers serializes the changes of the offer states, across the daemons
+// when the store supports locking
+func (v *Vsc) lockOffers() (func(), error) {
+	v.mu.Lock()
+	l, err := v.store().NewLock(v.store().OffersKey(), nil)
+	if err == kvstore.ErrCallNotSupported {
+		return v.mu.Unlock, nil
+	}
+	if err == nil {
+		_, err = l.Lock(nil)
+	}
+	if err != nil {
+		v.mu.Unlock()
+		return nil, goof.WithError("cannot lock the offer states", err)
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			v.logger().WithError(err).Warn("cannot unlock the offer states")
+		}
+		v.mu.Unlock()
+	}, nil
+}
@@ -0,0 +1,75 @@
+package volumes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfferID(t *testing.T) {
+	vol := newPageVolume("mock-vol-1", 10)
+	id := OfferID(vol)
+	volumeID, etag, err := parseOfferID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-vol-1", volumeID)
+	assert.Equal(t, ETag(vol), etag)
+
+	vol.Schedulers = []string{"mesos0"}
+	assert.NotEqual(t, id, OfferID(vol))
+
+	for _, bad := range []string{"", "!!", "bm9iYXI"} {
+		_, _, err = parseOfferID(bad)
+		assert.Equal(t, 400, types.ErrorStatus(err), bad)
+	}
+}
+
+func TestOfferState(t *testing.T) {
+	state := &types.OfferState{
+		Lease:    &types.Lease{FrameworkID: "fw1", Expires: 100},
+		Declines: map[string]int64{"fw2": 50, "fw3": 200},
+	}
+
+	assert.False(t, leasedToOther(state, "fw1", 10))
+	assert.True(t, leasedToOther(state, "fw2", 10))
+	assert.False(t, leasedToOther(state, "fw2", 100))
+	assert.True(t, declined(state, "fw2", 10))
+	assert.False(t, declined(state, "fw2", 50))
+	assert.False(t, declined(state, "fw1", 10))
+
+	assert.False(t, prune(state, 10))
+	assert.True(t, prune(state, 100))
+	assert.Nil(t, state.Lease)
+	assert.Equal(t, map[string]int64{"fw3": 200}, state.Declines)
+
+	state.Lease = &types.Lease{FrameworkID: "fw1"}
+	assert.False(t, prune(state, 150))
+	assert.True(t, leasedToOther(state, "fw2", 1<<40))
+}
+
+func TestLockOffers(t *testing.T) {
+	v := newTestVsc(t)
+	unlock, err := v.lockOffers()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the copies bound to a request wait for the lock too
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := v.WithContext(context.Background()).lockOffers()
+		if assert.NoError(t, err) {
+			unlock()
+		}
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("offer states locked twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
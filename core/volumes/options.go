@@ -0,0 +1,54 @@
+package volumes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emccode/polly/api/types"
+)
+
+// createOptions are the volume create options that are not labels, keyed by
+// their lower cased name
+var createOptions = map[string]func(req *types.VolumeCreateRequest, v string) error{
+	"service": func(req *types.VolumeCreateRequest, v string) error {
+		req.ServiceName = v
+		return nil
+	},
+	"size": func(req *types.VolumeCreateRequest, v string) (err error) {
+		req.Size, err = strconv.ParseInt(v, 10, 64)
+		return err
+	},
+	"iops": func(req *types.VolumeCreateRequest, v string) (err error) {
+		req.IOPS, err = strconv.ParseInt(v, 10, 64)
+		return err
+	},
+	"volumetype": func(req *types.VolumeCreateRequest, v string) error {
+		req.VolumeType = v
+		return nil
+	},
+	"availabilityzone": func(req *types.VolumeCreateRequest, v string) error {
+		req.AvailabilityZone = v
+		return nil
+	},
+}
+
+// CreateOptions applies free form options to a create request. The service,
+// size, iops, volumeType and availabilityZone options, matched regardless of
+// case, set the request fields and the other options are labels.
+func CreateOptions(req *types.VolumeCreateRequest, opts map[string]string) error {
+	for k, v := range opts {
+		if set, ok := createOptions[strings.ToLower(k)]; ok {
+			if err := set(req, v); err != nil {
+				return types.NewErrorf(http.StatusBadRequest,
+					"invalid option %s=%q", k, v).WithDetail("option", k)
+			}
+			continue
+		}
+		if req.Labels == nil {
+			req.Labels = make(map[string]string)
+		}
+		req.Labels[k] = v
+	}
+	return nil
+}
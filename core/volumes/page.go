@@ -0,0 +1,175 @@
+package volumes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/query"
+)
+
+const (
+	limitParam    = "limit"
+	continueParam = "continue"
+	sortParam     = "sort"
+
+	// defaultSort orders volumes when no sort key is requested
+	defaultSort = "volumeID"
+)
+
+// pageParams are the query parameters that page a listing rather than
+// select volumes
+var pageParams = map[string]bool{
+	limitParam:    true,
+	continueParam: true,
+	sortParam:     true,
+}
+
+// sortKeys are the keys volumes can be ordered by
+var sortKeys = map[string]struct {
+	numeric bool
+	value   func(v *types.Volume) string
+}{
+	"volumeID": {false, func(v *types.Volume) string { return v.VolumeID }},
+	"name": {false, func(v *types.Volume) string {
+		if v.Volume == nil {
+			return ""
+		}
+		return v.Name
+	}},
+	"serviceName": {false, func(v *types.Volume) string { return v.ServiceName }},
+	"size": {true, func(v *types.Volume) string {
+		if v.Volume == nil {
+			return "0"
+		}
+		return strconv.FormatInt(v.Size, 10)
+	}},
+	"iops": {true, func(v *types.Volume) string {
+		if v.Volume == nil {
+			return "0"
+		}
+		return strconv.FormatInt(v.IOPS, 10)
+	}},
+	"created": {true, func(v *types.Volume) string {
+		return strconv.FormatInt(v.Created, 10)
+	}},
+}
+
+// cursor is the position after the last volume of a page
+type cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	VolumeID string `json:"id"`
+}
+
+// page orders the volumes by the requested sort key, ties broken by volume
+// ID, and returns those after the continue token up to the limit, none when
+// 0, along with the token of the next page, empty on the last page
+func page(vols []*types.Volume, vals url.Values) ([]*types.Volume, string, error) {
+	sortBy := vals.Get(sortParam)
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	desc := strings.HasPrefix(sortBy, "-")
+	key, ok := sortKeys[strings.TrimPrefix(sortBy, "-")]
+	if !ok {
+		return nil, "", &query.Error{
+			Param: sortParam, Expr: sortBy, Reason: "unknown sort key"}
+	}
+
+	limit := 0
+	if l := vals.Get(limitParam); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			return nil, "", &query.Error{
+				Param: limitParam, Expr: l, Reason: "expected a non-negative number"}
+		}
+		limit = n
+	}
+
+	compare := func(aValue, aID, bValue, bID string) int {
+		c := compareValues(aValue, bValue, key.numeric)
+		if c == 0 {
+			c = strings.Compare(aID, bID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+
+	sorted := make([]*types.Volume, len(vols))
+	copy(sorted, vols)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(
+			key.value(sorted[i]), sorted[i].VolumeID,
+			key.value(sorted[j]), sorted[j].VolumeID) < 0
+	})
+
+	if token := vals.Get(continueParam); token != "" {
+		c, err := decodeCursor(token)
+		if err != nil || c.Sort != sortBy {
+			return nil, "", &query.Error{
+				Param: continueParam, Expr: token, Reason: "invalid or expired token"}
+		}
+		sorted = sorted[sort.Search(len(sorted), func(i int) bool {
+			return compare(
+				key.value(sorted[i]), sorted[i].VolumeID, c.Value, c.VolumeID) > 0
+		}):]
+	}
+
+	if limit == 0 || len(sorted) <= limit {
+		return sorted, "", nil
+	}
+
+	sorted = sorted[:limit]
+	last := sorted[limit-1]
+	next, err := encodeCursor(&cursor{
+		Sort:     sortBy,
+		Value:    key.value(last),
+		VolumeID: last.VolumeID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return sorted, next, nil
+}
+
+func compareValues(a, b string, numeric bool) int {
+	if numeric {
+		an, _ := strconv.ParseFloat(a, 64)
+		bn, _ := strconv.ParseFloat(b, 64)
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func encodeCursor(c *cursor) (string, error) {
+	j, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(j), nil
+}
+
+func decodeCursor(token string) (*cursor, error) {
+	j, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(j, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
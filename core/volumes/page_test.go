@@ -0,0 +1,59 @@
+package volumes
+
+import (
+	"net/url"
+	"testing"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPageVolume(id string, size int64) *types.Volume {
+	return &types.Volume{
+		Volume:   &lstypes.Volume{Name: id, Size: size},
+		VolumeID: id,
+	}
+}
+
+func TestPage(t *testing.T) {
+	vols := []*types.Volume{
+		newPageVolume("mock-vol-3", 10),
+		newPageVolume("mock-vol-1", 30),
+		newPageVolume("mock-vol-2", 10),
+		newPageVolume("mock-vol-4", 20),
+	}
+
+	var ids []string
+	vals := url.Values{"sort": {"-size"}, "limit": {"3"}}
+	for {
+		p, next, err := page(vols, vals)
+		assert.NoError(t, err)
+		for _, v := range p {
+			ids = append(ids, v.VolumeID)
+		}
+		if next == "" {
+			break
+		}
+		vals.Set("continue", next)
+	}
+	assert.Equal(t,
+		[]string{"mock-vol-1", "mock-vol-4", "mock-vol-3", "mock-vol-2"}, ids)
+
+	p, next, err := page(vols, url.Values{})
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Equal(t, "mock-vol-1", p[0].VolumeID)
+	assert.Len(t, p, 4)
+}
+
+func TestPageInvalid(t *testing.T) {
+	for _, vals := range []url.Values{
+		{"sort": {"color"}},
+		{"limit": {"-1"}},
+		{"continue": {"garbage"}},
+	} {
+		_, _, err := page(nil, vals)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,232 @@
+package volumes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tracing"
+)
+
+// ETag returns the entity tag of the Polly metadata of a volume, the
+// schedulers, labels and tenant that can be changed through the admin API
+func ETag(vol *types.Volume) string {
+	// empty and missing metadata are the same once stored
+	m := struct {
+		Schedulers []string          `json:"s,omitempty"`
+		Labels     map[string]string `json:"l,omitempty"`
+		Tenant     string            `json:"t,omitempty"`
+	}{vol.Schedulers, vol.Labels, vol.Tenant}
+	j, _ := json.Marshal(&m)
+	h := fnv.New64a()
+	h.Write(j)
+	return fmt.Sprintf(`"%016x"`, h.Sum64())
+}
+
+// matches returns whether an If-Match header matches the entity tag, an
+// empty header matches any
+func matches(ifMatch, etag string) bool {
+	if ifMatch == "" {
+		return true
+	}
+	for _, m := range strings.Split(ifMatch, ",") {
+		if m = strings.TrimSpace(m); m == "*" || m == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// VolumeUpdate applies a change to the metadata of a volume and saves it. A
+// non empty ifMatch must match the entity tag of the current metadata, the
+// update is otherwise rejected with a 412. Updates through this service are
+// serialized so the check and the save are not interleaved. The store is not
+// compare-and-swapped, so the tag only protects against writers on the same
+// instance, not against other daemons sharing the store.
+func (v *Vsc) VolumeUpdate(
+	volumeID, ifMatch string,
+	change func(vol *types.Volume) error) (vol *types.Volume, err error) {
+
+	v, span := v.span("VolumeUpdate", volumeAttr(volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	vol, err = v.VolumeInspect(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMatch(vol, ifMatch); err != nil {
+		return nil, err
+	}
+	before := snapshot(vol)
+	if err := change(vol); err != nil {
+		return nil, err
+	}
+	if err := v.validate(vol, before); err != nil {
+		return nil, err
+	}
+
+	// the lease is released first, a lease left on a revoked offer would
+	// keep the volume from the schedulers it is still offered to
+	if len(missing(before.Schedulers, vol.Schedulers)) > 0 {
+		if err := v.releaseRevoked(vol); err != nil {
+			return nil, err
+		}
+	}
+	err = v.store().SaveVolumeMetadata(vol)
+	if err != nil {
+		return nil, err
+	}
+
+	v.notifyUpdate(vol, before.Schedulers, before.Labels)
+	return vol, nil
+}
+
+// validate checks a change of the metadata of a volume, given the volume
+// before the change: its tenant and the schedulers it is offered to, then
+// its labels against the schema
+func (v *Vsc) validate(vol, before *types.Volume) error {
+	if err := v.checkTenant(vol, before.Tenant, before.Schedulers); err != nil {
+		return err
+	}
+	if sameLabels(before.Labels, vol.Labels) {
+		return nil
+	}
+	return v.checkLabels(vol.ServiceName, before.Labels, vol.Labels)
+}
+
+// VolumeRemoveMatch removes a volume when ifMatch, if set, matches the entity
+// tag of its metadata
+func (v *Vsc) VolumeRemoveMatch(volumeID, ifMatch string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if ifMatch != "" {
+		vol, err := v.VolumeInspect(volumeID)
+		if err != nil {
+			return err
+		}
+		if err := checkMatch(vol, ifMatch); err != nil {
+			return err
+		}
+	}
+	return v.VolumeRemove(volumeID)
+}
+
+func checkMatch(vol *types.Volume, ifMatch string) error {
+	etag := ETag(vol)
+	if matches(ifMatch, etag) {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"volumeID": vol.VolumeID,
+		"ifMatch":  ifMatch,
+		"etag":     etag,
+	}).Debug("volume metadata changed")
+	return types.NewErrorf(http.StatusPreconditionFailed,
+		"volume %q was modified", vol.VolumeID).
+		WithDetail("volumeID", vol.VolumeID).
+		WithDetail("etag", etag)
+}
+
+// VolumePatch applies a JSON merge patch (RFC 7396) to the metadata of a
+// volume. The labels object is merged, a null label removing the key, while
+// the schedulers array replaces the offer and the tenant string, or null,
+// replaces the tenant. Other members are rejected.
+func (v *Vsc) VolumePatch(volumeID, ifMatch string, patch []byte) (*types.Volume, error) {
+	apply, err := mergePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	return v.VolumeUpdate(volumeID, ifMatch, func(vol *types.Volume) error {
+		apply(vol)
+		return nil
+	})
+}
+
+var null = []byte("null")
+
+// mergePatch parses a merge patch document into the change it makes, so an
+// invalid document is rejected before the volume is looked up
+func mergePatch(patch []byte) (func(vol *types.Volume), error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &doc); err != nil || doc == nil {
+		return nil, types.NewError(http.StatusBadRequest,
+			"merge patch must be a JSON object")
+	}
+
+	var changes []func(vol *types.Volume)
+	for member, raw := range doc {
+		raw = bytes.TrimSpace(raw)
+		switch member {
+		case "labels":
+			if bytes.Equal(raw, null) {
+				changes = append(changes, func(vol *types.Volume) {
+					vol.Labels = nil
+				})
+				continue
+			}
+			var labels map[string]*string
+			if err := json.Unmarshal(raw, &labels); err != nil {
+				return nil, types.NewError(http.StatusBadRequest,
+					"labels must be an object of strings or nulls").
+					WithDetail("member", member)
+			}
+			changes = append(changes, func(vol *types.Volume) {
+				for k, l := range labels {
+					if l == nil {
+						unlabel(vol, []string{k})
+						continue
+					}
+					label(vol, map[string]string{k: *l})
+				}
+			})
+		case "schedulers":
+			if bytes.Equal(raw, null) {
+				changes = append(changes, func(vol *types.Volume) {
+					vol.Schedulers = nil
+				})
+				continue
+			}
+			var schedulers []string
+			if err := json.Unmarshal(raw, &schedulers); err != nil {
+				return nil, types.NewError(http.StatusBadRequest,
+					"schedulers must be an array of strings").
+					WithDetail("member", member)
+			}
+			changes = append(changes, func(vol *types.Volume) {
+				offer(vol, NonEmpty(schedulers))
+			})
+		case "tenant":
+			var tenant *string
+			if err := json.Unmarshal(raw, &tenant); err != nil {
+				return nil, types.NewError(http.StatusBadRequest,
+					"tenant must be a string or null").
+					WithDetail("member", member)
+			}
+			changes = append(changes, func(vol *types.Volume) {
+				vol.Tenant = ""
+				if tenant != nil {
+					vol.Tenant = *tenant
+				}
+			})
+		default:
+			return nil, types.NewErrorf(422,
+				"%q cannot be patched, only labels, schedulers and tenant", member).
+				WithDetail("member", member)
+		}
+	}
+
+	return func(vol *types.Volume) {
+		for _, c := range changes {
+			c(vol)
+		}
+	}, nil
+}
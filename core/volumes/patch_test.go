@@ -0,0 +1,53 @@
+package volumes
+
+import (
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	vol := newPageVolume("mock-vol-1", 10)
+	vol.Labels = map[string]string{"tier": "gold", "owner": "db"}
+	vol.Schedulers = []string{"mesos0"}
+
+	apply, err := mergePatch([]byte(
+		`{"labels":{"tier":"silver","owner":null,"zone":"a"},"schedulers":["mesos1",""]}`))
+	assert.NoError(t, err)
+	apply(vol)
+	assert.Equal(t, map[string]string{"tier": "silver", "zone": "a"}, vol.Labels)
+	assert.Equal(t, []string{"mesos1"}, vol.Schedulers)
+
+	apply, err = mergePatch([]byte(`{"labels":null,"schedulers":null}`))
+	assert.NoError(t, err)
+	apply(vol)
+	assert.Nil(t, vol.Labels)
+	assert.Nil(t, vol.Schedulers)
+
+	for _, doc := range []string{`[]`, `null`, `{"labels":[1]}`, `{"schedulers":"x"}`} {
+		_, err = mergePatch([]byte(doc))
+		assert.True(t, types.IsInvalid(err), doc)
+	}
+	_, err = mergePatch([]byte(`{"size":20}`))
+	assert.Equal(t, 422, types.ErrorStatus(err))
+}
+
+func TestETag(t *testing.T) {
+	vol := newPageVolume("mock-vol-1", 10)
+	etag := ETag(vol)
+
+	vol.Labels = map[string]string{}
+	assert.Equal(t, etag, ETag(vol))
+	assert.True(t, matches("", etag))
+	assert.True(t, matches("*", etag))
+	assert.True(t, matches(`"other", `+etag, etag))
+
+	vol.Labels["tier"] = "gold"
+	assert.NotEqual(t, etag, ETag(vol))
+	assert.False(t, matches(etag, ETag(vol)))
+
+	err := checkMatch(vol, etag)
+	assert.True(t, types.IsPreconditionFailed(err))
+	assert.NoError(t, checkMatch(vol, ETag(vol)))
+}
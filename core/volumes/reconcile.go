@@ -0,0 +1,109 @@
+package volumes
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	reconcilerIntervalKey = "polly.reconciler.interval"
+	reconcilerPurgeKey    = "polly.reconciler.purge"
+)
+
+func init() {
+	gofig.Register(reconcilerRegistration())
+}
+
+// ReconcileInterval returns the configured time between two reconciler passes
+func ReconcileInterval(cfg gofig.Config) time.Duration {
+	return config.Duration(cfg, reconcilerIntervalKey, 5*time.Minute)
+}
+
+// Reconcile compares the volumes known to the store with the volumes reported
+// by libStorage and returns the IDs of the store entries whose volume no
+// longer exists. Only volumes of services currently configured are
+// considered. When purge is set the metadata of those volumes is removed.
+func (v *Vsc) Reconcile(purge bool) (orphans []string, err error) {
+	v, span := v.span("Reconcile", attribute.Bool("purge", purge))
+	defer func() { tracing.End(span, err) }()
+
+	defer func() {
+		switch {
+		case err != nil:
+			metrics.ReconcilerResults.WithLabelValues("error").Inc()
+		case len(orphans) > 0 && purge:
+			metrics.ReconcilerResults.WithLabelValues("purged").Inc()
+		case len(orphans) > 0:
+			metrics.ReconcilerResults.WithLabelValues("orphans").Inc()
+		default:
+			metrics.ReconcilerResults.WithLabelValues("clean").Inc()
+		}
+	}()
+
+	ids, err := v.store().GetVolumeIds()
+	if err != nil {
+		return nil, err
+	}
+
+	vols, err := v.lsClient().Volumes()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, vol := range vols {
+		known[vol.VolumeID] = true
+	}
+
+	for _, id := range ids {
+		if known[id] {
+			continue
+		}
+		d, _, err := splitVolumeID(id)
+		if err != nil {
+			continue
+		}
+		if _, ok := v.p.LsClient.DriverService[d]; !ok {
+			continue
+		}
+		orphans = append(orphans, id)
+	}
+
+	for _, id := range orphans {
+		fields := log.Fields{"volumeID": id}
+		if !purge {
+			log.WithFields(fields).Warn("volume metadata without a volume")
+			continue
+		}
+		if err := v.store().RemoveVolumeMetadata(
+			&types.Volume{VolumeID: id}); err != nil {
+			return orphans, err
+		}
+		log.WithFields(fields).Info("purged metadata of removed volume")
+	}
+
+	return orphans, nil
+}
+
+// ReconcileJob runs Reconcile with the configured purge setting
+func (v *Vsc) ReconcileJob() error {
+	_, err := v.Reconcile(v.p.Config.GetBool(reconcilerPurgeKey))
+	return err
+}
+
+func reconcilerRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Reconciler")
+	r.Key(gofig.String, "", "5m",
+		"The time between two passes of the reconciler", reconcilerIntervalKey)
+	r.Key(gofig.Bool, "", false,
+		"Remove the metadata of volumes that no longer exist",
+		reconcilerPurgeKey)
+	return r
+}
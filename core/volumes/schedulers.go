@@ -0,0 +1,30 @@
+package volumes
+
+import (
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/schedulers"
+)
+
+// checkRegistered checks that the schedulers a volume is offered to are
+// registered, when the registry is validating. The service of the volume,
+// which Polly offers it to on creation, needs no registration.
+func (v *Vsc) checkRegistered(vol *types.Volume, names []string) error {
+	if v.p == nil || !schedulers.Validating(v.p.Config) {
+		return nil
+	}
+	for _, name := range names {
+		if name == vol.ServiceName {
+			continue
+		}
+		s, err := v.store().GetScheduler(name)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			return types.NewErrorf(422, "unknown scheduler %q", name).
+				WithDetail("scheduler", name).
+				WithDetail("volumeID", vol.VolumeID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package volumes
+
+import (
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// VolumeSnapshot snapshots a volume
+func (v *Vsc) VolumeSnapshot(volumeID, name string) (snap *types.Snapshot, err error) {
+	v, span := v.span("VolumeSnapshot", volumeAttr(volumeID))
+	defer func() { tracing.End(span, err) }()
+
+	s, libsvid, err := v.LibsVolumeID(volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	v.logger().WithFields(log.Fields{
+		"pVolumeID":    volumeID,
+		"service":      s,
+		"libsVolumeID": libsvid,
+		"name":         name,
+	}).Debug("vsc.VolumeSnapshot()")
+
+	snap, err = v.lsClient().VolumeSnapshot(s, libsvid, name)
+	if err != nil {
+		return nil, lsError(err)
+	}
+	return snap, nil
+}
+
+// SnapshotInspect returns a snapshot along with the ID of its volume
+func (v *Vsc) SnapshotInspect(snapshotID string) (snap *types.Snapshot, err error) {
+	v, span := v.span("SnapshotInspect",
+		attribute.String("snapshot.id", snapshotID))
+	defer func() { tracing.End(span, err) }()
+
+	s, libssid, err := v.libsSnapshotID(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	v.logger().WithFields(log.Fields{
+		"pSnapshotID":    snapshotID,
+		"service":        s,
+		"libsSnapshotID": libssid,
+	}).Debug("vsc.SnapshotInspect()")
+
+	snap, err = v.lsClient().SnapshotInspect(s, libssid)
+	if err != nil {
+		return nil, lsError(err)
+	}
+	return snap, nil
+}
+
+// SnapshotRemove removes a snapshot
+func (v *Vsc) SnapshotRemove(snapshotID string) (err error) {
+	v, span := v.span("SnapshotRemove",
+		attribute.String("snapshot.id", snapshotID))
+	defer func() { tracing.End(span, err) }()
+
+	s, libssid, err := v.libsSnapshotID(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	v.logger().WithFields(log.Fields{
+		"pSnapshotID":    snapshotID,
+		"service":        s,
+		"libsSnapshotID": libssid,
+	}).Debug("vsc.SnapshotRemove()")
+
+	return lsError(v.lsClient().SnapshotRemove(s, libssid))
+}
+
+// libsSnapshotID translates a Polly SnapshotID to its service and
+// libStorage SnapshotID
+func (v *Vsc) libsSnapshotID(snapshotID string) (string, string, error) {
+	d, libssid, err := splitVolumeID(snapshotID)
+	if err != nil {
+		return "", "", types.NewErrorf(http.StatusBadRequest,
+			"invalid snapshotID %q", snapshotID).WithDetail("snapshotID", snapshotID)
+	}
+	s, ok := v.p.LsClient.DriverService[d]
+	if !ok {
+		return "", "", types.NewErrorf(http.StatusNotFound,
+			"no service found for snapshot %q", snapshotID).
+			WithDetail("snapshotID", snapshotID)
+	}
+	return s, libssid, nil
+}
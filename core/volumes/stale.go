@@ -0,0 +1,199 @@
+package volumes
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/query"
+	"github.com/emccode/polly/core/schedulers"
+	"github.com/emccode/polly/core/tracing"
+)
+
+// liveness holds the last known state of each registered scheduler so the
+// liveness events are only sent when it changes
+type liveness struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+// SchedulerLiveness checks the registered schedulers against the staleness
+// policy. It sends an event when a scheduler is warned about, goes stale or
+// is seen again, and releases the leases of the stale schedulers, revoking
+// their offers too when the policy says so. The first pass after a start
+// warns again about the schedulers already warned about.
+func (v *Vsc) SchedulerLiveness() (err error) {
+	v, span := v.span("SchedulerLiveness")
+	defer func() { tracing.End(span, err) }()
+
+	policy := schedulers.NewPolicy(v.p.Config)
+	ss, err := v.store().Schedulers()
+	if err != nil {
+		return err
+	}
+
+	v.live.mu.Lock()
+	defer v.live.mu.Unlock()
+	if v.live.states == nil {
+		v.live.states = make(map[string]string)
+	}
+
+	metrics.SchedulerUnseen.Reset()
+	now := time.Now()
+	registered := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		s.State = policy.State(s, now)
+		registered[s.Name] = true
+		metrics.SchedulerUnseen.WithLabelValues(s.Name, s.State).Set(
+			schedulers.Unseen(s, now).Seconds())
+
+		prev := v.live.states[s.Name]
+		v.live.states[s.Name] = s.State
+		if s.State != prev {
+			v.notifyLiveness(s, prev, now)
+		}
+		if s.State != types.SchedulerStateStale {
+			continue
+		}
+		if err := v.releaseLeases(s.Name); err != nil {
+			return err
+		}
+		if policy.Action == schedulers.ActionRevoke {
+			if err := v.revokeOffers(s.Name); err != nil {
+				return err
+			}
+		}
+	}
+	for name := range v.live.states {
+		if !registered[name] {
+			delete(v.live.states, name)
+		}
+	}
+	return nil
+}
+
+// notifyLiveness sends the event of a scheduler whose state changed from
+// prev, no event being sent for a scheduler live from the start
+func (v *Vsc) notifyLiveness(s *types.Scheduler, prev string, now time.Time) {
+	fields := log.Fields{
+		"scheduler": s.Name,
+		"unseen":    schedulers.Unseen(s, now).String(),
+	}
+	typ := types.EventSchedulerLive
+	switch s.State {
+	case types.SchedulerStateWarning:
+		typ = types.EventSchedulerWarning
+		v.logger().WithFields(fields).Warn("scheduler not seen, about to go stale")
+	case types.SchedulerStateStale:
+		typ = types.EventSchedulerStale
+		v.logger().WithFields(fields).Warn("scheduler stale")
+	default:
+		if prev == "" || prev == types.SchedulerStateLive {
+			return
+		}
+		v.logger().WithFields(fields).Info("scheduler seen again")
+	}
+
+	c := *s
+	v.p.Webhooks.Notify(&types.Event{
+		Type:      typ,
+		Scheduler: &c,
+	})
+}
+
+// releaseLeases ends the leases held through a scheduler
+func (v *Vsc) releaseLeases(scheduler string) error {
+	unlock, err := v.lockOffers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	states, err := v.store().VolumeOfferStates()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for volumeID, state := range states {
+		lease := state.Lease
+		if lease == nil || lease.Scheduler != scheduler || expired(lease, now) {
+			continue
+		}
+		state.Lease = nil
+		if err := v.store().SaveVolumeOfferState(volumeID, state); err != nil {
+			return err
+		}
+		v.logger().WithFields(log.Fields{
+			"volumeID":    volumeID,
+			"scheduler":   scheduler,
+			"frameworkID": lease.FrameworkID,
+		}).Info("released lease of stale scheduler")
+		metrics.StaleSchedulerActions.WithLabelValues("release").Inc()
+		v.notifyLease(types.EventVolumeRelease, lease)
+	}
+	return nil
+}
+
+// revokeOffers revokes the offers of the volumes to a scheduler
+func (v *Vsc) revokeOffers(scheduler string) error {
+	vols, err := v.Volumes(url.Values{SchedulersParam: {scheduler}})
+	if err != nil {
+		return err
+	}
+	for _, vol := range vols {
+		if _, err := v.VolumeOfferRevoke(vol.VolumeID, []string{scheduler}); err != nil {
+			return err
+		}
+		v.logger().WithFields(log.Fields{
+			"volumeID":  vol.VolumeID,
+			"scheduler": scheduler,
+		}).Info("revoked offer to stale scheduler")
+		metrics.StaleSchedulerActions.WithLabelValues("revoke").Inc()
+	}
+	return nil
+}
+
+// checkSuspended returns the error of the first suspended scheduler a
+// selector selects the volumes of by name, so the listings made for a
+// scheduler withhold its offers as its offer feeds do
+func (v *Vsc) checkSuspended(sel query.Selector) error {
+	for _, r := range sel {
+		key := strings.ToLower(r.Key)
+		if (key != "schedulers" && key != "scheduler") ||
+			(r.Operator != query.Equals && r.Operator != query.In) {
+			continue
+		}
+		for _, name := range r.Values {
+			if err := v.Suspended(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Suspended returns the 409 of the offers to a scheduler withheld as it is
+// stale, nil when they are not
+func (v *Vsc) Suspended(name string) error {
+	policy := schedulers.NewPolicy(v.p.Config)
+	if policy.Action != schedulers.ActionSuspend || policy.StaleAfter == 0 {
+		return nil
+	}
+	s, err := v.store().GetScheduler(name)
+	if err != nil || s == nil {
+		return err
+	}
+	now := time.Now()
+	if !policy.Suspended(s, now) {
+		return nil
+	}
+	return types.NewErrorf(http.StatusConflict,
+		"offers to scheduler %q are suspended, it was not seen for %s",
+		name, schedulers.Unseen(s, now).Round(time.Second)).
+		WithDetail("scheduler", name)
+}
@@ -0,0 +1,47 @@
+package volumes
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSuspended(t *testing.T) {
+	v := newTestVsc(t)
+	assert.NoError(t, v.store().SaveScheduler(&types.Scheduler{
+		Name:    "marathon-1",
+		Type:    types.SchedulerTypeMesos,
+		Created: time.Now().Add(-2 * time.Hour),
+	}))
+
+	check := func(filter string) error {
+		sel, err := query.Parse(filter)
+		assert.NoError(t, err)
+		return v.checkSuspended(sel)
+	}
+	assert.NoError(t, check("schedulers=marathon-1"))
+
+	v.p.Config.Set("polly.schedulers.staleAfter", "1h")
+	for _, filter := range []string{
+		"schedulers=marathon-1",
+		"scheduler==marathon-1",
+		"size>1,schedulers in (k8s-1,marathon-1)",
+	} {
+		assert.True(t, types.IsConflict(check(filter)), filter)
+	}
+	for _, filter := range []string{
+		"", "schedulers=k8s-1", "schedulers!=marathon-1", "labels.schedulers=marathon-1",
+	} {
+		assert.NoError(t, check(filter), filter)
+	}
+	sel, err := Selector(url.Values{SchedulersParam: {"marathon-1"}})
+	assert.NoError(t, err)
+	assert.True(t, types.IsConflict(v.checkSuspended(sel)))
+
+	v.p.Config.Set("polly.schedulers.staleAction", "revoke")
+	assert.NoError(t, check("schedulers=marathon-1"))
+}
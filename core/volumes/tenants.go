@@ -0,0 +1,137 @@
+package volumes
+
+import (
+	"net/http"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/tenants"
+)
+
+// visible returns whether a volume belongs to the tenant the service is
+// restricted to, if any
+func (v *Vsc) visible(vol *types.Volume) bool {
+	return v.tenant == "" || vol.Tenant == v.tenant
+}
+
+// createTenant returns the tenant of a new volume, the one the service is
+// restricted to or else the one of the request, and checks the schedulers
+// the request offers the volume to
+func (v *Vsc) createTenant(request *types.VolumeCreateRequest) (string, error) {
+	tenant := request.Tenant
+	if v.tenant != "" {
+		if tenant != "" && tenant != v.tenant {
+			return "", types.NewErrorf(http.StatusForbidden,
+				"cannot create a volume of tenant %q", tenant).
+				WithDetail("tenant", tenant)
+		}
+		tenant = v.tenant
+	} else if err := v.checkTenantExists(tenant); err != nil {
+		return "", err
+	}
+
+	vol := &types.Volume{ServiceName: request.ServiceName, Tenant: tenant}
+	return tenant, v.checkSchedulers(vol, NonEmpty(request.Schedulers))
+}
+
+// checkTenant checks a metadata change given the tenant and schedulers of
+// the volume before it. Only unrestricted requests may change the tenant,
+// and a volume may only be offered to the schedulers of its tenant or, by
+// unrestricted requests, to the schedulers registered to no tenant.
+func (v *Vsc) checkTenant(vol *types.Volume, tenant string, schedulers []string) error {
+	added := missing(vol.Schedulers, schedulers)
+	if vol.Tenant != tenant {
+		if v.tenant != "" {
+			return types.NewErrorf(http.StatusForbidden,
+				"cannot change the tenant of volume %q", vol.VolumeID).
+				WithDetail("volumeID", vol.VolumeID)
+		}
+		if err := v.checkTenantExists(vol.Tenant); err != nil {
+			return err
+		}
+		added = vol.Schedulers
+	}
+	return v.checkSchedulers(vol, added)
+}
+
+// checkSchedulers checks that a volume may be offered to schedulers
+func (v *Vsc) checkSchedulers(vol *types.Volume, schedulers []string) error {
+	if len(schedulers) == 0 {
+		return nil
+	}
+	if err := v.checkRegistered(vol, schedulers); err != nil {
+		return err
+	}
+	all, err := v.store().Tenants()
+	if err != nil {
+		return err
+	}
+	owners := tenants.Owners(all)
+
+	for _, s := range schedulers {
+		owner := owners[s]
+		if owner != "" && owner == vol.Tenant || owner == "" && v.tenant == "" {
+			continue
+		}
+		e := types.NewErrorf(http.StatusForbidden,
+			"scheduler %q is not registered to tenant %q", s, vol.Tenant)
+		if owner != "" {
+			e = types.NewErrorf(http.StatusForbidden,
+				"scheduler %q is registered to tenant %q", s, owner)
+		}
+		return e.WithDetail("scheduler", s).WithDetail("volumeID", vol.VolumeID)
+	}
+	return nil
+}
+
+func (v *Vsc) checkTenantExists(name string) error {
+	if name == "" {
+		return nil
+	}
+	t, err := v.store().GetTenant(name)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return types.NewErrorf(422, "unknown tenant %q", name).
+			WithDetail("tenant", name)
+	}
+	return nil
+}
+
+// tenantSchedulers returns the schedulers registered to the tenant the
+// service is restricted to
+func (v *Vsc) tenantSchedulers() ([]string, error) {
+	t, err := v.store().GetTenant(v.tenant)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	return t.Schedulers, nil
+}
+
+// checkScheduler checks that the scheduler is registered to the tenant the
+// service is restricted to, if any
+func (v *Vsc) checkScheduler(scheduler string) error {
+	if v.tenant == "" {
+		return nil
+	}
+	schedulers, err := v.tenantSchedulers()
+	if err != nil {
+		return err
+	}
+	if !contains(schedulers, scheduler) {
+		return types.NewErrorf(http.StatusForbidden,
+			"scheduler %q is not registered to tenant %q", scheduler, v.tenant).
+			WithDetail("scheduler", scheduler)
+	}
+	return nil
+}
+
+// checkVisible checks that a volume belongs to the tenant the service is
+// restricted to, if any
+func (v *Vsc) checkVisible(volumeID string) error {
+	if v.tenant == "" {
+		return nil
+	}
+	_, err := v.VolumeInspect(volumeID)
+	return err
+}
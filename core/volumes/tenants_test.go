@@ -0,0 +1,46 @@
+package volumes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisible(t *testing.T) {
+	blue := &types.Volume{VolumeID: "vol-1", Tenant: "blue"}
+	shared := &types.Volume{VolumeID: "vol-2"}
+
+	v := &Vsc{}
+	assert.True(t, v.visible(blue))
+	assert.True(t, v.visible(shared))
+
+	v = &Vsc{tenant: "blue"}
+	assert.True(t, v.visible(blue))
+	assert.False(t, v.visible(shared))
+
+	v = &Vsc{tenant: "red"}
+	assert.False(t, v.visible(blue))
+	assert.NoError(t, (&Vsc{}).checkScheduler("marathon"))
+	assert.NoError(t, (&Vsc{}).checkVisible("vol-1"))
+}
+
+func TestCreateTenant(t *testing.T) {
+	// a restricted service creates the volumes of its tenant
+	v := &Vsc{tenant: "blue"}
+	tenant, err := v.createTenant(&types.VolumeCreateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", tenant)
+	tenant, err = v.createTenant(&types.VolumeCreateRequest{Tenant: "blue"})
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", tenant)
+
+	_, err = v.createTenant(&types.VolumeCreateRequest{Tenant: "red"})
+	assert.Equal(t, http.StatusForbidden, types.ErrorStatus(err))
+
+	// an unrestricted service creates shared volumes by default
+	tenant, err = (&Vsc{}).createTenant(&types.VolumeCreateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", tenant)
+}
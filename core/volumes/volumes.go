@@ -1,76 +1,242 @@
 package volumes
 
 import (
+	"context"
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/goof"
 	apitypes "github.com/emccode/libstorage/api/types"
+	pcontext "github.com/emccode/polly/api/context"
 	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/libstorage/client"
+	"github.com/emccode/polly/core/metrics"
+	"github.com/emccode/polly/core/query"
+	"github.com/emccode/polly/core/store"
+	"github.com/emccode/polly/core/tracing"
 	ptypes "github.com/emccode/polly/core/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Vsc is the Polly volume service
 type Vsc struct {
-	p *ptypes.Polly
+	p   *ptypes.Polly
+	inv *inventory
+
+	// mu serializes metadata updates, it is shared by the copies bound to a
+	// request
+	mu *sync.Mutex
+
+	// labels holds the label schema, it is shared by the copies too
+	labels *labelSchemas
+
+	// live holds the states of the registered schedulers, shared as well
+	live *liveness
+
+	// ctx is the context of the admin request the service is bound to, it
+	// holds the current span
+	ctx context.Context
+
+	// requestID correlates the logs and libStorage calls of an admin request
+	requestID string
+
+	// tenant restricts the service to the volumes of a tenant when set
+	tenant string
+
+	// reserved allows changing the labels reserved for the service broker
+	reserved bool
+}
+
+// Listing is a page of a volume listing
+type Listing struct {
+	Volumes []*types.Volume
+
+	// Continue is the token of the next page, empty on the last page
+	Continue string
+
+	// Fetched is when the volumes were listed from libStorage
+	Fetched time.Time
 }
 
 // New returns a Polly object
 func New(p *ptypes.Polly) *Vsc {
-	return &Vsc{
-		p: p,
+	v := &Vsc{
+		p:      p,
+		mu:     &sync.Mutex{},
+		labels: &labelSchemas{},
+		live:   &liveness{},
+	}
+	v.inv = newInventory(p.Config, func(ctx context.Context) ([]*types.Volume, error) {
+		return v.p.LsClient.WithContext(ctx).Volumes()
+	})
+	return v
+}
+
+// WithContext returns a copy of the service bound to the context of an admin
+// request. Its logs and libStorage calls carry the ID of the request and its
+// operations are traced as children of the span of the request. The copy
+// shares the inventory and the update lock of the service, and is restricted
+// to the tenant of the request if any.
+func (v *Vsc) WithContext(ctx context.Context) *Vsc {
+	rv := *v
+	rv.ctx = ctx
+	rv.requestID = pcontext.RequestID(ctx)
+	rv.tenant = pcontext.Tenant(ctx)
+	return &rv
+}
+
+// span starts the span of an operation and returns a copy of the service
+// bound to it
+func (v *Vsc) span(op string, attrs ...attribute.KeyValue) (*Vsc, trace.Span) {
+	ctx, span := tracing.StartSpan(v.ctx, "vsc."+op, attrs...)
+	rv := *v
+	rv.ctx = ctx
+	return &rv, span
+}
+
+// lsClient returns the libStorage client bound to the context if any
+func (v *Vsc) lsClient() *client.Client {
+	return v.p.LsClient.WithContext(v.ctx)
+}
+
+// store returns the store bound to the context if any
+func (v *Vsc) store() *store.PollyStore {
+	if v.ctx == nil {
+		return v.p.Store
+	}
+	return v.p.Store.WithContext(v.ctx)
+}
+
+// logger returns a log entry with the request ID if any
+func (v *Vsc) logger() *log.Entry {
+	if v.requestID == "" {
+		return log.NewEntry(log.StandardLogger())
 	}
+	return log.WithField("requestID", v.requestID)
+}
+
+// Start refreshes the cached volume inventory in the background
+func (v *Vsc) Start() {
+	v.inv.start()
+}
+
+// Close stops refreshing the cached volume inventory
+func (v *Vsc) Close() {
+	v.inv.close()
+}
+
+// Invalidate makes the next listing query libStorage
+func (v *Vsc) Invalidate() {
+	v.inv.invalidate()
 }
 
 // Volumes lists the registered and filtered volumes
 func (v *Vsc) Volumes(vals url.Values) ([]*types.Volume, error) {
-	log.WithFields(log.Fields{
-		"vals": vals,
-	}).Debug("vsc.Volumes()")
-	vols, err := v.p.LsClient.Volumes()
+	l, err := v.list(vals, false)
 	if err != nil {
 		return nil, err
 	}
+	return l.Volumes, nil
+}
 
-	var volsOut []*types.Volume
-	for _, vol := range vols {
-		exists, err := v.p.Store.SetVolumeMetadata(vol)
-		if err != nil {
-			return nil, goof.WithError("problem ckecking volume status in store", err)
-		}
+// VolumesAll lists all and filtered volumes
+func (v *Vsc) VolumesAll(vals url.Values) ([]*types.Volume, error) {
+	l, err := v.list(vals, true)
+	if err != nil {
+		return nil, err
+	}
+	return l.Volumes, nil
+}
 
-		if exists && volumeFilter(vol, vals) {
-			volsOut = append(volsOut, vol)
-		}
+// VolumesPage lists a page of the registered and filtered volumes
+func (v *Vsc) VolumesPage(vals url.Values) (*Listing, error) {
+	return v.listPage(vals, false)
+}
+
+// VolumesAllPage lists a page of all and filtered volumes
+func (v *Vsc) VolumesAllPage(vals url.Values) (*Listing, error) {
+	return v.listPage(vals, true)
+}
+
+func (v *Vsc) listPage(vals url.Values, all bool) (*Listing, error) {
+	l, err := v.list(vals, all)
+	if err != nil {
+		return nil, err
+	}
+	l.Volumes, l.Continue, err = page(l.Volumes, vals)
+	if err != nil {
+		return nil, err
 	}
-	return volsOut, nil
+	return l, nil
 }
 
-// VolumesAll lists all and filtered volumes
-func (v *Vsc) VolumesAll(vals url.Values) ([]*types.Volume, error) {
-	log.WithFields(log.Fields{
+// list returns the filtered volumes, only those known to the store unless
+// all is set. The metadata is loaded before filtering so labels and
+// schedulers can be selected on, selecting a suspended scheduler by name
+// listing no volumes.
+func (v *Vsc) list(vals url.Values, all bool) (l *Listing, err error) {
+	v, span := v.span("list", attribute.Bool("all", all))
+	defer func() { tracing.End(span, err) }()
+
+	v.logger().WithFields(log.Fields{
 		"vals": vals,
-	}).Debug("vsc.VolumesAll()")
-	vols, err := v.p.LsClient.Volumes()
+		"all":  all,
+	}).Debug("vsc.list()")
+	sel, err := Selector(vals)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkSuspended(sel); err != nil {
+		// the listings of a suspended scheduler withhold its offers
+		v.logger().WithError(err).Debug("withholding volumes")
+		return &Listing{}, nil
+	}
+
+	fresh, _ := strconv.ParseBool(vals.Get(freshParam))
+	vols, fetched, err := v.inv.volumes(v.ctx, fresh)
 	if err != nil {
 		return nil, err
 	}
 
-	var volsOut []*types.Volume
-	for _, vol := range vols {
-		if volumeFilter(vol, vals) {
-			_, err := v.p.Store.SetVolumeMetadata(vol)
-			if err != nil {
-				return nil, goof.WithError("problem ckecking volume status in store", err)
-			}
+	managed, err := v.store().SetVolumesMetadata(vols)
+	if err != nil {
+		return nil, goof.WithError("problem ckecking volume status in store", err)
+	}
 
-			volsOut = append(volsOut, vol)
+	l = &Listing{Fetched: fetched}
+	for i, vol := range vols {
+		if (all || managed[i]) && v.visible(vol) && sel.Matches(vol) {
+			l.Volumes = append(l.Volumes, vol)
 		} else {
-			log.WithField("vol", vol).Debug("filtered volume")
+			v.logger().WithField("vol", vol).Debug("filtered volume")
 		}
 	}
-	return volsOut, nil
+	recordInventory(vols, managed)
+	return l, nil
+}
+
+// recordInventory updates the volume metrics from a complete listing where
+// managed[i] reports whether vols[i] is known to the store
+func recordInventory(vols []*types.Volume, managed []bool) {
+	managedBySvc := make(map[string]int)
+	unmanagedBySvc := make(map[string]int)
+	offered := make(map[string]int)
+	for i, vol := range vols {
+		if !managed[i] {
+			unmanagedBySvc[vol.ServiceName]++
+			continue
+		}
+		managedBySvc[vol.ServiceName]++
+		for _, s := range vol.Schedulers {
+			offered[s]++
+		}
+	}
+	metrics.SetInventory(managedBySvc, unmanagedBySvc, offered)
 }
 
 //LibsVolumeID translates a Polly VolumeID to a libStorage VolumeID
@@ -83,37 +249,44 @@ func (v *Vsc) LibsVolumeID(pVolumeID string) (string, string, error) {
 	var s string
 	var ok bool
 	if s, ok = v.p.LsClient.DriverService[d]; !ok {
-		return "", "", goof.New("service not found")
+		return "", "", types.NewErrorf(http.StatusNotFound,
+			"no service found for volume %q", pVolumeID).WithDetail("volumeID", pVolumeID)
 	}
 
 	return s, vid, nil
 }
 
 // VolumeInspect returns details about a volume
-func (v *Vsc) VolumeInspect(volumeID string) (*types.Volume, error) {
+func (v *Vsc) VolumeInspect(volumeID string) (vol *types.Volume, err error) {
+	v, span := v.span("VolumeInspect", volumeAttr(volumeID))
+	defer func() { tracing.End(span, err) }()
 
 	s, libsvid, err := v.LibsVolumeID(volumeID)
 	if err != nil {
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeInspect()")
 
-	vol, err := v.p.LsClient.VolumeInspect(s, libsvid, false)
+	vol, err = v.lsClient().VolumeInspect(s, libsvid, false)
 	if err != nil {
-		return nil, err
+		return nil, lsError(err)
 	}
 
-	_, err = v.p.Store.SetVolumeMetadata(vol)
+	_, err = v.store().SetVolumeMetadata(vol)
 	if err != nil {
 		return nil, err
 	}
+	if !v.visible(vol) {
+		return nil, types.NewErrorf(http.StatusNotFound,
+			"no volume %q", volumeID).WithDetail("volumeID", volumeID)
+	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"vol":   vol,
 		"lsvol": vol.Volume,
 	}).Debug("vsc.VolumeInspect() result")
@@ -128,24 +301,60 @@ func (v *Vsc) VolumeOffer(volumeID string, schedulers []string) (*types.Volume,
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeOffer()")
 
-	vol, err := v.VolumeInspect(volumeID)
-	if err != nil {
-		return nil, err
-	}
+	return v.update(volumeID, func(vol *types.Volume) {
+		offer(vol, schedulers)
+	})
+}
+
+// update applies a change to the metadata of a volume and saves it
+func (v *Vsc) update(volumeID string, change func(vol *types.Volume)) (*types.Volume, error) {
+	return v.VolumeUpdate(volumeID, "", func(vol *types.Volume) error {
+		change(vol)
+		return nil
+	})
+}
+
+// offer sets the schedulers a volume is offered to
+func offer(vol *types.Volume, schedulers []string) {
 	vol.Schedulers = schedulers
+}
 
-	err = v.p.Store.SaveVolumeMetadata(vol)
-	if err != nil {
-		return nil, err
+// revoke removes schedulers from the offer of a volume
+func revoke(vol *types.Volume, schedulers []string) {
+	var newSchedulers []string
+	for _, sd := range vol.Schedulers {
+		if !contains(schedulers, sd) {
+			newSchedulers = append(newSchedulers, sd)
+		}
 	}
 
-	return vol, nil
+	vol.Schedulers = newSchedulers
+}
+
+// label creates or updates labels on a volume
+func label(vol *types.Volume, labels map[string]string) {
+	if vol.Labels == nil {
+		vol.Labels = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		vol.Labels[k] = v
+	}
+}
+
+// unlabel removes labels from a volume
+func unlabel(vol *types.Volume, keys []string) {
+	for _, k := range keys {
+		if _, ok := vol.Labels[k]; ok {
+			log.WithField("key", k).Debug("removed key from labels")
+			delete(vol.Labels, k)
+		}
+	}
 }
 
 func contains(s []string, e string) bool {
@@ -164,38 +373,42 @@ func (v *Vsc) VolumeOfferRevoke(volumeID string, schedulers []string) (*types.Vo
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeRevoke()")
 
-	vol, err := v.VolumeInspect(volumeID)
-	if err != nil {
-		return nil, err
-	}
-
-	var newSchedulers []string
-	for _, sd := range vol.Schedulers {
-		if !contains(schedulers, sd) {
-			newSchedulers = append(newSchedulers, sd)
-		}
-	}
+	return v.update(volumeID, func(vol *types.Volume) {
+		revoke(vol, schedulers)
+	})
+}
 
-	vol.Schedulers = newSchedulers
+// statusError is implemented by the libStorage client errors that carry the
+// HTTP status returned by the libStorage server
+type statusError interface {
+	Status() int
+}
 
-	err = v.p.Store.SaveVolumeMetadata(vol)
-	if err != nil {
-		return nil, err
+// lsError keeps the status of a libStorage error rejecting a request, such
+// as an unknown volume or a conflicting name, so it is reported to the
+// caller rather than as an internal error
+func lsError(err error) error {
+	if se, ok := err.(statusError); ok && se.Status() >= 400 && se.Status() < 500 {
+		return types.NewError(se.Status(), err.Error())
 	}
+	return err
+}
 
-	return vol, nil
+func volumeAttr(volumeID string) attribute.KeyValue {
+	return attribute.String("volume.id", volumeID)
 }
 
 func splitVolumeID(volumeID string) (string, string, error) {
 	arr := strings.SplitN(volumeID, "-", 2)
 	if len(arr) != 2 {
-		return "", "", goof.New("invalid volumeID")
+		return "", "", types.NewErrorf(http.StatusBadRequest,
+			"invalid volumeID %q", volumeID).WithDetail("volumeID", volumeID)
 	}
 	return arr[0], arr[1], nil
 }
@@ -207,27 +420,15 @@ func (v *Vsc) VolumeLabel(volumeID string, labels map[string]string) (*types.Vol
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeInspect()")
 
-	vol, err := v.VolumeInspect(volumeID)
-	if err != nil {
-		return nil, err
-	}
-
-	for k, v := range labels {
-		vol.Labels[k] = v
-	}
-
-	err = v.p.Store.SaveVolumeMetadata(vol)
-	if err != nil {
-		return nil, err
-	}
-
-	return vol, nil
+	return v.update(volumeID, func(vol *types.Volume) {
+		label(vol, labels)
+	})
 }
 
 // VolumeLabelsRemove removes labels from volumes
@@ -237,38 +438,35 @@ func (v *Vsc) VolumeLabelsRemove(volumeID string, labels []string) (*types.Volum
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeInspect()")
 
-	vol, err := v.VolumeInspect(volumeID)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, k := range labels {
-		if _, ok := vol.Labels[k]; ok {
-			log.WithField("key", k).Debug("removed key from labels")
-			delete(vol.Labels, k)
-		}
-	}
-
-	err = v.p.Store.SaveVolumeMetadata(vol)
-	if err != nil {
-		return nil, err
-	}
-
-	return vol, nil
+	return v.update(volumeID, func(vol *types.Volume) {
+		unlabel(vol, labels)
+	})
 }
 
 // VolumeCreate creates a volume from a request object
-func (v *Vsc) VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, error) {
-	log.WithFields(log.Fields{
+func (v *Vsc) VolumeCreate(request *types.VolumeCreateRequest) (vol *types.Volume, err error) {
+	v, span := v.span("VolumeCreate", attribute.String("service", request.ServiceName),
+		attribute.String("volume.name", request.Name))
+	defer func() { tracing.End(span, err) }()
+
+	v.logger().WithFields(log.Fields{
 		"request": request,
 	}).Debug("vsc.VolumeCreate()")
 
+	if err := v.checkLabels(request.ServiceName, nil, request.Labels); err != nil {
+		return nil, err
+	}
+	tenant, err := v.createTenant(request)
+	if err != nil {
+		return nil, err
+	}
+
 	opts := map[string]interface{}{}
 	volumeCreateRequest := &apitypes.VolumeCreateRequest{
 		Name:             request.Name,
@@ -279,10 +477,12 @@ func (v *Vsc) VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, e
 		Opts:             opts,
 	}
 
-	vol, err := v.p.LsClient.VolumeCreate(request.ServiceName, volumeCreateRequest)
+	vol, err = v.lsClient().VolumeCreate(request.ServiceName, volumeCreateRequest)
 	if err != nil {
-		return nil, err
+		return nil, lsError(err)
 	}
+	v.Invalidate()
+
 	vol.Schedulers = []string{request.ServiceName}
 	for _, sched := range request.Schedulers {
 		if sched != "" {
@@ -291,153 +491,98 @@ func (v *Vsc) VolumeCreate(request *types.VolumeCreateRequest) (*types.Volume, e
 	}
 
 	vol.Labels = request.Labels
+	vol.Tenant = tenant
 
-	err = v.p.Store.SaveVolumeMetadata(vol)
+	err = v.store().SaveVolumeMetadata(vol)
 	if err != nil {
 		return nil, goof.WithError("failed to save metadata", err)
 	}
 
+	v.notify(types.EventVolumeCreate, vol.VolumeID, vol)
 	return vol, nil
 }
 
 // VolumeRemove removes a volume
-func (v *Vsc) VolumeRemove(volumeID string) error {
+func (v *Vsc) VolumeRemove(volumeID string) (err error) {
+	v, span := v.span("VolumeRemove", volumeAttr(volumeID))
+	defer func() { tracing.End(span, err) }()
+
 	s, libsvid, err := v.LibsVolumeID(volumeID)
 	if err != nil {
 		return err
 	}
 
-	log.WithFields(log.Fields{
+	v.logger().WithFields(log.Fields{
 		"pVolumeID":    volumeID,
 		"service":      s,
 		"libsVolumeID": libsvid,
 	}).Debug("vsc.VolumeInspect()")
 
-	vol, err := v.p.LsClient.VolumeInspect(s, libsvid, false)
+	vol, err := v.VolumeInspect(volumeID)
 	if err != nil {
 		return err
 	}
 
-	err = v.p.LsClient.VolumeRemove(s, libsvid)
+	err = v.lsClient().VolumeRemove(s, libsvid)
 	if err != nil {
+		return lsError(err)
+	}
+	v.Invalidate()
+
+	if err := v.store().RemoveVolumeMetadata(vol); err != nil {
 		return err
 	}
+	v.notify(types.EventVolumeRemove, volumeID, nil)
+	return nil
+}
+
+const (
+	// filterParam is the query parameter holding a filter expression
+	filterParam = "filter"
+
+	// allParam selects the unregistered volumes too in a v2 listing
+	allParam = "all"
+
+	// SchedulersParam is the query parameter selecting the volumes offered
+	// to any of its schedulers
+	SchedulersParam = "schedulers"
+)
 
-	return v.p.Store.RemoveVolumeMetadata(vol)
-}
-
-func volumeFilter(v *types.Volume, vals url.Values) bool {
-	for key, value := range vals {
-		log.WithFields(log.Fields{
-			"vol":   v,
-			"lsvol": v.Volume,
-			"key":   key,
-			"value": value[0]}).Info("applyVolumeFilter")
-
-		switch key {
-		case "availabilityZone":
-			if v.AvailabilityZone == value[0] {
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"value": value[0]}).Info("availabilityZone filter match")
-				continue
-			}
-			log.WithFields(log.Fields{
-				"vol":   v,
-				"lsvol": v.Volume,
-				"key":   key,
-				"value": value[0]}).Info("rejected volume by AZ")
-			return false
-		case "iops":
-			i, err := strconv.ParseInt(value[0], 10, 64)
-			if err != nil {
-				return false
-			}
-			if v.IOPS == i {
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"value": value[0]}).Info("IOPS filter match")
-				continue
-			}
-			log.WithFields(log.Fields{
-				"vol":   v,
-				"lsvol": v.Volume,
-				"key":   key,
-				"value": value[0]}).Info("rejected volume by IOPS")
-			return false
-		case "size":
-			i, err := strconv.ParseInt(value[0], 10, 64)
-			if err != nil {
-				return false
-			}
-			if v.Size == i {
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"value": value[0]}).Info("size filter match")
-				continue
-			}
-			log.WithFields(log.Fields{
-				"vol":   v,
-				"lsvol": v.Volume,
-				"key":   key,
-				"value": value[0]}).Info("rejected volume by size")
-			return false
-		case "serviceName":
-			if v.ServiceName == value[0] {
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"value": value[0]}).Info("service filter match")
-				continue
-			}
-			return false
-		default:
-			log.WithFields(log.Fields{
-				"vol":   v,
-				"lsvol": v.Volume,
-				"key":   key,
-				"value": value[0]}).Info("non-standard filter key")
-
-			match := false
-			for k2, v2 := range v.Fields {
-				if k2 != key {
-					continue
-				}
-				if v2 == value[0] {
-					match = true
-				}
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"v2":    v2,
-					"value": value[0]}).Info("reject key")
-
-				break
-			}
-			if match {
-				log.WithFields(log.Fields{
-					"vol":   v,
-					"lsvol": v.Volume,
-					"key":   key,
-					"value": value[0]}).Info("filter match")
-				continue
-			}
-			log.WithFields(log.Fields{
-				"vol":   v,
-				"lsvol": v.Volume,
-				"key":   key,
-				"value": value[0]}).Info("rejected volume")
-			return false
+// legacyKeys map the query parameters that select volumes by equality to
+// their filter keys. Other parameters select libStorage fields.
+var legacyKeys = map[string]string{
+	"availabilityZone": "availabilityZone",
+	"iops":             "iops",
+	"size":             "size",
+	"serviceName":      "serviceName",
+	"tenant":           "tenant",
+	SchedulersParam:    "schedulers",
+}
+
+// Selector builds the selector of a request from its filter expression and
+// the query parameters selecting by equality
+func Selector(vals url.Values) (query.Selector, error) {
+	sel, err := query.Parse(vals.Get(filterParam))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range vals {
+		if key == filterParam || key == freshParam || key == allParam ||
+			pageParams[key] {
+			continue
+		}
+		fkey, ok := legacyKeys[key]
+		if !ok {
+			fkey = "fields." + key
 		}
+		r, err := query.NewRequirement(fkey, query.In, values...)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
 	}
-	log.WithField("name", v.Name).Debug("volume passed all filters")
-	return true
+
+	log.WithField("filter", sel.String()).Debug("volume selector")
+	return sel, nil
 }
@@ -0,0 +1,177 @@
+// Package volumestest provides an in-memory volume service for the tests of
+// the Docker, CSI, Open Service Broker and Kubernetes frontends
+package volumestest
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	lstypes "github.com/emccode/libstorage/api/types"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/query"
+	"github.com/emccode/polly/core/volumes"
+)
+
+// Fake is a volume service holding volumes in memory. A created volume has
+// the ID <service>-vol-<name> and is offered to its service.
+type Fake struct {
+	mu sync.Mutex
+
+	// Vols are the volumes by ID
+	Vols map[string]*types.Volume
+
+	// Created are the create requests received
+	Created []*types.VolumeCreateRequest
+
+	// Release makes the creates wait until it is closed when set
+	Release chan struct{}
+
+	// Snaps are the snapshots by ID
+	Snaps map[string]*types.Snapshot
+
+	// Stale are the schedulers whose offers are suspended
+	Stale map[string]bool
+}
+
+// New returns a fake volume service holding volumes
+func New(vols ...*types.Volume) *Fake {
+	f := &Fake{
+		Vols:  make(map[string]*types.Volume),
+		Snaps: make(map[string]*types.Snapshot),
+	}
+	for _, vol := range vols {
+		f.Vols[vol.VolumeID] = vol
+	}
+	return f
+}
+
+// Volumes lists the volumes matching the selector of a listing, none when
+// it selects a scheduler in Stale
+func (f *Fake) Volumes(vals url.Values) ([]*types.Volume, error) {
+	sel, err := volumes.Selector(vals)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range vals[volumes.SchedulersParam] {
+		if f.Stale[name] {
+			return nil, nil
+		}
+	}
+	var vols []*types.Volume
+	for _, vol := range f.Vols {
+		if sel.Matches(vol) {
+			vols = append(vols, vol)
+		}
+	}
+	return vols, nil
+}
+
+// VolumesPage lists the volumes matching the filter of a listing on a
+// single page, any continue token being invalid
+func (f *Fake) VolumesPage(vals url.Values) (*volumes.Listing, error) {
+	if token := vals.Get("continue"); token != "" {
+		return nil, &query.Error{
+			Param: "continue", Expr: token, Reason: "invalid or expired token"}
+	}
+	vols, err := f.Volumes(vals)
+	if err != nil {
+		return nil, err
+	}
+	return &volumes.Listing{Volumes: vols}, nil
+}
+
+// VolumeCreate creates a volume offered to its service and to the
+// schedulers of the request
+func (f *Fake) VolumeCreate(req *types.VolumeCreateRequest) (*types.Volume, error) {
+	if f.Release != nil {
+		<-f.Release
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Created = append(f.Created, req)
+	vol := &types.Volume{
+		Volume: &lstypes.Volume{
+			ID:   "vol-" + req.Name,
+			Name: req.Name,
+			Size: req.Size,
+			Type: req.VolumeType,
+		},
+		VolumeID:    req.ServiceName + "-vol-" + req.Name,
+		ServiceName: req.ServiceName,
+		Schedulers:  append([]string{req.ServiceName}, req.Schedulers...),
+		Labels:      req.Labels,
+	}
+	f.Vols[vol.VolumeID] = vol
+	return vol, nil
+}
+
+// VolumeRemove removes a volume
+func (f *Fake) VolumeRemove(volumeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Vols, volumeID)
+	return nil
+}
+
+// VolumeUpdate applies a change to a volume in place
+func (f *Fake) VolumeUpdate(
+	volumeID, ifMatch string,
+	change func(vol *types.Volume) error) (*types.Volume, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vol, ok := f.Vols[volumeID]
+	if !ok {
+		return nil, types.NewError(http.StatusNotFound, "no volume")
+	}
+	return vol, change(vol)
+}
+
+// VolumeSnapshot takes a completed snapshot of a volume
+func (f *Fake) VolumeSnapshot(volumeID, name string) (*types.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snap := &types.Snapshot{
+		Snapshot:       &lstypes.Snapshot{ID: "snap-" + name, VolumeSize: 2, Status: "completed"},
+		SnapshotID:     "ebs-snap-" + name,
+		SourceVolumeID: volumeID,
+	}
+	f.Snaps[snap.SnapshotID] = snap
+	return snap, nil
+}
+
+// SnapshotInspect returns a snapshot, 404 when missing
+func (f *Fake) SnapshotInspect(snapshotID string) (*types.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snap, ok := f.Snaps[snapshotID]
+	if !ok {
+		return nil, types.NewError(http.StatusNotFound, "no snapshot")
+	}
+	return snap, nil
+}
+
+// SnapshotRemove removes a snapshot, 404 when missing
+func (f *Fake) SnapshotRemove(snapshotID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Snaps[snapshotID]; !ok {
+		return types.NewError(http.StatusNotFound, "no snapshot")
+	}
+	delete(f.Snaps, snapshotID)
+	return nil
+}
+
+// Suspended returns a 409 for the schedulers in Stale
+func (f *Fake) Suspended(scheduler string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Stale[scheduler] {
+		return types.NewErrorf(http.StatusConflict,
+			"offers to scheduler %q are suspended", scheduler)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+// Package webhooks delivers the volume lifecycle events to the registered
+// HTTP webhooks
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
+)
+
+const (
+	attemptsKey = "polly.webhooks.attempts"
+	backoffKey  = "polly.webhooks.backoff"
+	timeoutKey  = "polly.webhooks.timeout"
+	logSizeKey  = "polly.webhooks.logSize"
+
+	// queueSize is the number of events waiting for dispatch before new
+	// ones are dropped
+	queueSize = 1024
+)
+
+func init() {
+	gofig.Register(configRegistration())
+}
+
+// Store keeps the webhooks and their delivery logs
+type Store interface {
+	Webhooks() ([]*types.Webhook, error)
+	AppendWebhookDelivery(id string, d *types.WebhookDelivery, max int) error
+}
+
+// Dispatcher calls the webhooks subscribed to the events it is notified of.
+// Failed calls are retried with an exponential backoff and every attempt is
+// added to the delivery log of the webhook.
+type Dispatcher struct {
+	st       Store
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	logSize  int
+
+	events chan *types.Event
+
+	// stop ends the dispatch and the retries, ctx is canceled to abort the
+	// calls in flight once the deliveries were given time to complete
+	stop   chan struct{}
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	// wg tracks the deliveries in flight, mu serializes the writes of the
+	// delivery logs
+	wg sync.WaitGroup
+	mu sync.Mutex
+}
+
+// New returns a dispatcher of the webhooks of a store
+func New(cfg gofig.Config, st Store) *Dispatcher {
+	return newDispatcher(st,
+		&http.Client{Timeout: config.Duration(cfg, timeoutKey, 10*time.Second)},
+		cfg.GetInt(attemptsKey),
+		config.Duration(cfg, backoffKey, time.Second),
+		cfg.GetInt(logSizeKey))
+}
+
+func newDispatcher(
+	st Store,
+	client *http.Client,
+	attempts int,
+	backoff time.Duration,
+	logSize int) *Dispatcher {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		st:       st,
+		client:   client,
+		attempts: attempts,
+		backoff:  backoff,
+		logSize:  logSize,
+		events:   make(chan *types.Event, queueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	if d.attempts <= 0 {
+		d.attempts = 1
+	}
+	if d.logSize <= 0 {
+		d.logSize = 50
+	}
+	return d
+}
+
+// Start dispatches the events in the background until Stop is called
+func (d *Dispatcher) Start() {
+	go func() {
+		defer close(d.done)
+		for {
+			select {
+			case e := <-d.events:
+				d.dispatch(e)
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Notify queues an event for dispatch, setting its ID and time. A nil
+// dispatcher ignores the events and a full queue drops them.
+func (d *Dispatcher) Notify(e *types.Event) {
+	if d == nil {
+		return
+	}
+	if e.ID == "" {
+		e.ID = NewID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	select {
+	case d.events <- e:
+	default:
+		log.WithFields(log.Fields{
+			"eventID":  e.ID,
+			"type":     e.Type,
+			"volumeID": e.VolumeID,
+		}).Warn("webhook queue full, dropping event")
+	}
+}
+
+// Stop ends the dispatch and waits for the deliveries in flight to complete
+// or for the context to be done, their retries are abandoned
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.once.Do(func() { close(d.stop) })
+
+	finished := make(chan struct{})
+	go func() {
+		<-d.done
+		d.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+// dispatch starts the delivery of an event to each webhook subscribed to it
+func (d *Dispatcher) dispatch(e *types.Event) {
+	hooks, err := d.st.Webhooks()
+	if err != nil {
+		log.WithError(err).WithField("eventID", e.ID).Error(
+			"problem getting webhooks, dropping event")
+		return
+	}
+
+	body, _ := json.Marshal(e)
+	for _, hook := range hooks {
+		if !subscribed(hook, e.Type) {
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(hook, e, body)
+	}
+}
+
+// deliver calls a webhook until it succeeds or the attempts are exhausted
+func (d *Dispatcher) deliver(hook *types.Webhook, e *types.Event, body []byte) {
+	defer d.wg.Done()
+
+	wait := d.backoff
+	for attempt := 1; ; attempt++ {
+		rec := d.call(hook, e, body)
+		rec.Attempt = attempt
+		d.record(hook, rec)
+		if rec.Error == "" {
+			return
+		}
+
+		fields := log.Fields{
+			"webhookID": hook.ID,
+			"eventID":   e.ID,
+			"attempt":   attempt,
+			"status":    rec.Status,
+			"error":     rec.Error,
+		}
+		if attempt >= d.attempts {
+			log.WithFields(fields).Warn("webhook delivery failed, giving up")
+			return
+		}
+		log.WithFields(fields).Debug("webhook delivery failed, retrying")
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// call posts an event to a webhook and returns the delivery record, whose
+// error is set unless the webhook replied with a 2xx status
+func (d *Dispatcher) call(
+	hook *types.Webhook, e *types.Event, body []byte) *types.WebhookDelivery {
+
+	rec := &types.WebhookDelivery{
+		EventID:   e.ID,
+		EventType: e.Type,
+		Time:      time.Now().UTC(),
+	}
+	defer func() { rec.Duration = time.Since(rec.Time).Seconds() }()
+
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	req = req.WithContext(d.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(types.WebhookEventHeader, e.Type)
+	req.Header.Set(types.WebhookSignatureHeader, Sign(hook.Secret, body))
+	if e.RequestID != "" {
+		req.Header.Set(types.RequestIDHeader, e.RequestID)
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	res.Body.Close()
+
+	rec.Status = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		rec.Error = res.Status
+	}
+	return rec
+}
+
+// record adds a delivery to the log of its webhook
+func (d *Dispatcher) record(hook *types.Webhook, rec *types.WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.st.AppendWebhookDelivery(hook.ID, rec, d.logSize); err != nil {
+		log.WithError(err).WithField("webhookID", hook.ID).Warn(
+			"problem logging webhook delivery")
+	}
+}
+
+// subscribed returns whether a webhook is called on events of a type
+func subscribed(hook *types.Webhook, typ string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, t := range hook.Events {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of a payload keyed by the secret of a webhook,
+// the value of its X-Polly-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewID returns a random identifier
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewWebhook returns the webhook registered by a request, with a generated
+// ID and, when the request has none, a generated secret
+func NewWebhook(req *types.WebhookCreateRequest) (*types.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, types.NewErrorf(http.StatusBadRequest,
+			"invalid webhook URL %q", req.URL).WithDetail("url", req.URL)
+	}
+	for _, typ := range req.Events {
+		if !known(typ) {
+			return nil, types.NewErrorf(http.StatusBadRequest,
+				"unknown event type %q", typ).WithDetail("event", typ)
+		}
+	}
+
+	hook := &types.Webhook{
+		ID:      NewID(),
+		URL:     req.URL,
+		Events:  req.Events,
+		Secret:  req.Secret,
+		Created: time.Now().UTC(),
+	}
+	if hook.Secret == "" {
+		hook.Secret = NewID()
+	}
+	return hook, nil
+}
+
+func known(typ string) bool {
+	for _, t := range types.EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func configRegistration() *gofig.Registration {
+	r := gofig.NewRegistration("Webhooks")
+	r.Key(gofig.Int, "", 5,
+		"The number of attempts to deliver an event to a webhook", attemptsKey)
+	r.Key(gofig.String, "", "1s",
+		"The wait before the first retry, doubled on every retry", backoffKey)
+	r.Key(gofig.String, "", "10s",
+		"The time a webhook has to reply", timeoutKey)
+	r.Key(gofig.Int, "", 50,
+		"The number of deliveries kept in the log of a webhook", logSizeKey)
+	return r
+}
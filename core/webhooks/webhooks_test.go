@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emccode/polly/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore holds webhooks and their delivery logs in memory
+type fakeStore struct {
+	mu    sync.Mutex
+	hooks []*types.Webhook
+	log   map[string][]*types.WebhookDelivery
+}
+
+func (f *fakeStore) Webhooks() ([]*types.Webhook, error) {
+	return f.hooks, nil
+}
+
+func (f *fakeStore) AppendWebhookDelivery(
+	id string, d *types.WebhookDelivery, max int) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ds := append(f.log[id], d)
+	if len(ds) > max {
+		ds = ds[len(ds)-max:]
+	}
+	f.log[id] = ds
+	return nil
+}
+
+func (f *fakeStore) deliveries(id string) []*types.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.log[id]
+}
+
+func TestDispatcher(t *testing.T) {
+	var (
+		calls, failures int32
+		signature       string
+		body            []byte
+	)
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		signature = r.Header.Get(types.WebhookSignatureHeader)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failures, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	st := &fakeStore{
+		hooks: []*types.Webhook{
+			{ID: "ok", URL: ok.URL, Secret: "s3cret",
+				Events: []string{types.EventVolumeOffer}},
+			{ID: "failing", URL: failing.URL},
+		},
+		log: map[string][]*types.WebhookDelivery{},
+	}
+	d := newDispatcher(st, ok.Client(), 3, time.Millisecond, 2)
+	d.Start()
+
+	d.Notify(&types.Event{Type: types.EventVolumeCreate, VolumeID: "ebs-vol-1"})
+	d.Notify(&types.Event{Type: types.EventVolumeOffer, VolumeID: "ebs-vol-1",
+		Schedulers: []string{"mesos0"}})
+
+	// both events are retried on the failing webhook until the attempts are
+	// exhausted
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&failures) == 6 && len(st.deliveries("failing")) == 2 &&
+			st.deliveries("failing")[1].Attempt == 3
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	// only the offer is delivered to the subscribed webhook
+	assert.EqualValues(t, 1, calls)
+	assert.Equal(t, Sign("s3cret", body), signature)
+	if ds := st.deliveries("ok"); assert.Len(t, ds, 1) {
+		assert.Equal(t, types.EventVolumeOffer, ds[0].EventType)
+		assert.Equal(t, http.StatusOK, ds[0].Status)
+		assert.Empty(t, ds[0].Error)
+	}
+
+	// the log keeps the last two deliveries
+	if ds := st.deliveries("failing"); assert.Len(t, ds, 2) {
+		assert.Equal(t, 3, ds[1].Attempt)
+		assert.Equal(t, http.StatusBadGateway, ds[1].Status)
+		assert.NotEmpty(t, ds[1].Error)
+	}
+
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Notify(&types.Event{Type: types.EventVolumeCreate})
+}
+
+func TestNewWebhook(t *testing.T) {
+	hook, err := NewWebhook(&types.WebhookCreateRequest{
+		URL:    "https://cmdb.example.com/polly",
+		Events: []string{types.EventVolumeLease},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, hook.ID, 32)
+	assert.Len(t, hook.Secret, 32)
+
+	for _, req := range []*types.WebhookCreateRequest{
+		{URL: "ftp://cmdb.example.com"},
+		{URL: "/polly"},
+		{URL: "http://cmdb.example.com", Events: []string{"volume.moved"}},
+	} {
+		_, err = NewWebhook(req)
+		assert.Equal(t, http.StatusBadRequest, types.ErrorStatus(err), req.URL)
+	}
+}
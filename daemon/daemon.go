@@ -1,27 +1,51 @@
 package daemon
 
 import (
+	"context"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
 	log "github.com/Sirupsen/logrus"
 	gofig "github.com/akutz/gofig"
 	"github.com/akutz/goof"
 	core "github.com/emccode/polly/core"
-	"os"
-	"strconv"
+	"github.com/emccode/polly/core/config"
 )
 
+// stopTimeout bounds how long in-flight requests are drained on stop
+const stopTimeout = 30 * time.Second
+
 // Run the Polly daemon
 func Run(cfg gofig.Config) error {
 	p := core.NewWithConfig(cfg)
 
 	if err := core.Run(p); err != nil {
-		return goof.New("problem starting polly core")
+		return goof.WithError("problem running polly core", err)
 	}
 	return nil
 }
 
-// Start the Polly daemon
+// Start the Polly daemon. Start returns once the stop channel receives a
+// signal and the core services have been stopped, or when a core service
+// fails. A SIGHUP reloads the configuration from the config files instead of
+// stopping the daemon. When stop is nil Start returns as soon as the services
+// are running.
 func Start(cfg gofig.Config, init chan error, stop <-chan os.Signal) error {
+	return StartWithLoader(cfg, config.FileLoader(""), init, stop)
+}
+
+// StartWithLoader starts the Polly daemon like Start, reloading the
+// configuration with load
+func StartWithLoader(
+	cfg gofig.Config,
+	load config.Loader,
+	init chan error,
+	stop <-chan os.Signal) error {
+
 	p := core.NewWithConfig(cfg)
+	p.Reloader = config.NewReloader(cfg, load)
 
 	if debug, _ := strconv.ParseBool(os.Getenv("POLLY_DEBUG")); debug {
 		log.SetLevel(log.DebugLevel)
@@ -33,9 +57,12 @@ func Start(cfg gofig.Config, init chan error, stop <-chan os.Signal) error {
 		os.Setenv("POLLY_CLIENT_HTTP_LOGGING_LOGRESPONSE", "true")
 	}
 
-	var err error
-	if err = core.Start(p); err != nil {
-		init <- goof.WithError("problem starting polly core", err)
+	s, err := core.Start(p)
+	if err != nil {
+		err = goof.WithError("problem starting polly core", err)
+		if init != nil {
+			init <- err
+		}
 	}
 
 	if init != nil {
@@ -43,15 +70,43 @@ func Start(cfg gofig.Config, init chan error, stop <-chan os.Signal) error {
 	}
 
 	if err != nil {
-		log.Error(err)
 		log.WithError(err).Error("service initialization failed")
+		return err
 	}
 
 	log.Info("service successfully initialized, waiting on stop signal")
 
-	if stop != nil {
-		<-stop
-		log.Info("Service received stop signal")
+	if stop == nil {
+		return nil
 	}
-	return nil
+
+wait:
+	for {
+		select {
+		case sig := <-stop:
+			if sig == syscall.SIGHUP {
+				log.Info("service received reload signal")
+				if _, rerr := p.Reloader.Reload(); rerr != nil {
+					log.WithError(rerr).Error("problem reloading config")
+				}
+				continue
+			}
+			log.WithField("signal", sig).Info("service received stop signal")
+		case err = <-s.Err():
+			log.WithError(err).Error("service failed, stopping")
+		}
+		break wait
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	if serr := s.Stop(ctx); serr != nil {
+		log.WithError(serr).Error("problem stopping service")
+		if err == nil {
+			err = serr
+		}
+	} else {
+		log.Info("service stopped")
+	}
+	return err
 }
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"bytes"
+	"context"
 	"os"
 
 	"testing"
@@ -23,7 +24,8 @@ func TestPolly(t *testing.T) {
 	}
 
 	p := core.NewWithConfig(cfg)
-	if err := core.Start(p); err != nil {
+	s, err := core.Start(p)
+	if err != nil {
 		log.WithError(err).Fatal("problem starting polly core")
 		os.Exit(1)
 	}
@@ -41,4 +43,6 @@ func TestPolly(t *testing.T) {
 	assert.Len(t, vols, 3)
 	// assert.Equal(t, vols[0])
 
+	assert.NoError(t, s.Stop(context.Background()))
+
 }
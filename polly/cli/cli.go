@@ -50,6 +50,7 @@ type CLI struct {
 	uninstallCmd         *cobra.Command
 	serviceStartCmd      *cobra.Command
 	serviceRestartCmd    *cobra.Command
+	serviceReloadCmd     *cobra.Command
 	serviceStopCmd       *cobra.Command
 	serviceStatusCmd     *cobra.Command
 	serviceInitSysCmd    *cobra.Command
@@ -63,6 +64,26 @@ type CLI struct {
 	storeCmd             *cobra.Command
 	storeEraseCmd        *cobra.Command
 	storeGetCmd          *cobra.Command
+	webhookCmd           *cobra.Command
+	webhookGetCmd        *cobra.Command
+	webhookAddCmd        *cobra.Command
+	webhookRemoveCmd     *cobra.Command
+	webhookDeliveriesCmd *cobra.Command
+	tenantCmd            *cobra.Command
+	tenantGetCmd         *cobra.Command
+	tenantAddCmd         *cobra.Command
+	tenantUpdateCmd      *cobra.Command
+	tenantRemoveCmd      *cobra.Command
+	tenantTokensCmd      *cobra.Command
+	tenantTokenAddCmd    *cobra.Command
+	tenantTokenRemoveCmd *cobra.Command
+	schedulerCmd         *cobra.Command
+	schedulerGetCmd      *cobra.Command
+	schedulerAddCmd      *cobra.Command
+	schedulerUpdateCmd   *cobra.Command
+	schedulerRemoveCmd   *cobra.Command
+	schedulerBeatCmd     *cobra.Command
+	schedulerVolumesCmd  *cobra.Command
 
 	outputFormat     string
 	client           string
@@ -70,6 +91,10 @@ type CLI struct {
 	force            bool
 	cfgFile          string
 	all              bool
+	filter           string
+	sort             string
+	fresh            bool
+	dryRun           bool
 	volumeID         string
 	schedulers       []string
 	labels           []string
@@ -79,6 +104,15 @@ type CLI struct {
 	size             int64
 	name             string
 	availabilityZone string
+	webhookID        string
+	url              string
+	secret           string
+	events           []string
+	description      string
+	tokenID          string
+	schedulerType    string
+	contact          string
+	tenant           string
 }
 
 const (
@@ -183,6 +217,9 @@ func NewWithArgs(a ...string) *CLI {
 	c.initOtherCmdsAndFlags()
 	c.initVolumeCmdsAndFlags()
 	c.initStoreCmdsAndFlags()
+	c.initWebhookCmdsAndFlags()
+	c.initTenantCmdsAndFlags()
+	c.initSchedulerCmdsAndFlags()
 	c.initServiceCmdsAndFlags()
 	c.initUsageTemplates()
 
@@ -343,6 +380,10 @@ func (c *CLI) checkCmdPermRequirements(cmd *cobra.Command) error {
 		return checkOpPerms("restarted")
 	}
 
+	if cmd == c.serviceReloadCmd {
+		return checkOpPerms("reloaded")
+	}
+
 	return nil
 }
 
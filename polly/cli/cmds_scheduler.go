@@ -0,0 +1,144 @@
+package cli
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/emccode/polly/api/types"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) initSchedulerCmdsAndFlags() {
+	c.initSchedulerCmds()
+	c.initSchedulerFlags()
+}
+
+func (c *CLI) initSchedulerCmds() {
+
+	c.schedulerCmd = &cobra.Command{
+		Use:   "scheduler",
+		Short: "The scheduler registry",
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.schedulerGetCmd.Run(c.schedulerGetCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.schedulerCmd)
+
+	c.schedulerGetCmd = &cobra.Command{
+		Use:     "get",
+		Short:   "Get one or all of the registered schedulers",
+		Aliases: []string{"ls", "list"},
+		Run: func(cmd *cobra.Command, args []string) {
+			if c.name != "" {
+				s, err := c.pc.SchedulerInspect(c.name)
+				if err != nil {
+					log.Fatal(err)
+				}
+				c.printOutput(&s)
+				return
+			}
+			ss, err := c.pc.Schedulers()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&ss)
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerGetCmd)
+
+	c.schedulerAddCmd = &cobra.Command{
+		Use:   "add",
+		Short: "Register a scheduler",
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := c.pc.SchedulerCreate(c.name, c.schedulerType, c.contact, c.tenant)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&s)
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerAddCmd)
+
+	c.schedulerUpdateCmd = &cobra.Command{
+		Use:   "update",
+		Short: "Replace the type, contact and tenant of a scheduler",
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := c.pc.SchedulerUpdate(c.name, c.schedulerType, c.contact, c.tenant)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&s)
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerUpdateCmd)
+
+	c.schedulerRemoveCmd = &cobra.Command{
+		Use:   "remove",
+		Short: "Unregister a scheduler",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.pc.SchedulerRemove(c.name); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerRemoveCmd)
+
+	c.schedulerBeatCmd = &cobra.Command{
+		Use:   "heartbeat",
+		Short: "Record that a scheduler is alive",
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := c.pc.SchedulerHeartbeat(c.name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&s)
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerBeatCmd)
+
+	c.schedulerVolumesCmd = &cobra.Command{
+		Use:   "volumes",
+		Short: "Get the volumes offered to a scheduler",
+		Run: func(cmd *cobra.Command, args []string) {
+			av, err := c.pc.SchedulerVolumes(c.name, &types.VolumeListOptions{
+				Filter: c.filter,
+				Sort:   c.sort,
+				Fresh:  c.fresh,
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&av)
+		},
+	}
+	c.schedulerCmd.AddCommand(c.schedulerVolumesCmd)
+}
+
+func (c *CLI) initSchedulerFlags() {
+	for _, cmd := range []*cobra.Command{
+		c.schedulerGetCmd, c.schedulerAddCmd, c.schedulerUpdateCmd,
+		c.schedulerRemoveCmd, c.schedulerBeatCmd, c.schedulerVolumesCmd} {
+		cmd.Flags().StringVar(&c.name, "name", "", "name")
+	}
+	for _, cmd := range []*cobra.Command{c.schedulerAddCmd, c.schedulerUpdateCmd} {
+		cmd.Flags().StringVar(&c.schedulerType, "type", "",
+			"type, one of mesos, k8s, swarm, cloudfoundry or other")
+		cmd.Flags().StringVar(&c.contact, "contact", "", "contact")
+		cmd.Flags().StringVar(&c.tenant, "tenant", "", "tenant owning the scheduler")
+	}
+	c.schedulerVolumesCmd.Flags().StringVar(&c.filter, "filter", "",
+		"filter expression, e.g. \"size>=100,labels.tier in (gold,silver)\"")
+	c.schedulerVolumesCmd.Flags().StringVar(&c.sort, "sort", "",
+		"sort key (volumeID, name, serviceName, size, iops, created), descending when prefixed by -")
+	c.schedulerVolumesCmd.Flags().BoolVar(&c.fresh, "fresh", false,
+		"list the volumes from libStorage rather than from the daemon cache")
+
+	c.addOutputFormatFlag(c.schedulerCmd.Flags())
+	c.addOutputFormatFlag(c.schedulerGetCmd.Flags())
+	c.addOutputFormatFlag(c.schedulerAddCmd.Flags())
+	c.addOutputFormatFlag(c.schedulerUpdateCmd.Flags())
+	c.addOutputFormatFlag(c.schedulerBeatCmd.Flags())
+	c.addOutputFormatFlag(c.schedulerVolumesCmd.Flags())
+}
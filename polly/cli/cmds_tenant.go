@@ -0,0 +1,135 @@
+package cli
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) initTenantCmdsAndFlags() {
+	c.initTenantCmds()
+	c.initTenantFlags()
+}
+
+func (c *CLI) initTenantCmds() {
+
+	c.tenantCmd = &cobra.Command{
+		Use:   "tenant",
+		Short: "The tenant manager",
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.tenantGetCmd.Run(c.tenantGetCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.tenantCmd)
+
+	c.tenantGetCmd = &cobra.Command{
+		Use:     "get",
+		Short:   "Get the tenants",
+		Aliases: []string{"ls", "list"},
+		Run: func(cmd *cobra.Command, args []string) {
+			ts, err := c.pc.Tenants()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&ts)
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantGetCmd)
+
+	c.tenantAddCmd = &cobra.Command{
+		Use:   "add",
+		Short: "Create a tenant owning schedulers",
+		Run: func(cmd *cobra.Command, args []string) {
+			t, err := c.pc.TenantCreate(c.name, c.description, c.schedulers)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&t)
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantAddCmd)
+
+	c.tenantUpdateCmd = &cobra.Command{
+		Use:   "update",
+		Short: "Replace the description and the schedulers of a tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			t, err := c.pc.TenantUpdate(c.name, c.description, c.schedulers)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&t)
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantUpdateCmd)
+
+	c.tenantRemoveCmd = &cobra.Command{
+		Use:   "remove",
+		Short: "Remove a tenant owning no volume",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.pc.TenantRemove(c.name); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantRemoveCmd)
+
+	c.tenantTokensCmd = &cobra.Command{
+		Use:   "tokens",
+		Short: "Get the tokens of a tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			ts, err := c.pc.TenantTokens(c.name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&ts)
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantTokensCmd)
+
+	c.tenantTokenAddCmd = &cobra.Command{
+		Use:   "token-add",
+		Short: "Create a token restricted to a tenant, it is only printed once",
+		Run: func(cmd *cobra.Command, args []string) {
+			t, err := c.pc.TenantTokenCreate(c.name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&t)
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantTokenAddCmd)
+
+	c.tenantTokenRemoveCmd = &cobra.Command{
+		Use:   "token-remove",
+		Short: "Remove a token of a tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.pc.TenantTokenRemove(c.name, c.tokenID); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	c.tenantCmd.AddCommand(c.tenantTokenRemoveCmd)
+}
+
+func (c *CLI) initTenantFlags() {
+	for _, cmd := range []*cobra.Command{
+		c.tenantAddCmd, c.tenantUpdateCmd, c.tenantRemoveCmd,
+		c.tenantTokensCmd, c.tenantTokenAddCmd, c.tenantTokenRemoveCmd} {
+		cmd.Flags().StringVar(&c.name, "name", "", "name")
+	}
+	c.tenantAddCmd.Flags().StringVar(&c.description, "description", "", "description")
+	c.tenantAddCmd.Flags().StringSliceVar(&c.schedulers, "scheduler", []string{""}, "scheduler")
+	c.tenantUpdateCmd.Flags().StringVar(&c.description, "description", "", "description")
+	c.tenantUpdateCmd.Flags().StringSliceVar(&c.schedulers, "scheduler", []string{""}, "scheduler")
+	c.tenantTokenRemoveCmd.Flags().StringVar(&c.tokenID, "tokenid", "", "tokenid")
+
+	c.addOutputFormatFlag(c.tenantCmd.Flags())
+	c.addOutputFormatFlag(c.tenantGetCmd.Flags())
+	c.addOutputFormatFlag(c.tenantAddCmd.Flags())
+	c.addOutputFormatFlag(c.tenantUpdateCmd.Flags())
+	c.addOutputFormatFlag(c.tenantTokensCmd.Flags())
+	c.addOutputFormatFlag(c.tenantTokenAddCmd.Flags())
+}
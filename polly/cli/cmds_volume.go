@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/emccode/polly/api/types"
@@ -48,13 +49,12 @@ func (c *CLI) initVolumeCmds() {
 					log.Fatal(err)
 				}
 				fmt.Println(out)
-			} else if c.all {
-				av, err = c.pc.VolumesAll()
-				if err != nil {
-					log.Fatal(err)
-				}
 			} else {
-				av, err = c.pc.Volumes()
+				av, err = c.pc.VolumesList(c.all, &types.VolumeListOptions{
+					Filter: c.filter,
+					Sort:   c.sort,
+					Fresh:  c.fresh,
+				})
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -75,6 +75,13 @@ func (c *CLI) initVolumeCmds() {
 		Use:   "offer",
 		Short: "Offer a volume to schedulers",
 		Run: func(cmd *cobra.Command, args []string) {
+			if c.isBulk() {
+				c.bulk(&types.VolumeBulkRequest{
+					Operation:  types.BulkOffer,
+					Schedulers: c.schedulers,
+				})
+				return
+			}
 			av, err := c.pc.VolumeOffer(c.volumeID, c.schedulers)
 			if err != nil {
 				log.Fatal(err)
@@ -93,6 +100,13 @@ func (c *CLI) initVolumeCmds() {
 		Use:   "revoke",
 		Short: "Revoke an offer of a volume to schedulers",
 		Run: func(cmd *cobra.Command, args []string) {
+			if c.isBulk() {
+				c.bulk(&types.VolumeBulkRequest{
+					Operation:  types.BulkRevoke,
+					Schedulers: c.schedulers,
+				})
+				return
+			}
 			av, err := c.pc.VolumeOfferRevoke(c.volumeID, c.schedulers)
 			if err != nil {
 				log.Fatal(err)
@@ -111,6 +125,13 @@ func (c *CLI) initVolumeCmds() {
 		Use:   "label",
 		Short: "Create labels on a volume",
 		Run: func(cmd *cobra.Command, args []string) {
+			if c.isBulk() {
+				c.bulkSend(&types.VolumeBulkRequest{},
+					func(req *types.VolumeBulkRequest) (*types.VolumeBulkResponse, error) {
+						return c.pc.VolumesBulkLabel(req, c.labels)
+					})
+				return
+			}
 			av, err := c.pc.VolumeLabel(c.volumeID, c.labels)
 			if err != nil {
 				log.Fatal(err)
@@ -130,6 +151,13 @@ func (c *CLI) initVolumeCmds() {
 		Short:   "Remove labels from a volume",
 		Aliases: []string{"lr"},
 		Run: func(cmd *cobra.Command, args []string) {
+			if c.isBulk() {
+				c.bulk(&types.VolumeBulkRequest{
+					Operation: types.BulkUnlabel,
+					LabelKeys: c.labels,
+				})
+				return
+			}
 			av, err := c.pc.VolumeLabelsRemove(c.volumeID, c.labels)
 			if err != nil {
 				log.Fatal(err)
@@ -170,6 +198,10 @@ func (c *CLI) initVolumeCmds() {
 		Short:   "Removes a volume",
 		Aliases: []string{"rm", "delete"},
 		Run: func(cmd *cobra.Command, args []string) {
+			if c.isBulk() {
+				c.bulk(&types.VolumeBulkRequest{Operation: types.BulkRemove})
+				return
+			}
 			err := c.pc.VolumeRemove(c.volumeID)
 			if err != nil {
 				log.Fatal(err)
@@ -180,9 +212,55 @@ func (c *CLI) initVolumeCmds() {
 
 }
 
+// isBulk returns whether a volume command selects volumes by filter or is a
+// dry run, in which case it goes through the bulk endpoint
+func (c *CLI) isBulk() bool {
+	return c.filter != "" || c.dryRun
+}
+
+// bulk applies a volume command to the volume given by ID or to those
+// matching the filter, printing the outcome for every volume and exiting
+// with an error when any failed
+func (c *CLI) bulk(req *types.VolumeBulkRequest) {
+	c.bulkSend(req, c.pc.VolumesBulk)
+}
+
+// bulkSend completes the selection of a bulk request and sends it with send
+func (c *CLI) bulkSend(
+	req *types.VolumeBulkRequest,
+	send func(*types.VolumeBulkRequest) (*types.VolumeBulkResponse, error)) {
+
+	req.Filter = c.filter
+	req.DryRun = c.dryRun
+	if c.volumeID != "" {
+		req.VolumeIDs = []string{c.volumeID}
+	}
+
+	res, err := send(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := c.marshalOutput(&res)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out)
+
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
 func (c *CLI) initVolumeFlags() {
 	c.volumeGetCmd.Flags().BoolVar(&c.all, "all", false, "all")
 	c.volumeGetCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeGetCmd.Flags().StringVar(&c.filter, "filter", "",
+		"filter expression, e.g. \"size>=100,labels.tier in (gold,silver)\"")
+	c.volumeGetCmd.Flags().StringVar(&c.sort, "sort", "",
+		"sort key (volumeID, name, serviceName, size, iops, created), descending when prefixed by -")
+	c.volumeGetCmd.Flags().BoolVar(&c.fresh, "fresh", false,
+		"list the volumes from libStorage rather than from the daemon cache")
 	c.volumeOfferCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.volumeOfferCmd.Flags().StringSliceVar(&c.schedulers, "scheduler", []string{""}, "scheduler")
 	c.volumeOfferRevokeCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
@@ -202,6 +280,19 @@ func (c *CLI) initVolumeFlags() {
 	c.volumeCreateCmd.Flags().StringSliceVar(&c.schedulers, "scheduler", []string{""}, "scheduler")
 	c.volumeRemoveCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 
+	for _, cmd := range []*cobra.Command{
+		c.volumeOfferCmd,
+		c.volumeOfferRevokeCmd,
+		c.volumeLabelCmd,
+		c.volumeLabelRemoveCmd,
+		c.volumeRemoveCmd,
+	} {
+		cmd.Flags().StringVar(&c.filter, "filter", "",
+			"apply to all the volumes matching a filter expression")
+		cmd.Flags().BoolVar(&c.dryRun, "dry-run", false,
+			"report the volumes and the outcome without applying the operation")
+	}
+
 	c.addOutputFormatFlag(c.volumeCmd.Flags())
 	c.addOutputFormatFlag(c.volumeGetCmd.Flags())
 	c.addOutputFormatFlag(c.volumeOfferCmd.Flags())
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) initWebhookCmdsAndFlags() {
+	c.initWebhookCmds()
+	c.initWebhookFlags()
+}
+
+func (c *CLI) initWebhookCmds() {
+
+	c.webhookCmd = &cobra.Command{
+		Use:   "webhook",
+		Short: "The webhook manager",
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.webhookGetCmd.Run(c.webhookGetCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.webhookCmd)
+
+	c.webhookGetCmd = &cobra.Command{
+		Use:     "get",
+		Short:   "Get the webhooks",
+		Aliases: []string{"ls", "list"},
+		Run: func(cmd *cobra.Command, args []string) {
+			hooks, err := c.pc.Webhooks()
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&hooks)
+		},
+	}
+	c.webhookCmd.AddCommand(c.webhookGetCmd)
+
+	c.webhookAddCmd = &cobra.Command{
+		Use:   "add",
+		Short: "Register a webhook, its secret is only printed once",
+		Run: func(cmd *cobra.Command, args []string) {
+			hook, err := c.pc.WebhookCreate(c.url, c.secret, c.events)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&hook)
+		},
+	}
+	c.webhookCmd.AddCommand(c.webhookAddCmd)
+
+	c.webhookRemoveCmd = &cobra.Command{
+		Use:   "remove",
+		Short: "Remove a webhook",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.pc.WebhookRemove(c.webhookID); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	c.webhookCmd.AddCommand(c.webhookRemoveCmd)
+
+	c.webhookDeliveriesCmd = &cobra.Command{
+		Use:   "deliveries",
+		Short: "Get the last deliveries to a webhook",
+		Run: func(cmd *cobra.Command, args []string) {
+			ds, err := c.pc.WebhookDeliveries(c.webhookID)
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.printOutput(&ds)
+		},
+	}
+	c.webhookCmd.AddCommand(c.webhookDeliveriesCmd)
+}
+
+// printOutput prints a value in the output format
+func (c *CLI) printOutput(v interface{}) {
+	out, err := c.marshalOutput(v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(out)
+}
+
+func (c *CLI) initWebhookFlags() {
+	c.webhookAddCmd.Flags().StringVar(&c.url, "url", "", "url")
+	c.webhookAddCmd.Flags().StringVar(&c.secret, "secret", "",
+		"the key of the payload signatures, generated when empty")
+	c.webhookAddCmd.Flags().StringSliceVar(&c.events, "event", []string{""},
+		"the type of the events delivered, e.g. volume.offer, all when not set")
+	c.webhookRemoveCmd.Flags().StringVar(&c.webhookID, "webhookid", "", "webhookid")
+	c.webhookDeliveriesCmd.Flags().StringVar(&c.webhookID, "webhookid", "", "webhookid")
+
+	c.addOutputFormatFlag(c.webhookCmd.Flags())
+	c.addOutputFormatFlag(c.webhookGetCmd.Flags())
+	c.addOutputFormatFlag(c.webhookAddCmd.Flags())
+	c.addOutputFormatFlag(c.webhookDeliveriesCmd.Flags())
+}
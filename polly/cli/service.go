@@ -7,11 +7,14 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"sort"
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gotil"
 
+	"github.com/emccode/polly/api/types"
+	"github.com/emccode/polly/core/config"
 	"github.com/emccode/polly/daemon"
 	"github.com/emccode/polly/util"
 )
@@ -160,8 +163,10 @@ func (c *CLI) startDaemon() {
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
+	done := make(chan error, 1)
 	go func() {
-		daemon.Start(c.p.Config, init, stop)
+		done <- daemon.StartWithLoader(
+			c.p.Config, config.FileLoader(c.cfgFile), init, stop)
 	}()
 
 	var initErrors []error
@@ -185,9 +190,32 @@ func (c *CLI) startDaemon() {
 		return
 	}
 
-	sigv := <-sigc
-	log.Printf("received shutdown signal %v", sigv)
-	stop <- sigv
+	for {
+		select {
+		case sigv := <-sigc:
+			if sigv == syscall.SIGHUP {
+				log.Printf("received reload signal %v", sigv)
+			} else {
+				log.Printf("received shutdown signal %v", sigv)
+			}
+			// the daemon stops reading the signals once it is exiting
+			select {
+			case stop <- sigv:
+			case err := <-done:
+				log.WithError(err).Error("service exited")
+				return
+			}
+			if sigv == syscall.SIGHUP {
+				continue
+			}
+			if err := <-done; err != nil {
+				log.WithError(err).Error("service stopped with error")
+			}
+		case err := <-done:
+			log.WithError(err).Error("service exited")
+		}
+		return
+	}
 }
 
 func (c *CLI) tryToStartDaemon() {
@@ -265,7 +293,7 @@ func stop() {
 	proc, procErr := os.FindProcess(pid)
 	failOnError(procErr)
 
-	killErr := proc.Signal(syscall.SIGHUP)
+	killErr := proc.Signal(syscall.SIGTERM)
 	failOnError(killErr)
 
 	if err := os.RemoveAll(util.PidFilePath()); err != nil {
@@ -279,6 +307,7 @@ func stop() {
 func (c *CLI) status() {
 	if useSystemDForSCMCmds {
 		statusViaSystemD()
+		c.printReadiness()
 		return
 	}
 
@@ -307,9 +336,34 @@ func (c *CLI) status() {
 	}
 
 	fmt.Printf("Polly is running at PID %d\n", pid)
+	c.printReadiness()
 	return
 }
 
+// printReadiness queries the readiness endpoint of the running daemon
+func (c *CLI) printReadiness() {
+	h, err := c.pc.Ready()
+	if err != nil {
+		fmt.Printf("Polly admin API at %s is unreachable\n  %v\n", c.host(), err)
+		return
+	}
+
+	fmt.Printf("Polly readiness: %s\n", h.Status)
+	names := make([]string, 0, len(h.Checks))
+	for name := range h.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := h.Checks[name]
+		if check.Message == "" {
+			fmt.Printf("  %-10s %s\n", name, check.Status)
+			continue
+		}
+		fmt.Printf("  %-10s %s (%s)\n", name, check.Status, check.Message)
+	}
+}
+
 func (c *CLI) restart() {
 	checkOpPerms("restarted")
 
@@ -320,6 +374,35 @@ func (c *CLI) restart() {
 	c.start()
 }
 
+// reload makes the running daemon read its configuration again and prints
+// the changed keys
+func (c *CLI) reload() {
+	checkOpPerms("reloaded")
+
+	res, err := c.pc.ConfigReload()
+	if e, ok := err.(*types.Error); ok {
+		fmt.Printf("Polly configuration reload failed\n  %v\n", e)
+		if applied, ok := e.Details["applied"].([]interface{}); ok {
+			for _, k := range applied {
+				fmt.Printf("  applied           %v\n", k)
+			}
+		}
+		panic(1)
+	}
+	if err != nil {
+		fmt.Printf("Polly admin API at %s is unreachable\n  %v\n", c.host(), err)
+		panic(1)
+	}
+
+	fmt.Println("Polly configuration reloaded")
+	for _, k := range res.Applied {
+		fmt.Printf("  applied           %s\n", k)
+	}
+	for _, k := range res.RestartRequired {
+		fmt.Printf("  restart required  %s\n", k)
+	}
+}
+
 func checkOpPerms(op string) error {
 	// if os.Geteuid() != 0 {
 	// 	return goof.Newf("Polly can only be %s by root", op)